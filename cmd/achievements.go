@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	_ "embed"
+	"image/color"
+
+	"github.com/braheezy/space-invaders/internal/achievement"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/charmbracelet/log"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+//go:embed data/achievements.json
+var defaultAchievements []byte
+
+// achievementsFile is where the progress towards the achievements is kept.
+const achievementsFile = "achievements.json"
+
+// toastFrames is how long an unlocked achievement is shown for.
+const toastFrames = 3 * 60
+
+var achievementDefsPath string
+
+func init() {
+	rootCmd.Flags().StringVar(&achievementDefsPath, "achievement-defs", "", "Data file defining the achievements, instead of the built-in ones")
+}
+
+// setupAchievements loads the achievements and the progress towards them. Like statistics,
+// they aren't kept for movies being played back or games being watched.
+func (game *SpaceInvadersGame) setupAchievements(logger *log.Logger) error {
+	if game.moviePlayer != nil || game.spectating != nil {
+		return nil
+	}
+
+	definitions, err := achievement.Parse(defaultAchievements)
+	if achievementDefsPath != "" {
+		definitions, err = achievement.LoadDefinitions(achievementDefsPath)
+	}
+	if err != nil {
+		return err
+	}
+	progress, err := achievement.LoadProgress(achievementsFile)
+	if err != nil {
+		logger.Error("Failed to load achievements", "path", achievementsFile, "err", err)
+		return nil
+	}
+	game.achievementDefs = definitions
+	game.achievementProgress = progress
+	game.achievements = achievement.NewTracker(definitions, &game.cpuEmulator.Memory, progress)
+	return nil
+}
+
+// updateAchievements checks the achievements after a frame, showing and saving any unlocked.
+// The bot can't unlock them, and neither can practice games, cheated ones or netplay games,
+// whose frames can be rolled back and played again.
+func (game *SpaceInvadersGame) updateAchievements() {
+	if len(game.toasts) > 0 {
+		game.toastFrames++
+		if game.toastFrames == toastFrames {
+			game.toasts = game.toasts[1:]
+			game.toastFrames = 0
+		}
+	}
+	if game.achievements == nil {
+		return
+	}
+
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	game.achievements.Paused = game.autoplay.Enabled() || hardware.Practicing() || game.cheated || game.netplay != nil
+	unlocked := game.achievements.Update()
+	for _, a := range unlocked {
+		game.cpuEmulator.Logger.Info("Achievement unlocked", "name", a.Name)
+	}
+	if len(unlocked) > 0 {
+		game.toasts = append(game.toasts, unlocked...)
+		game.saveAchievements()
+	}
+}
+
+// saveAchievements saves the progress towards the achievements.
+func (game *SpaceInvadersGame) saveAchievements() {
+	if game.achievementProgress == nil {
+		return
+	}
+	if err := game.achievementProgress.Save(achievementsFile); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save achievements", "path", achievementsFile, "err", err)
+	}
+}
+
+// drawToast draws the achievement just unlocked over the top of the game.
+func (game *SpaceInvadersGame) drawToast(screen *ebiten.Image) {
+	if len(game.toasts) == 0 {
+		return
+	}
+	unlocked := game.toasts[0]
+	bounds := screen.Bounds()
+	width, height := 460, 90
+	x := (bounds.Dx() - width) / 2
+	y := 20
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{0, 0, 0, 230}, false)
+	vector.StrokeRect(screen, float32(x), float32(y), float32(width), float32(height), 2, color.RGBA{196, 167, 231, 255}, false)
+
+	lines := []struct {
+		text  string
+		color color.Color
+	}{
+		{"ACHIEVEMENT UNLOCKED", color.RGBA{196, 167, 231, 255}},
+		{unlocked.Name, color.RGBA{255, 255, 0, 255}},
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(x+20), float64(y+20+i*30))
+		op.ColorScale.ScaleWithColor(line.color)
+		text.Draw(screen, line.text, loadedFont, op)
+	}
+}
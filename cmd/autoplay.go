@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"fmt"
+)
+
+var (
+	autoplayPlayers []int
+	autoplayStart   bool
+)
+
+func init() {
+	rootCmd.Flags().IntSliceVar(&autoplayPlayers, "autoplay", nil, "Let the bot play for these players, like 1 or 1,2")
+	rootCmd.Flags().BoolVar(&autoplayStart, "autoplay-start", false, "Let the bot insert coins and start games when none is being played")
+}
+
+// checkAutoplayFlags checks the players picked for the bot are players.
+func checkAutoplayFlags() error {
+	for _, player := range autoplayPlayers {
+		if player != 1 && player != 2 {
+			return fmt.Errorf("invalid autoplay player %d, must be 1 or 2", player)
+		}
+	}
+	return nil
+}
+
+// applyAutoplaySettings picks the players the bot plays for from the menu. Players picked on
+// the command line are played for too.
+func (game *SpaceInvadersGame) applyAutoplaySettings() {
+	for player := range game.autoplay.Players {
+		game.autoplay.Players[player] = game.menuScreen.GetAutoplay(player)
+	}
+	for _, player := range autoplayPlayers {
+		game.autoplay.Players[player-1] = true
+	}
+	game.autoplay.StartGames = autoplayStart || game.menuScreen.GetAutoplayStartsGames()
+}
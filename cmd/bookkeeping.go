@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/braheezy/space-invaders/internal/bookkeeping"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/charmbracelet/log"
+)
+
+// bookkeepingFile is where the operator's books are kept.
+const bookkeepingFile = "bookkeeping.json"
+
+// setupBookkeeping starts keeping the operator's books. Like statistics, they aren't kept for
+// movies being played back or games being watched, which weren't paid for here.
+func (game *SpaceInvadersGame) setupBookkeeping(logger *log.Logger) {
+	if game.moviePlayer != nil || game.spectating != nil {
+		return
+	}
+
+	books, err := bookkeeping.Load(bookkeepingFile)
+	if err != nil {
+		logger.Error("Failed to load bookkeeping", "path", bookkeepingFile, "err", err)
+		return
+	}
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	game.bookkeeping = bookkeeping.NewMeter(books, hardware.RAM())
+}
+
+// updateBookkeeping adds the frame just played, and the coins taken in it, to the books,
+// saving them when they change.
+func (game *SpaceInvadersGame) updateBookkeeping() {
+	if game.bookkeeping == nil {
+		return
+	}
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	if game.bookkeeping.Update(hardware.TakeCoins()) {
+		game.saveBookkeeping()
+	}
+}
+
+// saveBookkeeping saves the operator's books.
+func (game *SpaceInvadersGame) saveBookkeeping() {
+	if game.bookkeeping == nil {
+		return
+	}
+	if err := game.bookkeeping.Books.Save(bookkeepingFile); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save bookkeeping", "path", bookkeepingFile, "err", err)
+	}
+}
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/capture"
+	"github.com/braheezy/space-invaders/internal/invaders"
+)
+
+var (
+	recordAudio   bool
+	recordVideo   bool
+	videoFormat   string
+	recordingsDir string
+	screenshotDir string
+)
+
+func init() {
+	rootCmd.Flags().BoolVar(&recordAudio, "record-audio", false, "Record the game audio to a WAV file from the start")
+	rootCmd.Flags().BoolVar(&recordVideo, "record", false, "Record the game video from the start")
+	rootCmd.Flags().StringVar(&videoFormat, "video-format", "gif", "Format for video recordings: "+strings.Join(capture.VideoFormatNames, ", ")+"; GIFs drop frames to play at most 50 FPS")
+	rootCmd.Flags().StringVar(&recordingsDir, "recordings-dir", "recordings", "Directory to save recordings in")
+	rootCmd.Flags().StringVar(&screenshotDir, "screenshot-dir", "screenshots", "Directory to save screenshots in")
+}
+
+// capturePath returns a new timestamped file path in dir, creating dir if needed.
+// Without an extension, the path is for a directory. If the path is taken, a number is added.
+func capturePath(dir, prefix, ext string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	base := fmt.Sprintf("%s-%s", prefix, time.Now().Format("20060102-150405"))
+	if ext != "" {
+		ext = "." + ext
+	}
+	path := filepath.Join(dir, base+ext)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return path, nil
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+}
+
+// toggleAudioRecording starts recording audio, or stops the recording in progress.
+func (game *SpaceInvadersGame) toggleAudioRecording() {
+	if game.audioRecorder != nil {
+		game.stopAudioRecording()
+		return
+	}
+	if err := game.startAudioRecording(); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to start audio recording", "err", err)
+	}
+}
+
+// startAudioRecording starts teeing the mixed audio to a new WAV file.
+func (game *SpaceInvadersGame) startAudioRecording() error {
+	path, err := capturePath(recordingsDir, "audio", "wav")
+	if err != nil {
+		return err
+	}
+	recorder, err := capture.NewAudioRecorder(path, invaders.AudioSampleRate, invaders.AudioChannels, invaders.FramesPerSecond, game.cpuEmulator.FrameCount())
+	if err != nil {
+		return err
+	}
+
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.SetAudioTee(recorder)
+	game.audioRecorder = recorder
+	game.cpuEmulator.Logger.Info("Recording audio", "path", path, "frame", game.cpuEmulator.FrameCount())
+	return nil
+}
+
+// stopAudioRecording finishes the audio recording in progress, if any.
+func (game *SpaceInvadersGame) stopAudioRecording() {
+	if game.audioRecorder == nil {
+		return
+	}
+
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.SetAudioTee(nil)
+	frames := game.audioRecorder.Frames()
+	if err := game.audioRecorder.Close(); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save audio recording", "err", err)
+	} else {
+		game.cpuEmulator.Logger.Info("Saved audio recording", "frames", frames)
+	}
+	game.audioRecorder = nil
+}
+
+// toggleVideoRecording starts recording video, or stops the recording in progress.
+func (game *SpaceInvadersGame) toggleVideoRecording() {
+	if game.videoRecorder != nil {
+		game.stopVideoRecording()
+		return
+	}
+	if err := game.startVideoRecording(); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to start video recording", "err", err)
+	}
+}
+
+// startVideoRecording starts recording every emulated frame at native resolution.
+func (game *SpaceInvadersGame) startVideoRecording() error {
+	format, err := capture.ParseVideoFormat(videoFormat)
+	if err != nil {
+		return err
+	}
+	path, err := capturePath(recordingsDir, "video", format.Extension())
+	if err != nil {
+		return err
+	}
+	recorder, err := capture.NewVideoRecorder(path, format, invaders.FramesPerSecond, game.cpuEmulator.FrameCount())
+	if err != nil {
+		return err
+	}
+
+	game.videoRecorder = recorder
+	game.cpuEmulator.Logger.Info("Recording video", "path", path, "frame", game.cpuEmulator.FrameCount())
+	return nil
+}
+
+// stopVideoRecording finishes the video recording in progress, if any.
+func (game *SpaceInvadersGame) stopVideoRecording() {
+	if game.videoRecorder == nil {
+		return
+	}
+
+	frames := game.videoRecorder.Frames()
+	if err := game.videoRecorder.Close(); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save video recording", "err", err)
+	} else {
+		game.cpuEmulator.Logger.Info("Saved video recording", "startFrame", game.videoRecorder.StartFrame(), "frames", frames)
+	}
+	game.videoRecorder = nil
+}
+
+// takeScreenshot saves the current frame as a PNG, both at native resolution and at the display scale.
+func (game *SpaceInvadersGame) takeScreenshot() {
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	frame := hardware.Frame()
+
+	nativePath, err := capturePath(screenshotDir, "screenshot", "png")
+	if err == nil {
+		err = capture.SavePNG(nativePath, frame)
+	}
+	if err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save screenshot", "err", err)
+		return
+	}
+
+	scale := hardware.Scale()
+	scaledPath := strings.TrimSuffix(nativePath, ".png") + fmt.Sprintf("-%dx.png", scale)
+	if err := capture.SavePNG(scaledPath, capture.ScaleImage(frame, scale)); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save screenshot", "err", err)
+		return
+	}
+	game.cpuEmulator.Logger.Info("Saved screenshot", "path", nativePath, "scaled", scaledPath)
+}
+
+// stopRecordings finishes every recording in progress.
+func (game *SpaceInvadersGame) stopRecordings() {
+	game.stopAudioRecording()
+	game.stopVideoRecording()
+	game.stopMovieRecording()
+}
+
+// endCaptureFrame lets the recordings in progress know an emulated frame has finished.
+func (game *SpaceInvadersGame) endCaptureFrame() {
+	if game.audioRecorder != nil {
+		if err := game.audioRecorder.EndFrame(); err != nil {
+			game.cpuEmulator.Logger.Error("Failed to record audio", "err", err)
+			game.stopAudioRecording()
+		}
+	}
+	if game.videoRecorder != nil {
+		hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+		if err := game.videoRecorder.AddFrame(hardware.Frame()); err != nil {
+			game.cpuEmulator.Logger.Error("Failed to record video", "err", err)
+			game.stopVideoRecording()
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	_ "embed"
+
+	"github.com/braheezy/space-invaders/internal/cheat"
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/charmbracelet/log"
+)
+
+//go:embed data/cheats.json
+var defaultCheats []byte
+
+// cheatsFile is where the cheats for each ROM are kept.
+const cheatsFile = "cheats.json"
+
+// searchStart and searchEnd are the RAM searched for values: the game's work RAM, below the
+// video RAM.
+const (
+	searchStart = 0x2000
+	searchEnd   = 0x2400
+)
+
+// setupCheats loads the cheats for the ROM being played, starting with the built-in ones for
+// a ROM that has none yet. Games that must play exactly like another, like movies, netplay,
+// spectating and games being watched, can't be cheated.
+func (game *SpaceInvadersGame) setupCheats(logger *log.Logger) {
+	if game.movieRecorder != nil || game.moviePlayer != nil || game.netplay != nil || game.spectating != nil || game.spectatorServer != nil {
+		return
+	}
+
+	file, err := cheat.Load(cheatsFile)
+	if err != nil {
+		logger.Error("Failed to load cheats", "path", cheatsFile, "err", err)
+		return
+	}
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	game.romHash = movie.HashROM(hardware.ROM())
+	cheats, ok := file.ROMs[game.romHash]
+	if !ok {
+		builtIn, err := cheat.Parse(defaultCheats)
+		if err != nil {
+			logger.Error("Failed to load the built-in cheats", "err", err)
+			return
+		}
+		cheats = builtIn.ROMs[game.romHash]
+	}
+	game.cheatFile = file
+	game.cheats = cheats
+	game.cheatSearch = cheat.NewSearch(&game.cpuEmulator.Memory, searchStart, searchEnd)
+}
+
+// updateCheats makes the cheats that are on after a frame. A game is cheated from the frame a
+// cheat is on in it until the next game starts, and doesn't count. A save state being loaded
+// puts the machine in another game, so it starts out uncheated too.
+func (game *SpaceInvadersGame) updateCheats() {
+	if game.cheatFile == nil {
+		return
+	}
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	cheat.Apply(game.cheats, &game.cpuEmulator.Memory, len(hardware.ROM()))
+
+	playing := gamestate.Playing(hardware.RAM())
+	frame := game.cpuEmulator.FrameCount()
+	switch {
+	case frame != game.cheatsFrame+1:
+		// The frames don't follow on from the last, so a state was loaded
+		game.cheated = false
+	case playing && !game.cheatsPlaying:
+		game.cheated = false
+	}
+	game.cheatsFrame = frame
+	game.cheatsPlaying = playing
+	if cheat.Any(game.cheats) {
+		game.cheated = true
+	}
+}
+
+// saveCheats saves the cheats for the ROM being played, with those for other ROMs.
+func (game *SpaceInvadersGame) saveCheats() {
+	if game.cheatFile == nil {
+		return
+	}
+	// A ROM with no cheats isn't written down until it gets some
+	if _, saved := game.cheatFile.ROMs[game.romHash]; !saved && len(game.cheats) == 0 {
+		return
+	}
+	game.cheatFile.ROMs[game.romHash] = game.cheats
+	if err := game.cheatFile.Save(cheatsFile); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save cheats", "path", cheatsFile, "err", err)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dumpFrame int
+	dumpEvery int
+)
+
+func init() {
+	dumpStateCmd.Flags().IntVar(&dumpFrame, "frame", -1, "Frame to dump the state after; defaults to the end of the movie")
+	dumpStateCmd.Flags().IntVar(&dumpEvery, "every", 0, "Dump the state every this many frames instead, one JSON object per line")
+	dumpStateCmd.Flags().StringArrayVar(&patchPaths, "patch", nil, "IPS or BPS patch the movie's ROM was made with; repeat to apply several in order")
+	rootCmd.AddCommand(dumpStateCmd)
+}
+
+var dumpStateCmd = &cobra.Command{
+	Use:   "dump-state [movie]",
+	Short: "Print the state of the game as JSON",
+	Long: `Play a movie recorded with --record-movie without a window, and print the state of the
+game decoded from RAM as JSON: the scores, lives, credits and wave, the player's ship, the
+invaders, the shots and the UFO.
+
+Without a movie the machine is left to play the demo for --frame frames.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vm, hardware, err := replay.NewPatchedMachine(patchPaths)
+		if err != nil {
+			return err
+		}
+		settings := movie.Settings{Ships: 3, Cabinet: "Upright"}
+		frames := dumpFrame
+		var player *movie.Player
+		if len(args) == 1 {
+			m, err := movie.Load(args[0])
+			if err != nil {
+				return err
+			}
+			if romHash := movie.HashROM(hardware.ROM()); m.Header.ROMHash != romHash {
+				return fmt.Errorf("movie was recorded with a different ROM (SHA-1 %s, this is %s)", m.Header.ROMHash, romHash)
+			}
+			settings = m.Header.Settings
+			player = movie.NewPlayer(m)
+			hardware.Input = player
+			if frames < 0 || frames > m.Frames() {
+				frames = m.Frames()
+			}
+		} else if frames < 0 {
+			return errors.New("--frame is needed without a movie")
+		}
+		if err := replay.ApplySettings(vm, hardware, settings); err != nil {
+			return err
+		}
+		vm.Options.LimitTPS = false
+
+		encoder := json.NewEncoder(os.Stdout)
+		if dumpEvery <= 0 {
+			encoder.SetIndent("", "  ")
+		}
+		dump := func(frame int) error {
+			return encoder.Encode(struct {
+				Frame int `json:"frame"`
+				gamestate.State
+			}{frame, gamestate.Decode(hardware.RAM())})
+		}
+
+		for frame := 1; frame <= frames; frame++ {
+			vm.Update()
+			if player != nil {
+				if err := player.EndFrame(hardware.RAM()); err != nil {
+					return err
+				}
+			}
+			if dumpEvery > 0 && frame%dumpEvery == 0 {
+				if err := dump(frame); err != nil {
+					return err
+				}
+			}
+		}
+		if dumpEvery > 0 {
+			return nil
+		}
+		return dump(frames)
+	},
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"net"
+
+	"github.com/braheezy/space-invaders/internal/environment"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envListen string
+	envConfig = environment.DefaultConfig()
+)
+
+func init() {
+	envServerCmd.Flags().StringVar(&envListen, "listen", "localhost:7950", "Address to serve environments on")
+	envServerCmd.Flags().IntVar(&envConfig.Frameskip, "frameskip", environment.DefaultFrameskip, "Frames each step plays, from 1 to 255")
+	envServerCmd.Flags().IntVar(&envConfig.Ships, "ships", 3, "Ships each game starts with, from 3 to 6")
+	rootCmd.AddCommand(envServerCmd)
+}
+
+var envServerCmd = &cobra.Command{
+	Use:   "env-server",
+	Short: "Serve the game as an environment for training agents",
+	Long: `Serve headless games over TCP for agents to play, one for each client that connects.
+
+Clients reset the game and step it with actions, and get back a grayscale 224x256 frame,
+the points scored and whether the game is over. See the environment package for the protocol.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newDefaultLogger()
+		// Check the settings before anyone connects
+		if _, err := environment.New(envConfig); err != nil {
+			return err
+		}
+
+		listener, err := net.Listen("tcp", envListen)
+		if err != nil {
+			return err
+		}
+		defer listener.Close()
+		logger.Info("Serving environments", "addr", listener.Addr(), "frameskip", envConfig.Frameskip)
+		return environment.Serve(listener, envConfig, logger)
+	},
+}
@@ -1,16 +1,27 @@
 package cmd
 
 import (
+	"fmt"
 	"image/color"
+	"slices"
 	"strings"
+	"time"
 
+	"github.com/braheezy/space-invaders/internal/achievement"
+	"github.com/braheezy/space-invaders/internal/bookkeeping"
+	"github.com/braheezy/space-invaders/internal/cheat"
+	"github.com/braheezy/space-invaders/internal/highscore"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/stats"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
 // HelpSection represents a static section in the menu to display game controls.
 type HelpSection struct {
-	name     string
+	name string
+	// title replaces the usual "Help - name" title, for sections that aren't help
+	title    string
 	controls []string
 }
 
@@ -28,6 +39,11 @@ func (hs *HelpSection) SetValue(val interface{}) error {
 	return nil
 }
 
+// Height returns how much vertical space the help section takes up when rendered.
+func (hs *HelpSection) Height() int {
+	return 40 + len(hs.controls)*30
+}
+
 func (hs *HelpSection) Render(screen *ebiten.Image, x, y int, selected bool) {
 	// Colors for the bindings and descriptions
 	// Yellow for the keybinding
@@ -36,7 +52,10 @@ func (hs *HelpSection) Render(screen *ebiten.Image, x, y int, selected bool) {
 	descriptionColor := color.RGBA{255, 255, 255, 255}
 
 	// Render the help section title
-	title := "Help - Game Controls"
+	title := hs.title
+	if title == "" {
+		title = "Help - " + hs.name
+	}
 	op := &text.DrawOptions{}
 	op.GeoM.Translate(float64(x), float64(y))
 	op.ColorScale.ScaleWithColor(color.RGBA{196, 167, 231, 255})
@@ -69,3 +88,215 @@ func (hs *HelpSection) Render(screen *ebiten.Image, x, y int, selected bool) {
 		text.Draw(screen, description, loadedFont, op)
 	}
 }
+
+// newGameControlsHelp creates the help for playing the game, showing the keys currently bound.
+func newGameControlsHelp(bindings input.Bindings) *HelpSection {
+	// keys lists the keys bound to the actions, with short names to fit the column
+	keys := func(actions ...input.Action) string {
+		var names []string
+		for _, action := range actions {
+			for _, key := range bindings[action] {
+				name := strings.TrimPrefix(strings.TrimPrefix(key.String(), "Arrow"), "Digit")
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return "None"
+		}
+		return strings.Join(names, "/")
+	}
+
+	return &HelpSection{
+		name: "Game Controls",
+		controls: []string{
+			keys(input.P1Left, input.P1Right) + " - Player 1 move",
+			keys(input.P1Fire) + " - Player 1 shoot",
+			keys(input.P2Left, input.P2Right) + " - Player 2 move",
+			keys(input.P2Fire) + " - Player 2 shoot",
+			keys(input.Coin) + " - Insert credit",
+			keys(input.Start1P) + " - Player 1 Start",
+			keys(input.Start2P) + " - Player 2 Start",
+			keys(input.Tilt) + " - Tilt",
+			"M - Mute sound",
+			"F9 - Record audio",
+			"F10 - Record video",
+			"F12 - Screenshot",
+			"Arrow Keys/WASD - Navigate menu",
+			"Enter - Toggle setting",
+			"Tab - Toggle menu",
+			"Esc - Quit",
+		},
+	}
+}
+
+// newRebindingHelp creates the help for the key binding page.
+func newRebindingHelp() *HelpSection {
+	return &HelpSection{
+		name: "Controls",
+		controls: []string{
+			"Enter - Add or remove a key",
+			"Backspace - Clear keys",
+			"Up/Down - Choose a control",
+			"Tab - Close menu",
+		},
+	}
+}
+
+// newGamepadBindingHelp creates the help for the gamepad page, with how many gamepads are connected.
+func newGamepadBindingHelp(gamepads *input.Gamepads) *HelpSection {
+	connected := 0
+	if gamepads != nil {
+		connected = len(gamepads.Connected())
+	}
+	return &HelpSection{
+		name: fmt.Sprintf("Gamepads (%d connected)", connected),
+		controls: []string{
+			"Enter - Add or remove a button",
+			"Backspace - Clear buttons",
+			"Left/Right - Pick a gamepad",
+			"Up/Down - Choose a control",
+			"Tab - Close menu",
+		},
+	}
+}
+
+// newHighScoreSection shows the high score table, with the initials and date of each score.
+func newHighScoreSection(table *highscore.Table) *HelpSection {
+	section := &HelpSection{name: "High Scores", title: "High Scores"}
+	if table == nil {
+		section.controls = []string{"- Not kept for movies, netplay or spectating"}
+		return section
+	}
+	if len(table.Entries) == 0 {
+		section.controls = []string{"- No high scores yet"}
+		return section
+	}
+	for i, entry := range table.Entries {
+		section.controls = append(section.controls, fmt.Sprintf("%2d. %s - %04d  %s", i+1, entry.Initials, entry.Score, entry.Date.Format("2006-01-02")))
+	}
+	return section
+}
+
+// newStatsSection shows the statistics of this session next to those over every session.
+func newStatsSection(session, lifetime *stats.Totals) *HelpSection {
+	section := &HelpSection{name: "Statistics", title: "Statistics"}
+	if session == nil || lifetime == nil {
+		section.controls = []string{"- Not kept for movies or spectating"}
+		return section
+	}
+	row := func(name string, value func(t *stats.Totals) string) {
+		section.controls = append(section.controls, fmt.Sprintf("%s - %8s %9s", name, value(session), value(lifetime)))
+	}
+	count := func(get func(t *stats.Totals) int) func(t *stats.Totals) string {
+		return func(t *stats.Totals) string { return fmt.Sprint(get(t)) }
+	}
+
+	section.controls = append(section.controls, fmt.Sprintf(" - %8s %9s", "Session", "Lifetime"))
+	row("Games", count(func(t *stats.Totals) int { return t.Games }))
+	row("Play time", func(t *stats.Totals) string { return t.PlayTime().Round(time.Second).String() })
+	row("Average wave", func(t *stats.Totals) string { return fmt.Sprintf("%.1f", t.AverageWave()) })
+	row("Shots fired", count(func(t *stats.Totals) int { return t.ShotsFired }))
+	row("Accuracy", func(t *stats.Totals) string { return fmt.Sprintf("%.0f%%", t.Accuracy()*100) })
+	row("Invaders killed", count(func(t *stats.Totals) int { return t.InvadersKilled }))
+	row("UFOs destroyed", count(func(t *stats.Totals) int { return t.UFOsDestroyed }))
+	// Only the UFO scores and causes of death there have been
+	var points []int
+	for value := range lifetime.UFOPoints {
+		points = append(points, value)
+	}
+	slices.Sort(points)
+	for _, value := range points {
+		row(fmt.Sprintf("  worth %d", value), count(func(t *stats.Totals) int { return t.UFOPoints[value] }))
+	}
+	row("Deaths", count(func(t *stats.Totals) int { return t.TotalDeaths() }))
+	for _, cause := range stats.Causes {
+		if lifetime.Deaths[cause] > 0 {
+			row("  "+cause, count(func(t *stats.Totals) int { return t.Deaths[cause] }))
+		}
+	}
+	return section
+}
+
+// newAchievementsSection shows the achievements, with when each was unlocked or what to do
+// for those that aren't yet.
+func newAchievementsSection(achievements []achievement.Achievement, unlocked map[string]time.Time) *HelpSection {
+	section := &HelpSection{name: "Achievements", title: "Achievements"}
+	if achievements == nil {
+		section.controls = []string{"- Not kept for movies or spectating"}
+		return section
+	}
+	for _, a := range achievements {
+		status := a.Description
+		if date, ok := unlocked[a.ID]; ok {
+			status = "Unlocked " + date.Format("2006-01-02")
+		}
+		section.controls = append(section.controls, fmt.Sprintf("%s - %s", a.Name, status))
+	}
+	return section
+}
+
+// newOperatorSection shows the operator's books: the coins taken, the games played in each
+// mode and how long for.
+func newOperatorSection(books *bookkeeping.Books, coinLockout bool) *HelpSection {
+	section := &HelpSection{name: "Operator", title: "Bookkeeping"}
+	if books == nil {
+		section.controls = []string{"- Not kept for movies or spectating"}
+		return section
+	}
+	lockout := "Off"
+	if coinLockout {
+		lockout = "On"
+	}
+	section.controls = []string{
+		"Since - " + books.Since.Format("2006-01-02"),
+		fmt.Sprintf("Coins in - %d", books.CoinsIn),
+		fmt.Sprintf("Games - %d", books.TotalGames()),
+	}
+	for _, mode := range bookkeeping.Modes {
+		section.controls = append(section.controls, fmt.Sprintf("  %s - %d", mode, books.Games[mode]))
+	}
+	section.controls = append(section.controls,
+		"Play time - "+books.PlayTime().Round(time.Second).String(),
+		"Coin lockout - "+lockout,
+	)
+	return section
+}
+
+// newCheatsHelp creates the help for the cheats page.
+func newCheatsHelp(available bool, cheats int) *HelpSection {
+	section := &HelpSection{name: "Cheats"}
+	if !available {
+		section.controls = []string{"- Off for movies, netplay and spectators"}
+		return section
+	}
+	if cheats == 0 {
+		section.controls = append(section.controls, "- No cheats yet, find some with RAM search")
+	}
+	section.controls = append(section.controls,
+		"Enter - Turn a cheat on or off",
+		"Backspace - Remove a cheat that's off",
+		"Tab - Close menu",
+	)
+	return section
+}
+
+// newSearchHelp creates the help for the RAM search page, with how many addresses are left.
+func newSearchHelp(search *cheat.Search) *HelpSection {
+	section := &HelpSection{name: "RAM Search"}
+	if search == nil {
+		section.controls = []string{"- Off for movies, netplay and spectators"}
+		return section
+	}
+	left := fmt.Sprintf("%d addresses left", search.Count())
+	if search.Count() > searchResultsShown {
+		left += ", keep narrowing"
+	}
+	section.controls = []string{
+		"- " + left,
+		"Enter - Compare with last time",
+		"Left/Right - Pick a value",
+		"Enter - Freeze an address",
+		"Tab - Play and come back",
+	}
+	return section
+}
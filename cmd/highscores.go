@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/highscore"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/charmbracelet/log"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+)
+
+// highScoresFile is where the high score table is kept.
+const highScoresFile = "highscores.json"
+
+// initialsEntry is a player entering their initials for a new high score.
+type initialsEntry struct {
+	player int
+	score  int
+	// letters are the initials so far, with a space for letters not picked yet
+	letters [highscore.InitialsLength]byte
+	// cursor is the letter being picked
+	cursor int
+}
+
+func newInitialsEntry(player, score int) *initialsEntry {
+	entry := &initialsEntry{player: player, score: score}
+	for i := range entry.letters {
+		entry.letters[i] = 'A'
+	}
+	return entry
+}
+
+// setupHighScores loads the high score table, and starts the game with the best score as the
+// HI-SCORE. Games that must play exactly like another, like movies, netplay and spectating,
+// start from the usual high score of 0 and don't count.
+func (game *SpaceInvadersGame) setupHighScores(logger *log.Logger) {
+	if game.movieRecorder != nil || game.moviePlayer != nil || game.netplay != nil || game.spectating != nil {
+		return
+	}
+
+	table, err := highscore.Load(highScoresFile)
+	if err != nil {
+		logger.Error("Failed to load high scores", "path", highScoresFile, "err", err)
+		return
+	}
+	game.highScores = table
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.SetBootHighScore(table.Best())
+}
+
+// checkGameOver asks the players for their initials when a game ends with a new high score.
+func (game *SpaceInvadersGame) checkGameOver() {
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	gameOver, ok := hardware.TakeGameOver()
+	// Practice games and cheated ones don't count
+	if !ok || game.highScores == nil || gameOver.Practice || game.cheated {
+		return
+	}
+	for player, score := range gameOver.Scores {
+		// The bot's scores aren't anyone's
+		if game.autoplay.Players[player] {
+			continue
+		}
+		if game.highScores.Qualifies(score) {
+			game.pendingInitials = append(game.pendingInitials, newInitialsEntry(player, score))
+		}
+	}
+}
+
+// enteringInitials reports whether a player is entering their initials. The game waits
+// until they're done.
+func (game *SpaceInvadersGame) enteringInitials() bool {
+	return len(game.pendingInitials) > 0
+}
+
+// updateInitialsEntry takes the keys for entering initials. Letters are typed, or picked with
+// up and down, and Enter saves them.
+func (game *SpaceInvadersGame) updateInitialsEntry() {
+	entry := game.pendingInitials[0]
+
+	for _, key := range inpututil.AppendJustPressedKeys(nil) {
+		switch {
+		case key >= ebiten.KeyA && key <= ebiten.KeyZ:
+			entry.letters[entry.cursor] = byte('A' + key - ebiten.KeyA)
+			entry.cursor = min(entry.cursor+1, len(entry.letters)-1)
+		case key == ebiten.KeyArrowUp:
+			entry.letters[entry.cursor] = 'A' + (entry.letters[entry.cursor]-'A'+1)%26
+		case key == ebiten.KeyArrowDown:
+			entry.letters[entry.cursor] = 'A' + (entry.letters[entry.cursor]-'A'+25)%26
+		case key == ebiten.KeyArrowLeft || key == ebiten.KeyBackspace:
+			entry.cursor = max(entry.cursor-1, 0)
+		case key == ebiten.KeyArrowRight:
+			entry.cursor = min(entry.cursor+1, len(entry.letters)-1)
+		case key == ebiten.KeyEnter:
+			game.saveHighScore(entry)
+			game.pendingInitials = game.pendingInitials[1:]
+			return
+		}
+	}
+}
+
+// saveHighScore puts a score in the table and saves it.
+func (game *SpaceInvadersGame) saveHighScore(entry *initialsEntry) {
+	place := game.highScores.Add(highscore.Entry{
+		Initials: string(entry.letters[:]),
+		Score:    entry.score,
+		Date:     time.Now(),
+	})
+	if err := game.highScores.Save(highScoresFile); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save high scores", "path", highScoresFile, "err", err)
+		return
+	}
+	game.cpuEmulator.Logger.Info("New high score", "initials", string(entry.letters[:]), "score", entry.score, "place", place+1)
+}
+
+// drawInitialsEntry draws the box for entering initials over the game.
+func (game *SpaceInvadersGame) drawInitialsEntry(screen *ebiten.Image) {
+	entry := game.pendingInitials[0]
+	bounds := screen.Bounds()
+	width, height := 560, 260
+	x := (bounds.Dx() - width) / 2
+	y := (bounds.Dy() - height) / 2
+	vector.DrawFilledRect(screen, float32(x), float32(y), float32(width), float32(height), color.RGBA{0, 0, 0, 230}, false)
+	vector.StrokeRect(screen, float32(x), float32(y), float32(width), float32(height), 2, color.RGBA{196, 167, 231, 255}, false)
+
+	lines := []struct {
+		text  string
+		color color.Color
+	}{
+		{"NEW HIGH SCORE!", color.RGBA{196, 167, 231, 255}},
+		{fmt.Sprintf("PLAYER %d  %04d", entry.player+1, entry.score), color.RGBA{255, 255, 255, 255}},
+		{"ENTER YOUR INITIALS", color.RGBA{255, 255, 255, 255}},
+	}
+	for i, line := range lines {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(x+30), float64(y+30+i*40))
+		op.ColorScale.ScaleWithColor(line.color)
+		text.Draw(screen, line.text, loadedFont, op)
+	}
+
+	// The letter being picked is yellow and underlined
+	for i, letter := range entry.letters {
+		letterX := x + 30 + i*40
+		letterY := y + 160
+		letterColor := color.Color(color.RGBA{255, 255, 255, 255})
+		if i == entry.cursor {
+			letterColor = color.RGBA{255, 255, 0, 255}
+			vector.DrawFilledRect(screen, float32(letterX), float32(letterY+26), 20, 3, letterColor, false)
+		}
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(letterX), float64(letterY))
+		op.ColorScale.ScaleWithColor(letterColor)
+		text.Draw(screen, string(letter), loadedFont, op)
+	}
+
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(x+30), float64(y+210))
+	op.ColorScale.ScaleWithColor(color.RGBA{128, 128, 128, 255})
+	text.Draw(screen, "Type or Up/Down, Enter to save", loadedFont, op)
+}
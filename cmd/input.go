@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"net"
+
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/charmbracelet/log"
+)
+
+var (
+	inputScriptPath string
+	inputListenAddr string
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&inputScriptPath, "input-script", "", "Play the controls from a script file, as well as the keyboard and gamepads")
+	rootCmd.Flags().StringVar(&inputListenAddr, "input-listen", "", "Wait for a remote controller to connect on this TCP address, like :7700")
+}
+
+// setupInput connects the keyboard, gamepads and any scripted or remote controls to the hardware.
+func (game *SpaceInvadersGame) setupInput(logger *log.Logger) error {
+	sources := input.Combined{game.keyboard, game.gamepads, game.autoplay}
+
+	if inputScriptPath != "" {
+		script, err := input.LoadScript(inputScriptPath)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, script)
+	}
+
+	if inputListenAddr != "" {
+		listener, err := net.Listen("tcp", inputListenAddr)
+		if err != nil {
+			return err
+		}
+		logger.Info("Waiting for a remote controller", "addr", listener.Addr())
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			return err
+		}
+		logger.Info("Remote controller connected", "addr", conn.RemoteAddr())
+		sources = append(sources, input.NewRemote(conn))
+	}
+
+	game.localInput = sources
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.Input = sources
+	return nil
+}
@@ -5,52 +5,323 @@ import (
 	"fmt"
 	"image/color"
 	"os"
+	"slices"
 	"strings"
-
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/achievement"
+	"github.com/braheezy/space-invaders/internal/bookkeeping"
+	"github.com/braheezy/space-invaders/internal/cheat"
+	"github.com/braheezy/space-invaders/internal/crt"
+	"github.com/braheezy/space-invaders/internal/highscore"
+	"github.com/braheezy/space-invaders/internal/input"
 	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/stats"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
 )
 
+// minVisibleSettings is the fewest settings shown at once, no matter how small the window.
+const minVisibleSettings = 5
+
+// searchResultsShown is the most addresses the RAM search shows. Until it's been narrowed down
+// that far, there are too many to go through.
+const searchResultsShown = 20
+
+// menuPage is a page of the settings menu.
+type menuPage int
+
+const (
+	mainPage menuPage = iota
+	// controlsPage holds the key bindings
+	controlsPage
+	// gamepadsPage holds the gamepad settings and bindings
+	gamepadsPage
+	// highScoresPage shows the high score table
+	highScoresPage
+	// statsPage shows the statistics of the games played
+	statsPage
+	// achievementsPage shows the achievements, and which are unlocked
+	achievementsPage
+	// operatorPage shows the operator's books
+	operatorPage
+	// cheatsPage turns the cheats for the ROM on and off
+	cheatsPage
+	// searchPage searches RAM for addresses to freeze
+	searchPage
+)
+
+// settingPage returns the page of the menu a setting is shown on.
+func settingPage(setting Setting) menuPage {
+	switch setting := setting.(type) {
+	case *KeyBindingSetting:
+		return controlsPage
+	case *GamepadBindingSetting:
+		return gamepadsPage
+	case *ChoiceSetting:
+		return setting.page
+	case *CheatSetting:
+		return cheatsPage
+	case *SearchSetting, *SearchResultSetting:
+		return searchPage
+	}
+	return mainPage
+}
+
 type MenuScreen struct {
 	settings      []Setting
 	selectedIndex int
-	errorMessage  string
-	settingsFile  string
-	helpSection   *HelpSection
+	// scrollOffset is the index of the first setting shown when they don't all fit on screen
+	scrollOffset int
+	errorMessage string
+	settingsFile string
+	helpSection  *HelpSection
+	// page is the page of settings being shown
+	page menuPage
+	// backSetting returns to the main page from other pages
+	backSetting *PageSetting
+	// gamepads are read for buttons to bind
+	gamepads *input.Gamepads
+	// highScores is the high score table shown, if there is one
+	highScores *highscore.Table
+	// sessionStats and lifetimeStats are the statistics shown, if they're kept
+	sessionStats  *stats.Totals
+	lifetimeStats *stats.Totals
+	// achievements are the achievements shown, and unlocked when each was unlocked
+	achievements []achievement.Achievement
+	unlocked     map[string]time.Time
+	// books are the operator's books shown, if they're kept, and coinLockout whether coins
+	// are being turned away
+	books       *bookkeeping.Books
+	coinLockout bool
+	// cheats are the cheats for the ROM, and search the RAM search, if cheats can be used
+	cheats []*cheat.Cheat
+	search *cheat.Search
 }
 
 func NewMenuScreen(settingsFile string) *MenuScreen {
 	ms := &MenuScreen{
 		settingsFile: settingsFile,
+		backSetting:  &PageSetting{name: "Back", page: mainPage},
 	}
 	if err := ms.loadSettings(); err != nil {
 		ms.errorMessage = fmt.Sprintf("Error loading settings: %v", err)
 		ms.initializeDefaultSettings()
 	}
+	ms.updateHelpSection()
 	return ms
 }
 
 func (ms *MenuScreen) initializeDefaultSettings() {
 	// Clone the default settings to ms.settings
 	ms.settings = NewDefaultSettings()
+}
+
+// updateHelpSection shows the help for the current page, with the keys currently bound.
+func (ms *MenuScreen) updateHelpSection() {
+	switch ms.page {
+	case controlsPage:
+		ms.helpSection = newRebindingHelp()
+	case gamepadsPage:
+		ms.helpSection = newGamepadBindingHelp(ms.gamepads)
+	case highScoresPage:
+		ms.helpSection = newHighScoreSection(ms.highScores)
+	case statsPage:
+		ms.helpSection = newStatsSection(ms.sessionStats, ms.lifetimeStats)
+	case achievementsPage:
+		ms.helpSection = newAchievementsSection(ms.achievements, ms.unlocked)
+	case operatorPage:
+		ms.helpSection = newOperatorSection(ms.books, ms.coinLockout)
+	case cheatsPage:
+		ms.helpSection = newCheatsHelp(ms.search != nil, len(ms.cheats))
+	case searchPage:
+		ms.helpSection = newSearchHelp(ms.search)
+	default:
+		ms.helpSection = newGameControlsHelp(ms.GetBindings())
+	}
+}
+
+// visibleSettings returns the settings on the current page.
+func (ms *MenuScreen) visibleSettings() []Setting {
+	var visible []Setting
+	for _, setting := range ms.settings {
+		if settingPage(setting) == ms.page {
+			visible = append(visible, setting)
+		}
+	}
+	if ms.page != mainPage {
+		visible = append(visible, ms.backSetting)
+	}
+	return visible
+}
+
+// openPage shows another page of the menu.
+func (ms *MenuScreen) openPage(page menuPage) {
+	ms.page = page
+	ms.selectedIndex = 0
+	ms.scrollOffset = 0
+	ms.updateHelpSection()
+}
+
+// Capturing reports whether the menu is waiting for a key or button to bind.
+func (ms *MenuScreen) Capturing() bool {
+	for _, setting := range ms.settings {
+		switch setting := setting.(type) {
+		case *KeyBindingSetting:
+			if setting.capturing {
+				return true
+			}
+		case *GamepadBindingSetting:
+			if setting.capturing {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SetGamepads gives the menu the gamepads to read buttons to bind from.
+func (ms *MenuScreen) SetGamepads(gamepads *input.Gamepads) {
+	ms.gamepads = gamepads
+	ms.updateHelpSection()
+}
+
+// SetAchievements gives the menu the achievements to show, and when each was unlocked.
+func (ms *MenuScreen) SetAchievements(achievements []achievement.Achievement, unlocked map[string]time.Time) {
+	ms.achievements = achievements
+	ms.unlocked = unlocked
+}
+
+// SetBooks gives the menu the operator's books to show, and whether coins are locked out.
+func (ms *MenuScreen) SetBooks(books bookkeeping.Books, coinLockout bool) {
+	ms.books = &books
+	ms.coinLockout = coinLockout
+}
+
+// SetCheats gives the menu the cheats to turn on and off, and the RAM search to narrow down.
+func (ms *MenuScreen) SetCheats(cheats []*cheat.Cheat, search *cheat.Search) {
+	ms.cheats = cheats
+	ms.search = search
+	ms.updateCheatSettings()
+}
+
+// GetCheats returns the cheats, with any added or removed in the menu.
+func (ms *MenuScreen) GetCheats() []*cheat.Cheat {
+	return ms.cheats
+}
+
+// updateCheatSettings replaces the settings for the cheats and the RAM search after they
+// change, keeping the value being searched for.
+func (ms *MenuScreen) updateCheatSettings() {
+	value := byte(0)
+	for _, setting := range ms.settings {
+		if setting, ok := setting.(*SearchSetting); ok && setting.comparison == cheat.Equal && !setting.reset {
+			value = setting.value
+		}
+	}
+	ms.settings = slices.DeleteFunc(ms.settings, func(setting Setting) bool {
+		switch setting.(type) {
+		case *CheatSetting, *SearchSetting, *SearchResultSetting:
+			return true
+		}
+		return false
+	})
+	if ms.search == nil {
+		return
+	}
 
-	// Initialize the help section separately
-	ms.helpSection = &HelpSection{
-		name: "Game Controls",
-		controls: []string{
-			"Arrow Keys/WASD - Move, Navigate menu",
-			"Space - Shoot",
-			"C - Insert credit",
-			"1 - Player 1 Start",
-			"2 - Player 2 Start",
-			"T - Tilt",
-			"Enter - Toggle setting",
-			"Tab - Toggle menu",
-			"Esc - Quit",
-		},
+	frozen := map[string]bool{}
+	for _, c := range ms.cheats {
+		ms.settings = append(ms.settings, &CheatSetting{cheat: c})
+		frozen[c.Name] = true
 	}
+	ms.settings = append(ms.settings, &SearchSetting{name: "New search", reset: true})
+	for comparison, name := range cheat.ComparisonNames {
+		ms.settings = append(ms.settings, &SearchSetting{name: name, comparison: cheat.Comparison(comparison), value: value})
+	}
+	if ms.search.Count() <= searchResultsShown {
+		for _, result := range ms.search.Results(searchResultsShown) {
+			ms.settings = append(ms.settings, &SearchResultSetting{result: result, frozen: frozen[cheat.Freeze(result.Address).Name]})
+		}
+	}
+	ms.updateHelpSection()
+}
+
+// SetStats gives the menu the statistics to show, of this session and over all of them.
+func (ms *MenuScreen) SetStats(session, lifetime stats.Totals) {
+	ms.sessionStats = &session
+	ms.lifetimeStats = &lifetime
+}
+
+// SetHighScores gives the menu the high score table to show.
+func (ms *MenuScreen) SetHighScores(table *highscore.Table) {
+	ms.highScores = table
+	ms.updateHelpSection()
+}
+
+// GetBindings returns the keys bound to each of the cabinet's controls.
+func (ms *MenuScreen) GetBindings() input.Bindings {
+	bindings := input.DefaultBindings()
+	for _, setting := range ms.settings {
+		if keyBinding, ok := setting.(*KeyBindingSetting); ok {
+			bindings[keyBinding.action] = keyBinding.keys
+		}
+	}
+	return bindings
+}
+
+// GetGamepadBindings returns the gamepad buttons bound to each of the cabinet's controls.
+func (ms *MenuScreen) GetGamepadBindings() input.GamepadBindings {
+	bindings := input.DefaultGamepadBindings()
+	for _, setting := range ms.settings {
+		if gamepadBinding, ok := setting.(*GamepadBindingSetting); ok {
+			bindings[gamepadBinding.action] = gamepadBinding.buttons
+		}
+	}
+	return bindings
+}
+
+// GetGamepadSlot returns which gamepad a player uses, or input.NoGamepad.
+func (ms *MenuScreen) GetGamepadSlot(player int) int {
+	name := gamepadSlotSettingName(player)
+	for _, setting := range ms.settings {
+		if choiceSetting, ok := setting.(*ChoiceSetting); ok && choiceSetting.name == name {
+			return input.GamepadSlot(choiceSetting.value)
+		}
+	}
+	return player
+}
+
+// GetAutoplay returns whether the bot plays for a player.
+func (ms *MenuScreen) GetAutoplay(player int) bool {
+	name := autoplaySettingName(player)
+	for _, setting := range ms.settings {
+		if onOffSetting, ok := setting.(*OnOffSetting); ok && onOffSetting.name == name {
+			return onOffSetting.value
+		}
+	}
+	return false
+}
+
+// GetAutoplayStartsGames returns whether the bot starts games when none is being played.
+func (ms *MenuScreen) GetAutoplayStartsGames() bool {
+	for _, setting := range ms.settings {
+		if onOffSetting, ok := setting.(*OnOffSetting); ok && onOffSetting.name == "Autoplay starts games" {
+			return onOffSetting.value
+		}
+	}
+	return false
+}
+
+// GetStickDeadzone returns how far a stick must be pushed to count, from 0 to 1.
+func (ms *MenuScreen) GetStickDeadzone() float64 {
+	for _, setting := range ms.settings {
+		if choiceSetting, ok := setting.(*ChoiceSetting); ok && choiceSetting.name == "Stick deadzone" {
+			return float64(choiceSetting.value+1) * deadzoneStep
+		}
+	}
+	return input.DefaultDeadzone
 }
 
 func (ms *MenuScreen) GetLimitTPS() bool {
@@ -89,6 +360,38 @@ func (ms *MenuScreen) GetShowCoinInfoOnDemo() bool {
 	return true
 }
 
+// GetPractice returns the wave, lives and score practice games start with. The wave is 0 when
+// practice is off.
+func (ms *MenuScreen) GetPractice() invaders.Practice {
+	practice := invaders.Practice{Lives: 3}
+	for _, setting := range ms.settings {
+		switch setting := setting.(type) {
+		case *ChoiceSetting:
+			switch setting.name {
+			case "Practice from wave":
+				practice.Wave = setting.value
+			case "Practice score":
+				practice.Score = setting.value * practiceScoreStep
+			}
+		case *RangeSetting:
+			if setting.name == "Practice lives" {
+				practice.Lives = setting.value
+			}
+		}
+	}
+	return practice
+}
+
+// GetFreePlay returns whether games are started without coins.
+func (ms *MenuScreen) GetFreePlay() bool {
+	for _, setting := range ms.settings {
+		if onOffSetting, ok := setting.(*OnOffSetting); ok && onOffSetting.name == "Free play" {
+			return onOffSetting.value
+		}
+	}
+	return false
+}
+
 func (ms *MenuScreen) GetColorScheme() invaders.ColorScheme {
 	for _, setting := range ms.settings {
 		if colorSchemeSetting, ok := setting.(*ColorSchemeSetting); ok && colorSchemeSetting.name == "Color scheme" {
@@ -98,6 +401,61 @@ func (ms *MenuScreen) GetColorScheme() invaders.ColorScheme {
 	return invaders.BlackAndWhite
 }
 
+func (ms *MenuScreen) GetSoundMode() invaders.SoundMode {
+	for _, setting := range ms.settings {
+		if choiceSetting, ok := setting.(*ChoiceSetting); ok && choiceSetting.name == "Sound" {
+			return invaders.SoundMode(choiceSetting.value)
+		}
+	}
+	return invaders.SampledSound
+}
+
+func (ms *MenuScreen) GetCabinetType() invaders.CabinetType {
+	for _, setting := range ms.settings {
+		if choiceSetting, ok := setting.(*ChoiceSetting); ok && choiceSetting.name == "Cabinet" {
+			return invaders.CabinetType(choiceSetting.value)
+		}
+	}
+	return invaders.Upright
+}
+
+func (ms *MenuScreen) GetCRTPreset() crt.Preset {
+	for _, setting := range ms.settings {
+		if choiceSetting, ok := setting.(*ChoiceSetting); ok && choiceSetting.name == "CRT effect" {
+			return crt.Preset(choiceSetting.value)
+		}
+	}
+	return crt.Off
+}
+
+func (ms *MenuScreen) GetMasterVolume() float64 {
+	for _, setting := range ms.settings {
+		if volumeSetting, ok := setting.(*VolumeSetting); ok && volumeSetting.name == "Master volume" {
+			return volumeSetting.Fraction()
+		}
+	}
+	return 1
+}
+
+func (ms *MenuScreen) GetDucking() bool {
+	for _, setting := range ms.settings {
+		if onOffSetting, ok := setting.(*OnOffSetting); ok && onOffSetting.name == "Duck sounds when player dies" {
+			return onOffSetting.value
+		}
+	}
+	return true
+}
+
+func (ms *MenuScreen) GetEffectVolume(effect invaders.SoundEffect) float64 {
+	name := effectVolumeSettingName(invaders.SoundEffectNames[effect])
+	for _, setting := range ms.settings {
+		if volumeSetting, ok := setting.(*VolumeSetting); ok && volumeSetting.name == name {
+			return volumeSetting.Fraction()
+		}
+	}
+	return 1
+}
+
 func (ms *MenuScreen) loadSettings() error {
 	file, err := os.Open(ms.settingsFile)
 	if os.IsNotExist(err) {
@@ -162,24 +520,69 @@ func (ms *MenuScreen) saveSettings() error {
 }
 
 func (ms *MenuScreen) Update() {
+	settings := ms.visibleSettings()
+
+	// Keep the count of connected gamepads current
+	if ms.page == gamepadsPage {
+		ms.updateHelpSection()
+	}
+
+	// While waiting for a key to bind, every key goes to the binding
+	if keyBinding, ok := settings[ms.selectedIndex].(*KeyBindingSetting); ok && keyBinding.capturing {
+		for _, key := range inpututil.AppendJustPressedKeys(nil) {
+			// Escape and Tab are kept for quitting and closing the menu
+			if key == ebiten.KeyEscape || key == ebiten.KeyTab {
+				continue
+			}
+			keyBinding.keys = input.ToggleKey(keyBinding.keys, key)
+			keyBinding.capturing = false
+			ms.updateHelpSection()
+			break
+		}
+		return
+	}
+
+	// While waiting for a gamepad button to bind, Backspace cancels
+	if gamepadBinding, ok := settings[ms.selectedIndex].(*GamepadBindingSetting); ok && gamepadBinding.capturing {
+		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+			gamepadBinding.capturing = false
+		} else if ms.gamepads != nil {
+			if button, ok := ms.gamepads.JustPressedButton(); ok {
+				gamepadBinding.buttons = input.ToggleButton(gamepadBinding.buttons, button)
+				gamepadBinding.capturing = false
+			}
+		}
+		return
+	}
+
 	if inpututil.IsKeyJustPressed(ebiten.KeyArrowDown) || inpututil.IsKeyJustPressed(ebiten.KeyS) {
 		// Wrap around to the first setting
-		ms.selectedIndex = (ms.selectedIndex + 1) % len(ms.settings)
+		ms.selectedIndex = (ms.selectedIndex + 1) % len(settings)
 	} else if inpututil.IsKeyJustPressed(ebiten.KeyArrowUp) || inpututil.IsKeyJustPressed(ebiten.KeyW) {
 		ms.selectedIndex--
 		if ms.selectedIndex < 0 {
 			// Wrap around to the last setting
-			ms.selectedIndex = len(ms.settings) - 1
+			ms.selectedIndex = len(settings) - 1
 		}
 	}
 
 	if inpututil.IsKeyJustPressed(ebiten.KeyEnter) {
 		ms.toggleSelectedSetting()
+		return
 	}
 
 	// Handle left/right arrow keys for range and color scheme settings
-	selectedSetting := ms.settings[ms.selectedIndex]
+	selectedSetting := settings[ms.selectedIndex]
 	switch setting := selectedSetting.(type) {
+	case *KeyBindingSetting:
+		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+			setting.keys = nil
+			ms.updateHelpSection()
+		}
+	case *GamepadBindingSetting:
+		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) {
+			setting.buttons = nil
+		}
 	case *RangeSetting:
 		if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyA) {
 			if setting.value > setting.minVal {
@@ -200,11 +603,46 @@ func (ms *MenuScreen) Update() {
 			// Increase and wrap around
 			setting.SetValue(invaders.ColorScheme(newScheme))
 		}
+	case *ChoiceSetting:
+		if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyA) {
+			setting.previous()
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || inpututil.IsKeyJustPressed(ebiten.KeyD) {
+			setting.next()
+		}
+	case *VolumeSetting:
+		if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyA) {
+			setting.decrease()
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || inpututil.IsKeyJustPressed(ebiten.KeyD) {
+			setting.increase()
+		}
+	case *SearchSetting:
+		// Only an exact search has a value. It wraps around, like the byte it's compared with.
+		if setting.reset || setting.comparison != cheat.Equal {
+			break
+		}
+		if inpututil.IsKeyJustPressed(ebiten.KeyArrowLeft) || inpututil.IsKeyJustPressed(ebiten.KeyA) {
+			setting.value--
+		} else if inpututil.IsKeyJustPressed(ebiten.KeyArrowRight) || inpututil.IsKeyJustPressed(ebiten.KeyD) {
+			setting.value++
+		}
+	case *CheatSetting:
+		// Only cheats that are off are removed, so nothing they've patched is left behind
+		if inpututil.IsKeyJustPressed(ebiten.KeyBackspace) && !setting.cheat.Enabled {
+			ms.cheats = slices.DeleteFunc(ms.cheats, func(c *cheat.Cheat) bool { return c == setting.cheat })
+			ms.updateCheatSettings()
+			ms.selectedIndex = min(ms.selectedIndex, len(ms.visibleSettings())-1)
+		}
 	}
 }
 func (ms *MenuScreen) toggleSelectedSetting() {
-	selectedSetting := ms.settings[ms.selectedIndex]
+	selectedSetting := ms.visibleSettings()[ms.selectedIndex]
 	switch setting := selectedSetting.(type) {
+	case *PageSetting:
+		ms.openPage(setting.page)
+	case *KeyBindingSetting:
+		setting.capturing = true
+	case *GamepadBindingSetting:
+		setting.capturing = true
 	case *OnOffSetting:
 		if ebiten.IsKeyPressed(ebiten.KeyEnter) {
 			setting.SetValue(!setting.value)
@@ -220,12 +658,55 @@ func (ms *MenuScreen) toggleSelectedSetting() {
 		// Cycle through the color schemes
 		newScheme := (int(setting.value) + 1) % len(invaders.ColorSchemeNames)
 		setting.SetValue(invaders.ColorScheme(newScheme))
+	case *ChoiceSetting:
+		setting.next()
+	case *VolumeSetting:
+		// Cycle through the volumes
+		if setting.value == 100 {
+			setting.SetValue(0)
+		} else {
+			setting.increase()
+		}
+	case *CheatSetting:
+		setting.cheat.Enabled = !setting.cheat.Enabled
+	case *SearchSetting:
+		if setting.reset {
+			ms.search.Reset()
+		} else {
+			ms.search.Compare(setting.comparison, setting.value)
+		}
+		ms.updateCheatSettings()
+	case *SearchResultSetting:
+		if !setting.frozen {
+			ms.cheats = append(ms.cheats, cheat.Freeze(setting.result.Address))
+			ms.updateCheatSettings()
+		}
 	}
 }
 
 func (ms *MenuScreen) Draw(screen *ebiten.Image) {
+	settings := ms.visibleSettings()
+
 	// Render the overall menu title
 	menuTitle := "Settings Menu"
+	switch ms.page {
+	case controlsPage:
+		menuTitle = "Settings Menu - Controls"
+	case gamepadsPage:
+		menuTitle = "Settings Menu - Gamepads"
+	case highScoresPage:
+		menuTitle = "Settings Menu - High Scores"
+	case statsPage:
+		menuTitle = "Settings Menu - Statistics"
+	case achievementsPage:
+		menuTitle = "Settings Menu - Achievements"
+	case operatorPage:
+		menuTitle = "Settings Menu - Operator"
+	case cheatsPage:
+		menuTitle = "Settings Menu - Cheats"
+	case searchPage:
+		menuTitle = "Settings Menu - RAM Search"
+	}
 	titleOp := &text.DrawOptions{}
 	// Position at the top of the screen
 	titleOp.GeoM.Translate(float64(50), float64(20))
@@ -238,18 +719,48 @@ func (ms *MenuScreen) Draw(screen *ebiten.Image) {
 	startY := 70
 	lineHeight := 30
 
-	// Iterate through each setting and draw it
-	for i, setting := range ms.settings {
+	// Show as many settings as fit above the help section, scrolling to keep
+	// the selected setting in view
+	visibleRows := len(settings)
+	if ms.helpSection != nil {
+		available := screen.Bounds().Dy() - startY - 50 - ms.helpSection.Height()
+		visibleRows = max(min(available/lineHeight, len(settings)), minVisibleSettings)
+	}
+	if ms.selectedIndex < ms.scrollOffset {
+		ms.scrollOffset = ms.selectedIndex
+	} else if ms.selectedIndex >= ms.scrollOffset+visibleRows {
+		ms.scrollOffset = ms.selectedIndex - visibleRows + 1
+	}
+	ms.scrollOffset = max(min(ms.scrollOffset, len(settings)-visibleRows), 0)
+
+	// Iterate through each visible setting and draw it
+	for row := 0; row < visibleRows && ms.scrollOffset+row < len(settings); row++ {
+		i := ms.scrollOffset + row
 		// Calculate the Y position for each setting
-		y := startY + (i * lineHeight)
+		y := startY + (row * lineHeight)
 		selected := i == ms.selectedIndex
-		setting.Render(screen, startX, y, selected)
+		settings[i].Render(screen, startX, y, selected)
+	}
+
+	// Hint that there are more settings above or below
+	moreColor := color.RGBA{128, 128, 128, 255}
+	if ms.scrollOffset > 0 {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(startX), float64(startY-lineHeight/2-6))
+		op.ColorScale.ScaleWithColor(moreColor)
+		text.Draw(screen, "...", loadedFont, op)
+	}
+	if ms.scrollOffset+visibleRows < len(settings) {
+		op := &text.DrawOptions{}
+		op.GeoM.Translate(float64(startX), float64(startY+visibleRows*lineHeight))
+		op.ColorScale.ScaleWithColor(moreColor)
+		text.Draw(screen, "...", loadedFont, op)
 	}
 
 	// Draw the help section below the settings
 	if ms.helpSection != nil {
 		// Space between settings and help section
-		y := startY + (len(ms.settings) * lineHeight) + 50
+		y := startY + (visibleRows * lineHeight) + 50
 		ms.helpSection.Render(screen, startX, y, false)
 	}
 
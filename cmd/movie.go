@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+var (
+	recordMoviePath string
+	playMoviePath   string
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&recordMoviePath, "record-movie", "", "Record the controls from power on to a movie file")
+	rootCmd.Flags().StringVar(&playMoviePath, "play-movie", "", "Play back a movie file recorded with --record-movie")
+	rootCmd.MarkFlagsMutuallyExclusive("record-movie", "play-movie")
+}
+
+// setupMovie starts recording or playing back a movie, if asked to. It must be called before
+// the first frame is emulated, since movies start from power on.
+func (game *SpaceInvadersGame) setupMovie() error {
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	romHash := movie.HashROM(hardware.ROM())
+
+	if recordMoviePath != "" {
+		header := movie.Header{ROMHash: romHash, Settings: replay.CurrentSettings(game.cpuEmulator, hardware)}
+		recorder, err := movie.Create(recordMoviePath, header, hardware.Input)
+		if err != nil {
+			return err
+		}
+		hardware.Input = recorder
+		game.movieRecorder = recorder
+		game.cpuEmulator.Logger.Info("Recording movie", "path", recordMoviePath)
+	}
+
+	if playMoviePath != "" {
+		m, err := movie.Load(playMoviePath)
+		if err != nil {
+			return err
+		}
+		if m.Header.ROMHash != romHash {
+			return fmt.Errorf("movie was recorded with a different ROM (SHA-1 %s, this is %s)", m.Header.ROMHash, romHash)
+		}
+		if err := replay.ApplySettings(game.cpuEmulator, hardware, m.Header.Settings); err != nil {
+			return err
+		}
+		game.moviePlayer = movie.NewPlayer(m)
+		hardware.Input = game.moviePlayer
+		game.cpuEmulator.Logger.Info("Playing movie", "path", playMoviePath, "frames", m.Frames())
+	}
+	return nil
+}
+
+// endMovieFrame records or checks the RAM after an emulated frame, and hands the controls
+// back to the player when a movie finishes playing.
+func (game *SpaceInvadersGame) endMovieFrame() {
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	if game.movieRecorder != nil {
+		if err := game.movieRecorder.EndFrame(hardware.RAM()); err != nil {
+			game.cpuEmulator.Logger.Error("Failed to record movie", "err", err)
+			game.stopMovieRecording()
+		}
+	}
+
+	if game.moviePlayer != nil {
+		var desync *movie.DesyncError
+		if err := game.moviePlayer.EndFrame(hardware.RAM()); errors.As(err, &desync) && !game.movieDesynced {
+			// Only the first desync matters, everything after follows from it
+			game.movieDesynced = true
+			game.cpuEmulator.Logger.Error("Movie playback desynced", "frame", desync.Frame, "err", err)
+		}
+		if game.moviePlayer.Done() {
+			game.cpuEmulator.Logger.Info("Movie finished", "frames", game.moviePlayer.Frames(), "desynced", game.movieDesynced)
+			hardware.Input = game.localInput
+			game.moviePlayer = nil
+		}
+	}
+}
+
+// stopMovieRecording finishes the movie being recorded, if any.
+func (game *SpaceInvadersGame) stopMovieRecording() {
+	if game.movieRecorder == nil {
+		return
+	}
+
+	frames := game.movieRecorder.Frames()
+	if err := game.movieRecorder.Close(); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save movie", "err", err)
+	} else {
+		game.cpuEmulator.Logger.Info("Saved movie", "path", recordMoviePath, "frames", frames)
+	}
+	game.movieRecorder = nil
+}
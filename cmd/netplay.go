@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/netplay"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/charmbracelet/log"
+)
+
+var (
+	netplayHostAddr string
+	netplayJoinAddr string
+	netplayDelay    int
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&netplayHostAddr, "netplay-host", "", "Host a netplay game as player 1, waiting for player 2 to connect on this TCP address, like :7800")
+	rootCmd.Flags().StringVar(&netplayJoinAddr, "netplay-join", "", "Join a netplay game as player 2 at this TCP address, like localhost:7800")
+	rootCmd.Flags().IntVar(&netplayDelay, "netplay-delay", netplay.DefaultInputDelay, "Frames to hold back your controls in netplay, fewer rollbacks for a little lag")
+	rootCmd.MarkFlagsMutuallyExclusive("netplay-host", "netplay-join")
+	rootCmd.MarkFlagsMutuallyExclusive("netplay-host", "record-movie", "play-movie")
+	rootCmd.MarkFlagsMutuallyExclusive("netplay-join", "record-movie", "play-movie")
+}
+
+// setupNetplay hosts or joins a netplay game, if asked to. It must be called before the first
+// frame is emulated, since both sides start from power on.
+func (game *SpaceInvadersGame) setupNetplay(logger *log.Logger) error {
+	if netplayHostAddr == "" && netplayJoinAddr == "" {
+		return nil
+	}
+
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	config := netplay.Config{
+		InputDelay: netplayDelay,
+		Settings:   replay.CurrentSettings(game.cpuEmulator, hardware),
+	}
+
+	var session *netplay.Session
+	var err error
+	if netplayHostAddr != "" {
+		logger.Info("Waiting for player 2 to join", "addr", netplayHostAddr)
+		session, err = netplay.Host(netplayHostAddr, game.cpuEmulator, hardware, game.localInput, config)
+	} else {
+		logger.Info("Joining netplay game", "addr", netplayJoinAddr)
+		session, err = netplay.Join(netplayJoinAddr, game.cpuEmulator, hardware, game.localInput, config)
+	}
+	if err != nil {
+		return err
+	}
+
+	game.netplay = session
+	logger.Info("Netplay started", "player", session.Player()+1, "settings", session.Settings())
+	return nil
+}
+
+// updateNetplay runs the netplay game for a frame. If the connection drops or the games stop
+// matching, the game carries on locally.
+func (game *SpaceInvadersGame) updateNetplay() {
+	err := game.netplay.Update()
+	if err == nil {
+		return
+	}
+
+	game.cpuEmulator.Logger.Error("Netplay stopped", "frame", game.netplay.Frame(), "rollbacks", game.netplay.Rollbacks, "err", err)
+	game.stopNetplay()
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.Input = game.localInput
+	game.applySettings()
+}
+
+// stopNetplay ends the netplay game, if any.
+func (game *SpaceInvadersGame) stopNetplay() {
+	if game.netplay == nil {
+		return
+	}
+	game.netplay.Close()
+	game.netplay = nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/charmbracelet/log"
+)
+
+var patchPaths []string
+
+func init() {
+	rootCmd.Flags().StringArrayVar(&patchPaths, "patch", nil, "IPS or BPS patch to apply to the ROM; repeat to apply several in order")
+}
+
+// applyPatches applies the patches to the ROM in the order given, before the emulator copies
+// it into memory, and reports the hash of the ROM they make. Movies, netplay, spectating and
+// cheats all go by that hash, so they keep to the patched ROM.
+func applyPatches(hardware *invaders.SpaceInvadersHardware, logger *log.Logger) error {
+	if len(patchPaths) == 0 {
+		return nil
+	}
+	for _, path := range patchPaths {
+		if err := hardware.LoadPatch(path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		logger.Info("Applied patch", "path", path)
+	}
+	logger.Info("Patched ROM", "sha1", movie.HashROM(hardware.ROM()), "size", len(hardware.ROM()))
+	return nil
+}
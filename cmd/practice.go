@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/text/v2"
+)
+
+// practiceWaveNames are the choices for the wave practice games start on, where the first
+// turns practice off.
+var practiceWaveNames = func() []string {
+	names := []string{"Off"}
+	for wave := 1; wave <= gamestate.MaxWave; wave++ {
+		names = append(names, fmt.Sprint(wave))
+	}
+	return names
+}()
+
+// practiceScoreStep is how far apart the choices for the score practice games start with are.
+const practiceScoreStep = 500
+
+// practiceScoreNames are the choices for the score practice games start with.
+var practiceScoreNames = func() []string {
+	var names []string
+	for score := 0; score <= gamestate.MaxScore; score += practiceScoreStep {
+		names = append(names, fmt.Sprint(score))
+	}
+	return names
+}()
+
+// drawPracticeLabel marks a practice game being played, so it isn't mistaken for a real one.
+func (game *SpaceInvadersGame) drawPracticeLabel(screen *ebiten.Image) {
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	if !hardware.Practicing() || !gamestate.Playing(hardware.RAM()) {
+		return
+	}
+	op := &text.DrawOptions{}
+	op.GeoM.Translate(float64(screen.Bounds().Dx()-180), float64(screen.Bounds().Dy()-40))
+	op.ColorScale.ScaleWithColor(color.RGBA{255, 255, 0, 255})
+	text.Draw(screen, "PRACTICE", loadedFont, op)
+}
@@ -4,17 +4,36 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/braheezy/space-invaders/internal/achievement"
+	"github.com/braheezy/space-invaders/internal/autoplay"
+	"github.com/braheezy/space-invaders/internal/bookkeeping"
+	"github.com/braheezy/space-invaders/internal/capture"
+	"github.com/braheezy/space-invaders/internal/cheat"
 	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/highscore"
+	"github.com/braheezy/space-invaders/internal/input"
 	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/netplay"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/braheezy/space-invaders/internal/spectate"
+	"github.com/braheezy/space-invaders/internal/stats"
 	"github.com/charmbracelet/log"
 	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/spf13/cobra"
 )
 
-var debug bool
+var (
+	debug        bool
+	backdropPath string
+	bezelPath    string
+)
 
 func init() {
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Show debug messages")
+	rootCmd.Flags().StringVar(&backdropPath, "backdrop", "", "Image to use as the backdrop artwork in the Cabinet color scheme")
+	rootCmd.Flags().StringVar(&bezelPath, "bezel", "", "Image to draw over the screen in the Cabinet color scheme")
 }
 
 func Execute() {
@@ -31,25 +50,65 @@ var rootCmd = &cobra.Command{
 		if debug {
 			logger.SetLevel(log.DebugLevel)
 		}
+		if err := checkAutoplayFlags(); err != nil {
+			logger.Fatal("Invalid flags", "err", err)
+		}
 
 		invadersHardware := invaders.NewSpaceInvadersHardware()
+		if backdropPath != "" {
+			if err := invadersHardware.LoadBackdrop(backdropPath); err != nil {
+				logger.Fatal("Failed to load backdrop", "err", err)
+			}
+		}
+		if bezelPath != "" {
+			if err := invadersHardware.LoadBezel(bezelPath); err != nil {
+				logger.Fatal("Failed to load bezel", "err", err)
+			}
+		}
+		if err := applyPatches(invadersHardware, logger); err != nil {
+			logger.Fatal("Failed to patch the ROM", "err", err)
+		}
 
 		vm := emulator.NewEmulator(invadersHardware)
-		vm.StartInterruptRoutines()
+		// Interrupts are timed by the CPU, so the same controls always play the same game
+		vm.ScheduleInterrupts()
 		vm.Logger = logger
 
 		game := NewSpaceInvadersGame(vm)
-		vm.Options.LimitTPS = game.menuScreen.GetLimitTPS()
-
-		hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
-		hardware.ShipsSetting = game.menuScreen.GetShipsSetting()
-		hardware.ExtraShipAt1000 = game.menuScreen.GetExtraShipAt1000()
-		// Coin info displayed in demo screen 0=ON
-		hardware.ShowCoinInfoOnDemo = !game.menuScreen.GetShowCoinInfoOnDemo()
-		hardware.ColorScheme = game.menuScreen.GetColorScheme()
+		game.applySettings()
+		if err := game.setupInput(logger); err != nil {
+			logger.Fatal("Failed to set up input", "err", err)
+		}
+		if err := game.setupMovie(); err != nil {
+			logger.Fatal("Failed to set up movie", "err", err)
+		}
+		if err := game.setupNetplay(logger); err != nil {
+			logger.Fatal("Failed to start netplay", "err", err)
+		}
+		if err := game.setupSpectating(logger); err != nil {
+			logger.Fatal("Failed to set up spectating", "err", err)
+		}
+		game.setupHighScores(logger)
+		game.setupStats(logger)
+		game.setupBookkeeping(logger)
+		game.setupCheats(logger)
+		if err := game.setupAchievements(logger); err != nil {
+			logger.Fatal("Failed to load achievements", "err", err)
+		}
+		if recordAudio {
+			if err := game.startAudioRecording(); err != nil {
+				logger.Fatal("Failed to start audio recording", "err", err)
+			}
+		}
+		if recordVideo {
+			if err := game.startVideoRecording(); err != nil {
+				logger.Fatal("Failed to start video recording", "err", err)
+			}
+		}
 
 		ebiten.SetWindowTitle("space invaders")
-		if vm.Options.LimitTPS {
+		// Netplay and spectating run at the arcade's speed so both sides keep up with each other
+		if vm.Options.LimitTPS || game.netplay != nil || game.spectating != nil {
 			ebiten.SetTPS(60)
 		} else {
 			ebiten.SetTPS(ebiten.SyncWithFPS)
@@ -57,9 +116,21 @@ var rootCmd = &cobra.Command{
 		ebiten.SetWindowSize(vm.Hardware.Width()*vm.Hardware.Scale(), vm.Hardware.Height()*vm.Hardware.Scale())
 
 		if err := ebiten.RunGame(game); err != nil && err != ebiten.Termination {
+			game.stopRecordings()
+			game.stopNetplay()
+			game.stopSpectating()
+			game.saveStats()
+			game.saveAchievements()
+			game.saveBookkeeping()
 			game.cpuEmulator.Hardware.Cleanup()
 			logger.Fatal(err)
 		}
+		game.stopRecordings()
+		game.stopNetplay()
+		game.stopSpectating()
+		game.saveStats()
+		game.saveAchievements()
+		game.saveBookkeeping()
 		game.cpuEmulator.Hardware.Cleanup()
 	},
 	CompletionOptions: cobra.CompletionOptions{
@@ -74,14 +145,73 @@ type SpaceInvadersGame struct {
 	inSettingsMenu bool
 	menuScreen     *MenuScreen
 	tabPressed     bool
+	// audioRecorder is the audio recording in progress, if any
+	audioRecorder *capture.AudioRecorder
+	// videoRecorder is the video recording in progress, if any
+	videoRecorder *capture.VideoRecorder
+	// keyboard and gamepads are the local controls
+	keyboard *input.Keyboard
+	gamepads *input.Gamepads
+	// localInput combines the controls of the players at this machine
+	localInput input.Source
+	// autoplay is the bot, playing for the players it's been picked for
+	autoplay *autoplay.Bot
+	// movieRecorder is the movie being recorded, if any
+	movieRecorder *movie.Recorder
+	// moviePlayer is the movie being played back, if any
+	moviePlayer *movie.Player
+	// movieDesynced is set once the movie being played back stops matching the game
+	movieDesynced bool
+	// netplay is the netplay game being played, if any. It runs the emulator instead.
+	netplay *netplay.Session
+	// spectatorServer streams the game to spectators, if serving any
+	spectatorServer *spectate.Server
+	// spectating is the game being watched, if any. It runs the emulator instead.
+	spectating *spectate.Spectator
+	// spectatingDesynced is set once the game being watched stops matching the host's
+	spectatingDesynced bool
+	// highScores is the high score table, unless this game can't set high scores
+	highScores *highscore.Table
+	// pendingInitials are the new high scores waiting for initials, first one first
+	pendingInitials []*initialsEntry
+	// stats keeps the statistics of this session, unless this game doesn't count
+	stats *stats.Tracker
+	// statsRecord is the statistics of the sessions before this one
+	statsRecord *stats.Record
+	// achievements checks the achievements each frame, unless this game doesn't count
+	achievements        *achievement.Tracker
+	achievementDefs     *achievement.Definitions
+	achievementProgress *achievement.Progress
+	// toasts are the achievements unlocked waiting to be shown, the first one showing for
+	// toastFrames frames so far
+	toasts      []achievement.Achievement
+	toastFrames int
+	// bookkeeping keeps the operator's books, unless this game wasn't paid for here
+	bookkeeping *bookkeeping.Meter
+	// cheats are the cheats for the ROM, hashed as romHash, and cheatFile those for every
+	// ROM, unless this game can't be cheated
+	cheats    []*cheat.Cheat
+	cheatFile *cheat.File
+	romHash   string
+	// cheatSearch is the RAM search, kept between visits to the menu
+	cheatSearch *cheat.Search
+	// cheated is set once a cheat is on in the game being played, or the last one if none
+	// is, and cheatsPlaying whether a game was being played after cheatsFrame, the last frame
+	cheated       bool
+	cheatsPlaying bool
+	cheatsFrame   int
 }
 
 // NewSpaceInvadersGame creates a new SpaceInvadersGame instance
 func NewSpaceInvadersGame(cpuEmulator *emulator.CPU8080) *SpaceInvadersGame {
+	hardware := cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
 	return &SpaceInvadersGame{
 		cpuEmulator:    cpuEmulator,
 		inSettingsMenu: false,
 		menuScreen:     NewMenuScreen("settings.json"),
+		keyboard:       input.NewKeyboard(),
+		gamepads:       input.NewGamepads(),
+		autoplay:       autoplay.New(hardware.RAM()),
 	}
 }
 
@@ -104,17 +234,72 @@ func (game *SpaceInvadersGame) Update() error {
 		game.tabPressed = false
 	}
 
+	// Keep track of gamepads being plugged in and unplugged
+	game.gamepads.Update()
+
+	// Keys being bound in the menu or typed as initials aren't hotkeys
+	if !game.enteringInitials() && (!game.inSettingsMenu || !game.menuScreen.Capturing()) {
+		game.handleHotkeys()
+	}
+
 	if game.inSettingsMenu {
 		// Update menu logic
 		game.menuScreen.Update()
+	} else if game.enteringInitials() {
+		game.updateInitialsEntry()
 	} else {
 		// Run the CPU emulator
-		game.cpuEmulator.Update()
+		frame := game.cpuEmulator.FrameCount()
+		switch {
+		case game.spectating != nil:
+			game.updateSpectating()
+		case game.netplay != nil:
+			game.updateNetplay()
+		default:
+			game.cpuEmulator.Update()
+		}
+		// The rest looks at the frame just emulated. There's none while netplay or spectating
+		// waits for the other side.
+		if game.cpuEmulator.FrameCount() == frame {
+			return nil
+		}
+		game.endMovieFrame()
+		game.endSpectatorFrame()
+		game.updateCheats()
+		game.checkGameOver()
+		game.updateStats()
+		game.updateAchievements()
+		game.updateBookkeeping()
+		game.endCaptureFrame()
 	}
 
 	return nil
 }
 
+// handleHotkeys handles the keys that work both in game and in the menu.
+func (game *SpaceInvadersGame) handleHotkeys() {
+	// Handle M key press to toggle mute
+	if inpututil.IsKeyJustPressed(ebiten.KeyM) {
+		hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+		hardware.ToggleMute()
+	}
+
+	// Handle F9 key press to start or stop recording audio
+	if inpututil.IsKeyJustPressed(ebiten.KeyF9) {
+		game.toggleAudioRecording()
+	}
+
+	// Handle F10 key press to start or stop recording video
+	if inpututil.IsKeyJustPressed(ebiten.KeyF10) {
+		game.toggleVideoRecording()
+	}
+
+	// Handle F12 key press to take a screenshot
+	if inpututil.IsKeyJustPressed(ebiten.KeyF12) {
+		game.takeScreenshot()
+	}
+}
+
 // Draw fulfills the Game interface for ebiten
 func (game *SpaceInvadersGame) Draw(screen *ebiten.Image) {
 	if game.inSettingsMenu {
@@ -123,6 +308,11 @@ func (game *SpaceInvadersGame) Draw(screen *ebiten.Image) {
 	} else {
 		// Draw the CPU emulator output
 		game.cpuEmulator.Draw(screen)
+		game.drawPracticeLabel(screen)
+		game.drawToast(screen)
+		if game.enteringInitials() {
+			game.drawInitialsEntry(screen)
+		}
 	}
 }
 
@@ -138,6 +328,21 @@ func (game *SpaceInvadersGame) toggleSettingsMenu() {
 	if game.inSettingsMenu {
 		// Initialize menu screen with a specified settings file path
 		game.menuScreen = NewMenuScreen("settings.json")
+		game.menuScreen.SetGamepads(game.gamepads)
+		game.menuScreen.SetHighScores(game.highScores)
+		if game.stats != nil {
+			game.menuScreen.SetStats(game.stats.Session.Totals, game.lifetimeStats())
+		}
+		if game.achievements != nil {
+			game.menuScreen.SetAchievements(game.achievementDefs.Achievements, game.achievementProgress.Unlocked)
+		}
+		if game.bookkeeping != nil {
+			hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+			game.menuScreen.SetBooks(*game.bookkeeping.Books, hardware.CoinLockout())
+		}
+		if game.cheatFile != nil {
+			game.menuScreen.SetCheats(game.cheats, game.cheatSearch)
+		}
 	} else {
 		// Save settings after a change
 		if err := game.menuScreen.saveSettings(); err != nil {
@@ -145,15 +350,63 @@ func (game *SpaceInvadersGame) toggleSettingsMenu() {
 		}
 
 		// Update game settings from the menu screen
-		hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
-		hardware.ShipsSetting = game.menuScreen.GetShipsSetting()
-		hardware.ExtraShipAt1000 = game.menuScreen.GetExtraShipAt1000()
-		// Coin info displayed in demo screen 0=ON
-		hardware.ShowCoinInfoOnDemo = !game.menuScreen.GetShowCoinInfoOnDemo()
-		hardware.ColorScheme = game.menuScreen.GetColorScheme()
-
-		game.cpuEmulator.Options.LimitTPS = game.menuScreen.GetLimitTPS()
+		game.applySettings()
+		if game.cheatFile != nil {
+			game.cheats = game.menuScreen.GetCheats()
+			game.saveCheats()
+		}
 		// Close the menu screen
 		game.menuScreen = nil
 	}
 }
+
+// applySettings copies the settings from the menu screen to the emulator and hardware.
+func (game *SpaceInvadersGame) applySettings() {
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.ShipsSetting = game.menuScreen.GetShipsSetting()
+	hardware.ExtraShipAt1000 = game.menuScreen.GetExtraShipAt1000()
+	// Coin info displayed in demo screen 0=ON
+	hardware.ShowCoinInfoOnDemo = !game.menuScreen.GetShowCoinInfoOnDemo()
+	hardware.ColorScheme = game.menuScreen.GetColorScheme()
+	hardware.CabinetType = game.menuScreen.GetCabinetType()
+	hardware.FreePlay = game.menuScreen.GetFreePlay()
+	hardware.Practice = game.menuScreen.GetPractice()
+	game.keyboard.Bindings = game.menuScreen.GetBindings()
+	game.gamepads.Bindings = game.menuScreen.GetGamepadBindings()
+	game.gamepads.Slots = [2]int{game.menuScreen.GetGamepadSlot(0), game.menuScreen.GetGamepadSlot(1)}
+	game.gamepads.Deadzone = game.menuScreen.GetStickDeadzone()
+	hardware.CRT = game.menuScreen.GetCRTPreset().Settings()
+	hardware.SoundMode = game.menuScreen.GetSoundMode()
+	hardware.SetMasterVolume(game.menuScreen.GetMasterVolume())
+	hardware.SetDucking(game.menuScreen.GetDucking())
+	for effect := range invaders.SoundEffectNames {
+		hardware.SetEffectVolume(invaders.SoundEffect(effect), game.menuScreen.GetEffectVolume(invaders.SoundEffect(effect)))
+	}
+
+	game.cpuEmulator.Options.LimitTPS = game.menuScreen.GetLimitTPS()
+	game.applyAutoplaySettings()
+
+	if settings, ok := game.pinnedSettings(); ok {
+		if err := replay.ApplySettings(game.cpuEmulator, hardware, settings); err != nil {
+			game.cpuEmulator.Logger.Error("Failed to apply the game's settings", "err", err)
+		}
+	}
+}
+
+// pinnedSettings returns the settings the game has to keep playing with, whatever the menu
+// says, if it has any. A movie being recorded or played back has the settings in its header,
+// since changing them partway through would play a different game to the one in the movie.
+// So do both sides of a netplay game, and spectators.
+func (game *SpaceInvadersGame) pinnedSettings() (movie.Settings, bool) {
+	switch {
+	case game.movieRecorder != nil:
+		return game.movieRecorder.Header().Settings, true
+	case game.moviePlayer != nil:
+		return game.moviePlayer.Movie().Header.Settings, true
+	case game.netplay != nil:
+		return game.netplay.Settings(), true
+	case game.spectating != nil:
+		return game.spectating.Settings(), true
+	}
+	return movie.Settings{}, false
+}
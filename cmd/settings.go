@@ -8,9 +8,13 @@ import (
 	"image/color"
 	"log"
 
+	"github.com/braheezy/space-invaders/internal/cheat"
+	"github.com/braheezy/space-invaders/internal/crt"
+	"github.com/braheezy/space-invaders/internal/input"
 	"github.com/braheezy/space-invaders/internal/invaders"
 	"github.com/hajimehoshi/ebiten/v2"
 	"github.com/hajimehoshi/ebiten/v2/text/v2"
+	"github.com/hajimehoshi/ebiten/v2/vector"
 )
 
 //go:embed fonts/PressStart2P-Regular.ttf
@@ -29,13 +33,96 @@ var (
 )
 
 func NewDefaultSettings() []Setting {
-	return []Setting{
+	settings := []Setting{
 		&ColorSchemeSetting{name: "Color scheme", value: invaders.BlackAndWhite},
+		&ChoiceSetting{name: "CRT effect", value: int(crt.Off), choices: crt.PresetNames},
 		&OnOffSetting{name: "Show coin info on demo screen", value: true},
 		&OnOffSetting{name: "Extra ship at 1000 instead of 1500", value: false},
+		&OnOffSetting{name: "Free play", value: false},
 		&OnOffSetting{name: "Limit to 60 FPS", value: false},
 		&RangeSetting{name: "Ship Count", value: 3, minVal: 3, maxVal: 6},
+		&ChoiceSetting{name: "Cabinet", value: int(invaders.Upright), choices: invaders.CabinetTypeNames},
+		&ChoiceSetting{name: "Sound", value: int(invaders.SampledSound), choices: invaders.SoundModeNames},
+		&VolumeSetting{name: "Master volume", value: 100},
+		&OnOffSetting{name: "Duck sounds when player dies", value: true},
 	}
+	// Each sound effect gets its own volume
+	for _, effect := range invaders.SoundEffectNames {
+		settings = append(settings, &VolumeSetting{name: effectVolumeSettingName(effect), value: 100})
+	}
+
+	// The bot can play for either player
+	settings = append(settings,
+		&OnOffSetting{name: autoplaySettingName(0), value: false},
+		&OnOffSetting{name: autoplaySettingName(1), value: false},
+		&OnOffSetting{name: "Autoplay starts games", value: false},
+	)
+
+	// Practice games start on a later wave
+	settings = append(settings,
+		&ChoiceSetting{name: "Practice from wave", value: 0, choices: practiceWaveNames},
+		&RangeSetting{name: "Practice lives", value: 3, minVal: 1, maxVal: invaders.MaxPracticeLives},
+		&ChoiceSetting{name: "Practice score", value: 0, choices: practiceScoreNames},
+	)
+
+	// The key bindings are shown on their own page
+	settings = append(settings, &PageSetting{name: "Controls...", page: controlsPage})
+	bindings := input.DefaultBindings()
+	for action, keys := range bindings {
+		settings = append(settings, &KeyBindingSetting{
+			name:   keyBindingSettingName(input.Action(action)),
+			action: input.Action(action),
+			keys:   keys,
+		})
+	}
+
+	// So are the gamepad settings
+	settings = append(settings,
+		&PageSetting{name: "Gamepads...", page: gamepadsPage},
+		&ChoiceSetting{name: gamepadSlotSettingName(0), value: 0, choices: input.GamepadSlotNames, page: gamepadsPage},
+		&ChoiceSetting{name: gamepadSlotSettingName(1), value: 1, choices: input.GamepadSlotNames, page: gamepadsPage},
+		&ChoiceSetting{name: "Stick deadzone", value: defaultDeadzoneChoice, choices: deadzoneNames, page: gamepadsPage},
+	)
+	for action, buttons := range input.DefaultGamepadBindings() {
+		settings = append(settings, &GamepadBindingSetting{
+			name:    gamepadBindingSettingName(input.Action(action)),
+			action:  input.Action(action),
+			buttons: buttons,
+		})
+	}
+
+	settings = append(settings,
+		&PageSetting{name: "High scores...", page: highScoresPage},
+		&PageSetting{name: "Statistics...", page: statsPage},
+		&PageSetting{name: "Achievements...", page: achievementsPage},
+		&PageSetting{name: "Operator...", page: operatorPage},
+		&PageSetting{name: "Cheats...", page: cheatsPage},
+		&PageSetting{name: "RAM search...", page: searchPage},
+	)
+	return settings
+}
+
+// gamepadSlotSettingName returns the name of the setting picking a player's gamepad.
+func gamepadSlotSettingName(player int) string {
+	return fmt.Sprintf("Player %d gamepad", player+1)
+}
+
+// autoplaySettingName returns the name of the setting letting the bot play for a player.
+func autoplaySettingName(player int) string {
+	return fmt.Sprintf("Autoplay player %d", player+1)
+}
+
+// deadzoneNames are the choices for the stick deadzone, in steps of deadzoneStep.
+var deadzoneNames = []string{"10%", "20%", "30%", "40%", "50%"}
+
+const (
+	deadzoneStep          = 0.1
+	defaultDeadzoneChoice = 1
+)
+
+// effectVolumeSettingName returns the name of the volume setting for a sound effect.
+func effectVolumeSettingName(effect string) string {
+	return effect + " volume"
 }
 
 func init() {
@@ -192,7 +279,7 @@ func (s *RangeSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
 	}
 }
 
-// ColorSchemeSetting represents a setting with multiple predefined values (BW, TV, CV).
+// ColorSchemeSetting represents a setting with multiple predefined values (BW, TV, CV, Cabinet).
 type ColorSchemeSetting struct {
 	name  string
 	value invaders.ColorScheme
@@ -209,7 +296,7 @@ func (s *ColorSchemeSetting) Value() interface{} {
 func (s *ColorSchemeSetting) SetValue(val interface{}) error {
 	switch v := val.(type) {
 	case invaders.ColorScheme:
-		if v >= invaders.BlackAndWhite && v <= invaders.CV {
+		if v >= invaders.BlackAndWhite && v <= invaders.Cabinet {
 			s.value = v
 			return nil
 		}
@@ -236,7 +323,7 @@ func (s *ColorSchemeSetting) Render(screen *ebiten.Image, x, y int, selected boo
 	// Calculate the position for the setting values
 	valueX := float64(x) + nameWidth + 20
 
-	// Render all available values (BW, TV, CV)
+	// Render all available values (BW, TV, CV, Cabinet)
 	for i, value := range invaders.ColorSchemeNames {
 		valueOp := &text.DrawOptions{}
 		valueOp.GeoM.Translate(valueX, float64(y))
@@ -263,3 +350,437 @@ func (s *ColorSchemeSetting) Render(screen *ebiten.Image, x, y int, selected boo
 		text.Draw(screen, ">", loadedFont, arrowOp)
 	}
 }
+
+// ChoiceSetting represents a setting that picks one value from a list of named choices.
+type ChoiceSetting struct {
+	name    string
+	value   int
+	choices []string
+	// page is the page of the menu the setting is shown on
+	page menuPage
+}
+
+func (s *ChoiceSetting) Name() string {
+	return s.name
+}
+
+func (s *ChoiceSetting) Value() interface{} {
+	return s.choices[s.value]
+}
+
+func (s *ChoiceSetting) SetValue(val interface{}) error {
+	switch v := val.(type) {
+	case int:
+		if v >= 0 && v < len(s.choices) {
+			s.value = v
+			return nil
+		}
+	case string:
+		for i, choice := range s.choices {
+			if choice == v {
+				s.value = i
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("invalid value or out of range")
+}
+
+// next selects the following choice, wrapping around at the end.
+func (s *ChoiceSetting) next() {
+	s.value = (s.value + 1) % len(s.choices)
+}
+
+// previous selects the preceding choice, wrapping around at the start.
+func (s *ChoiceSetting) previous() {
+	s.value = (s.value + len(s.choices) - 1) % len(s.choices)
+}
+
+func (s *ChoiceSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	// Render the setting name
+	nameOp := &text.DrawOptions{}
+	nameOp.GeoM.Translate(float64(x), float64(y))
+	nameOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, s.name, loadedFont, nameOp)
+
+	// Measure the width of the setting name
+	nameWidth, _ := text.Measure(s.name, loadedFont, 1.0)
+
+	// Render all available choices after the name, highlighting the selected one
+	valueX := float64(x) + nameWidth + 20
+	for i, choice := range s.choices {
+		valueOp := &text.DrawOptions{}
+		valueOp.GeoM.Translate(valueX, float64(y))
+		if i == s.value {
+			valueOp.ColorScale.ScaleWithColor(color.RGBA{0, 255, 0, 255})
+		} else {
+			valueOp.ColorScale.ScaleWithColor(color.White)
+		}
+		text.Draw(screen, choice, loadedFont, valueOp)
+
+		choiceWidth, _ := text.Measure(choice, loadedFont, 1.0)
+		valueX += choiceWidth + 20
+	}
+
+	// If this setting is selected in the overall list, draw an arrow
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
+
+// VolumeSetting represents a volume from 0 to 100 percent, adjusted in steps.
+type VolumeSetting struct {
+	name  string
+	value int
+}
+
+// volumeStep is how much a volume changes with each key press.
+const volumeStep = 10
+
+func (s *VolumeSetting) Name() string {
+	return s.name
+}
+
+func (s *VolumeSetting) Value() interface{} {
+	return s.value
+}
+
+func (s *VolumeSetting) SetValue(val interface{}) error {
+	if v, ok := val.(float64); ok {
+		// JSON numbers are decoded as float64
+		val = int(v)
+	}
+	if v, ok := val.(int); ok && v >= 0 && v <= 100 {
+		s.value = v
+		return nil
+	}
+	return fmt.Errorf("invalid value or out of range")
+}
+
+// increase raises the volume by one step, up to 100.
+func (s *VolumeSetting) increase() {
+	s.value = min(s.value+volumeStep, 100)
+}
+
+// decrease lowers the volume by one step, down to 0.
+func (s *VolumeSetting) decrease() {
+	s.value = max(s.value-volumeStep, 0)
+}
+
+// Fraction returns the volume from 0 to 1.
+func (s *VolumeSetting) Fraction() float64 {
+	return float64(s.value) / 100
+}
+
+func (s *VolumeSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	// Render the setting name
+	nameOp := &text.DrawOptions{}
+	nameOp.GeoM.Translate(float64(x), float64(y))
+	nameOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, s.name, loadedFont, nameOp)
+
+	// Draw the volume as a bar at a fixed column so the bars line up
+	barX := float32(x + 300)
+	barY := float32(y + 1)
+	barWidth := float32(150)
+	barHeight := float32(10)
+	vector.StrokeRect(screen, barX, barY, barWidth, barHeight, 1, color.White, false)
+	vector.DrawFilledRect(screen, barX, barY, barWidth*float32(s.value)/100, barHeight, color.RGBA{0, 255, 0, 255}, false)
+
+	// Show the percentage after the bar
+	valueOp := &text.DrawOptions{}
+	valueOp.GeoM.Translate(float64(barX+barWidth+15), float64(y))
+	valueOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, fmt.Sprintf("%d%%", s.value), loadedFont, valueOp)
+
+	// If selected, indicate that this setting is active
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
+
+// KeyBindingSetting holds the keys bound to one of the cabinet's controls.
+type KeyBindingSetting struct {
+	name   string
+	action input.Action
+	keys   []ebiten.Key
+	// capturing is set while waiting for a key to bind
+	capturing bool
+}
+
+// keyBindingSettingName returns the name of the key binding setting for an action.
+func keyBindingSettingName(action input.Action) string {
+	return input.ActionNames[action] + " keys"
+}
+
+func (s *KeyBindingSetting) Name() string {
+	return s.name
+}
+
+func (s *KeyBindingSetting) Value() interface{} {
+	// Keys are saved by name, which also keeps the value comparable
+	return input.FormatKeys(s.keys)
+}
+
+func (s *KeyBindingSetting) SetValue(val interface{}) error {
+	if v, ok := val.(string); ok {
+		keys, err := input.ParseKeys(v)
+		if err != nil {
+			return err
+		}
+		s.keys = keys
+		return nil
+	}
+	return fmt.Errorf("invalid value type")
+}
+
+func (s *KeyBindingSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	// Render the name of the control
+	nameOp := &text.DrawOptions{}
+	nameOp.GeoM.Translate(float64(x), float64(y))
+	nameOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, input.ActionNames[s.action], loadedFont, nameOp)
+
+	// Render the bound keys in a column, in yellow like the help section
+	keys := input.FormatKeys(s.keys)
+	keysColor := color.RGBA{255, 255, 0, 255}
+	if s.capturing {
+		keys = "Press a key..."
+		keysColor = color.RGBA{0, 255, 0, 255}
+	} else if keys == "" {
+		keys = "None"
+		keysColor = color.RGBA{128, 128, 128, 255}
+	}
+	keysOp := &text.DrawOptions{}
+	keysOp.GeoM.Translate(float64(x+200), float64(y))
+	keysOp.ColorScale.ScaleWithColor(keysColor)
+	text.Draw(screen, keys, loadedFont, keysOp)
+
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
+
+// PageSetting opens another page of the menu when chosen. It has no value to save.
+type PageSetting struct {
+	name string
+	page menuPage
+}
+
+func (s *PageSetting) Name() string {
+	return s.name
+}
+
+func (s *PageSetting) Value() interface{} {
+	return nil
+}
+
+func (s *PageSetting) SetValue(val interface{}) error {
+	return nil
+}
+
+func (s *PageSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	nameOp := &text.DrawOptions{}
+	nameOp.GeoM.Translate(float64(x), float64(y))
+	nameOp.ColorScale.ScaleWithColor(color.RGBA{196, 167, 231, 255})
+	text.Draw(screen, s.name, loadedFont, nameOp)
+
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
+
+// GamepadBindingSetting holds the gamepad buttons bound to one of the cabinet's controls.
+type GamepadBindingSetting struct {
+	name    string
+	action  input.Action
+	buttons []input.GamepadButton
+	// capturing is set while waiting for a button to bind
+	capturing bool
+}
+
+// gamepadBindingSettingName returns the name of the gamepad binding setting for an action.
+func gamepadBindingSettingName(action input.Action) string {
+	return input.ActionNames[action] + " buttons"
+}
+
+func (s *GamepadBindingSetting) Name() string {
+	return s.name
+}
+
+func (s *GamepadBindingSetting) Value() interface{} {
+	// Buttons are saved by name, which also keeps the value comparable
+	return input.FormatButtons(s.buttons)
+}
+
+func (s *GamepadBindingSetting) SetValue(val interface{}) error {
+	if v, ok := val.(string); ok {
+		buttons, err := input.ParseButtons(v)
+		if err != nil {
+			return err
+		}
+		s.buttons = buttons
+		return nil
+	}
+	return fmt.Errorf("invalid value type")
+}
+
+func (s *GamepadBindingSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	// Render the name of the control
+	nameOp := &text.DrawOptions{}
+	nameOp.GeoM.Translate(float64(x), float64(y))
+	nameOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, input.ActionNames[s.action], loadedFont, nameOp)
+
+	// Render the bound buttons in a column, like the key bindings
+	buttons := input.FormatButtons(s.buttons)
+	buttonsColor := color.RGBA{255, 255, 0, 255}
+	if s.capturing {
+		buttons = "Press a button..."
+		buttonsColor = color.RGBA{0, 255, 0, 255}
+	} else if buttons == "" {
+		buttons = "None"
+		buttonsColor = color.RGBA{128, 128, 128, 255}
+	}
+	buttonsOp := &text.DrawOptions{}
+	buttonsOp.GeoM.Translate(float64(x+200), float64(y))
+	buttonsOp.ColorScale.ScaleWithColor(buttonsColor)
+	text.Draw(screen, buttons, loadedFont, buttonsOp)
+
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
+
+// CheatSetting turns a cheat on and off. Cheats are saved with the ROM they're for, not with
+// the settings.
+type CheatSetting struct {
+	cheat *cheat.Cheat
+}
+
+func (s *CheatSetting) Name() string {
+	return s.cheat.Name
+}
+
+func (s *CheatSetting) Value() interface{} {
+	return s.cheat.Enabled
+}
+
+func (s *CheatSetting) SetValue(val interface{}) error {
+	if v, ok := val.(bool); ok {
+		s.cheat.Enabled = v
+		return nil
+	}
+	return fmt.Errorf("invalid value type")
+}
+
+func (s *CheatSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	// It's drawn just like an on/off setting
+	onOff := &OnOffSetting{name: s.cheat.Name, value: s.cheat.Enabled}
+	onOff.Render(screen, x, y, selected)
+}
+
+// SearchSetting narrows down the RAM search when chosen, or starts it again. It has no value
+// to save.
+type SearchSetting struct {
+	name string
+	// comparison is how the values are compared with the last snapshot
+	comparison cheat.Comparison
+	// value is the value looked for by cheat.Equal
+	value byte
+	// reset starts a new search instead
+	reset bool
+}
+
+func (s *SearchSetting) Name() string {
+	return s.name
+}
+
+func (s *SearchSetting) Value() interface{} {
+	return nil
+}
+
+func (s *SearchSetting) SetValue(val interface{}) error {
+	return nil
+}
+
+func (s *SearchSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	nameOp := &text.DrawOptions{}
+	nameOp.GeoM.Translate(float64(x), float64(y))
+	nameOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, s.name, loadedFont, nameOp)
+
+	// Only an exact search needs a value, picked with Left and Right
+	if !s.reset && s.comparison == cheat.Equal {
+		nameWidth, _ := text.Measure(s.name, loadedFont, 1.0)
+		valueOp := &text.DrawOptions{}
+		valueOp.GeoM.Translate(float64(x)+nameWidth+20, float64(y))
+		valueOp.ColorScale.ScaleWithColor(color.RGBA{0, 255, 0, 255})
+		text.Draw(screen, fmt.Sprintf("< %d >", s.value), loadedFont, valueOp)
+	}
+
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
+
+// SearchResultSetting is an address the RAM search has kept, frozen as a cheat when chosen.
+type SearchResultSetting struct {
+	result cheat.Result
+	// frozen is set once there's a cheat freezing the address
+	frozen bool
+}
+
+func (s *SearchResultSetting) Name() string {
+	return s.result.Address.String()
+}
+
+func (s *SearchResultSetting) Value() interface{} {
+	return nil
+}
+
+func (s *SearchResultSetting) SetValue(val interface{}) error {
+	return nil
+}
+
+func (s *SearchResultSetting) Render(screen *ebiten.Image, x, y int, selected bool) {
+	addressOp := &text.DrawOptions{}
+	addressOp.GeoM.Translate(float64(x), float64(y))
+	addressOp.ColorScale.ScaleWithColor(color.RGBA{255, 255, 0, 255})
+	text.Draw(screen, s.result.Address.String(), loadedFont, addressOp)
+
+	// Show the value in a column, like the key bindings, and what it was before
+	values := fmt.Sprintf("%d, was %d", s.result.Value, s.result.Previous)
+	if s.frozen {
+		values += " (frozen)"
+	}
+	valuesOp := &text.DrawOptions{}
+	valuesOp.GeoM.Translate(float64(x+200), float64(y))
+	valuesOp.ColorScale.ScaleWithColor(color.White)
+	text.Draw(screen, values, loadedFont, valuesOp)
+
+	if selected {
+		arrowOp := &text.DrawOptions{}
+		arrowOp.GeoM.Translate(float64(x-20), float64(y))
+		arrowOp.ColorScale.ScaleWithColor(color.White)
+		text.Draw(screen, ">", loadedFont, arrowOp)
+	}
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/spectate"
+	"github.com/charmbracelet/log"
+)
+
+var (
+	serveSpectatorsAddr string
+	spectateAddr        string
+)
+
+func init() {
+	rootCmd.Flags().StringVar(&serveSpectatorsAddr, "serve-spectators", "", "Let other instances watch the game by connecting to this TCP address, like :7900")
+	rootCmd.Flags().StringVar(&spectateAddr, "spectate", "", "Watch a game served with --serve-spectators at this TCP address, like localhost:7900")
+	rootCmd.MarkFlagsMutuallyExclusive("serve-spectators", "spectate")
+	for _, flag := range []string{"netplay-host", "netplay-join", "record-movie", "play-movie", "input-script", "input-listen"} {
+		rootCmd.MarkFlagsMutuallyExclusive("spectate", flag)
+	}
+	rootCmd.MarkFlagsMutuallyExclusive("serve-spectators", "netplay-host")
+	rootCmd.MarkFlagsMutuallyExclusive("serve-spectators", "netplay-join")
+}
+
+// setupSpectating starts serving the game to spectators, or watches someone else's, if asked
+// to. Watching must start before the first frame is emulated.
+func (game *SpaceInvadersGame) setupSpectating(logger *log.Logger) error {
+	if serveSpectatorsAddr != "" {
+		server, err := spectate.Listen(serveSpectatorsAddr)
+		if err != nil {
+			return err
+		}
+		game.spectatorServer = server
+		logger.Info("Serving spectators", "addr", server.Addr())
+	}
+
+	if spectateAddr != "" {
+		hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+		logger.Info("Waiting for the game", "addr", spectateAddr)
+		spectator, err := spectate.Watch(spectateAddr, game.cpuEmulator, hardware)
+		if err != nil {
+			return err
+		}
+		game.spectating = spectator
+		logger.Info("Spectating", "frame", game.cpuEmulator.FrameCount(), "settings", spectator.Settings())
+	}
+	return nil
+}
+
+// updateSpectating emulates the frames the host has sent. Once the host stops, the game
+// carries on locally from where it got to.
+func (game *SpaceInvadersGame) updateSpectating() {
+	err := game.spectating.Update()
+	if desync := game.spectating.Desync(); desync != nil && !game.spectatingDesynced {
+		// Only the first desync matters, everything after follows from it
+		game.spectatingDesynced = true
+		game.cpuEmulator.Logger.Error("Spectated game desynced", "frame", desync.Frame, "err", desync)
+	}
+	if err == nil {
+		return
+	}
+
+	game.cpuEmulator.Logger.Error("Stopped spectating", "frame", game.cpuEmulator.FrameCount(), "err", err)
+	game.stopSpectating()
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	hardware.Input = game.localInput
+	game.applySettings()
+}
+
+// endSpectatorFrame sends the frame just emulated to the spectators, if serving any.
+func (game *SpaceInvadersGame) endSpectatorFrame() {
+	if game.spectatorServer == nil {
+		return
+	}
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	game.spectatorServer.EndFrame(game.cpuEmulator, hardware)
+}
+
+// stopSpectating stops serving spectators and watching another game.
+func (game *SpaceInvadersGame) stopSpectating() {
+	if game.spectatorServer != nil {
+		game.spectatorServer.Close()
+		game.spectatorServer = nil
+	}
+	if game.spectating != nil {
+		game.spectating.Close()
+		game.spectating = nil
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/stats"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+)
+
+// statsFile is where the statistics are kept.
+const statsFile = "stats.json"
+
+var exportFormat string
+
+func init() {
+	exportStatsCmd.Flags().StringVar(&exportFormat, "format", "json", "Format to export in, json or csv")
+	rootCmd.AddCommand(exportStatsCmd)
+}
+
+var exportStatsCmd = &cobra.Command{
+	Use:   "export-stats [file]",
+	Short: "Print the statistics of the games played as JSON or CSV",
+	Long: `Print the statistics kept of the games played, for each session and over all of them:
+games played, shots fired and accuracy, invaders killed, UFOs destroyed and what they were
+worth, deaths by cause, the average wave reached and play time.
+
+They're read from ` + statsFile + ` unless another file is given.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := statsFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+		record, err := stats.Load(path)
+		if err != nil {
+			return err
+		}
+		switch exportFormat {
+		case "json":
+			return record.WriteJSON(os.Stdout)
+		case "csv":
+			return record.WriteCSV(os.Stdout)
+		default:
+			return fmt.Errorf("unknown format %q, must be json or csv", exportFormat)
+		}
+	},
+}
+
+// setupStats starts keeping statistics of the games played. Movies being played back and games
+// being watched aren't played here, so they don't count.
+func (game *SpaceInvadersGame) setupStats(logger *log.Logger) {
+	if game.moviePlayer != nil || game.spectating != nil {
+		return
+	}
+
+	record, err := stats.Load(statsFile)
+	if err != nil {
+		logger.Error("Failed to load statistics", "path", statsFile, "err", err)
+		return
+	}
+	game.statsRecord = record
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	game.stats = stats.NewTracker(hardware.RAM())
+}
+
+// updateStats adds the frame just played to the statistics, saving them when a game ends.
+// The bot's games, practice games and cheated games aren't counted, and neither are netplay
+// games, whose frames can be rolled back and played again.
+func (game *SpaceInvadersGame) updateStats() {
+	if game.stats == nil {
+		return
+	}
+	hardware := game.cpuEmulator.Hardware.(*invaders.SpaceInvadersHardware)
+	game.stats.Paused = game.autoplay.Enabled() || hardware.Practicing() || game.cheated || game.netplay != nil
+	if game.stats.Update() {
+		game.saveStats()
+	}
+}
+
+// saveStats saves the statistics, with this session's so far.
+func (game *SpaceInvadersGame) saveStats() {
+	if game.stats == nil || game.stats.Session.Frames == 0 {
+		return
+	}
+	if err := game.statsRecord.With(game.stats.Session).Save(statsFile); err != nil {
+		game.cpuEmulator.Logger.Error("Failed to save statistics", "path", statsFile, "err", err)
+	}
+}
+
+// lifetimeStats returns the statistics over every session, including this one.
+func (game *SpaceInvadersGame) lifetimeStats() stats.Totals {
+	return game.statsRecord.With(game.stats.Session).Lifetime
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/spf13/cobra"
+)
+
+var (
+	claimedScore     int
+	requiredSettings movie.Settings
+	anySettings      bool
+)
+
+func init() {
+	verifyReplayCmd.Flags().IntVar(&claimedScore, "score", -1, "Score the movie claims; fail unless the best final score matches")
+	verifyReplayCmd.Flags().IntVar(&requiredSettings.Ships, "ships", 3, "Ship count the movie must be played with")
+	verifyReplayCmd.Flags().BoolVar(&requiredSettings.ExtraShipAt1000, "extra-ship-at-1000", false, "Require the extra ship at 1000 instead of 1500")
+	verifyReplayCmd.Flags().BoolVar(&requiredSettings.HideCoinInfo, "hide-coin-info", false, "Require the coin info to be hidden on the demo screen")
+	verifyReplayCmd.Flags().StringVar(&requiredSettings.Cabinet, "cabinet", "Upright", "Cabinet the movie must be played on")
+	verifyReplayCmd.Flags().BoolVar(&anySettings, "any-settings", false, "Accept movies played with any settings")
+	verifyReplayCmd.Flags().StringArrayVar(&patchPaths, "patch", nil, "IPS or BPS patch the movie's ROM was made with; repeat to apply several in order")
+	rootCmd.AddCommand(verifyReplayCmd)
+}
+
+var verifyReplayCmd = &cobra.Command{
+	Use:   "verify-replay movie",
+	Short: "Check a movie plays back in sync and report its score",
+	Long: `Play a movie recorded with --record-movie without a window, as fast as possible.
+
+The movie must be for this ROM and, unless --any-settings is given, played with the
+required settings. It fails if the playback desyncs or the score doesn't match --score.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := movie.Load(args[0])
+		if err != nil {
+			return err
+		}
+		if !anySettings && !replay.SameGame(m.Header.Settings, requiredSettings) {
+			return fmt.Errorf("movie was played with different settings: %+v", m.Header.Settings)
+		}
+
+		result, err := replay.Play(m, patchPaths)
+		var desync *movie.DesyncError
+		if err != nil && !errors.As(err, &desync) {
+			return err
+		}
+
+		fmt.Printf("ROM:           %s\n", m.Header.ROMHash)
+		fmt.Printf("Settings:      %d ships, extra ship at %d, %s cabinet\n", m.Header.Settings.Ships, extraShipScore(m.Header.Settings), m.Header.Settings.Cabinet)
+		fmt.Printf("Frames:        %d of %d\n", result.Frames, m.Frames())
+		fmt.Printf("Scores:        %d (player 1), %d (player 2)\n", result.Scores[0], result.Scores[1])
+		fmt.Printf("High score:    %d\n", result.HighScore)
+		fmt.Printf("Waves cleared: %d\n", result.WavesCleared)
+
+		if desync != nil {
+			return err
+		}
+		if claimedScore >= 0 && result.BestScore() != claimedScore {
+			return fmt.Errorf("claimed score %d doesn't match the final score %d", claimedScore, result.BestScore())
+		}
+		fmt.Println("Verified")
+		return nil
+	},
+}
+
+// extraShipScore returns the score the extra ship is given at.
+func extraShipScore(settings movie.Settings) int {
+	if settings.ExtraShipAt1000 {
+		return 1000
+	}
+	return 1500
+}
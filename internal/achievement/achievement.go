@@ -0,0 +1,256 @@
+// Package achievement unlocks achievements as a game is played. Achievements are defined in a
+// data file as conditions over values in the machine's memory, and checked after every frame,
+// so they work for any ROM. The achievements unlocked, and the counters they keep, are saved
+// between runs.
+package achievement
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Address is an address in memory. In a data file it's a number, or a string like "0x20F8".
+type Address uint16
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	n, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %s", data)
+	}
+	*a = Address(n)
+	return nil
+}
+
+// Value is a number in memory.
+type Value struct {
+	Address Address `json:"address"`
+	// Size is how many bytes long it is, least significant first. It defaults to 1.
+	Size int `json:"size,omitempty"`
+	// BCD is set for numbers stored as two decimal digits a byte, like scores
+	BCD bool `json:"bcd,omitempty"`
+}
+
+// read reads the value from memory.
+func (v Value) read(memory *[0x10000]byte) int {
+	n := 0
+	for i := max(v.Size, 1) - 1; i >= 0; i-- {
+		b := int(memory[uint16(int(v.Address)+i)])
+		if v.BCD {
+			n = n*100 + b>>4*10 + b&0x0F
+		} else {
+			n = n<<8 | b
+		}
+	}
+	return n
+}
+
+// Counter counts frames or events across frames, for conditions that depend on more than one
+// frame. Each frame, after the achievements are checked, it goes back to 0 if its Reset
+// condition holds, and then goes up by 1 if its Count condition does.
+type Counter struct {
+	Count string `json:"count"`
+	Reset string `json:"reset,omitempty"`
+}
+
+// Achievement is something to do in a game.
+type Achievement struct {
+	// ID identifies it in the saved progress, so it can be renamed
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Condition unlocks the achievement as soon as it holds after a frame
+	Condition string `json:"condition"`
+}
+
+// Definitions are the achievements for a game, and the values and counters they use.
+type Definitions struct {
+	Values       map[string]Value   `json:"values"`
+	Counters     map[string]Counter `json:"counters"`
+	Achievements []Achievement      `json:"achievements"`
+
+	counterNames []string
+	counters     []compiledCounter
+	conditions   []expr
+}
+
+type compiledCounter struct {
+	count, reset expr
+}
+
+// Parse reads definitions from a data file's contents, checking every condition.
+func Parse(data []byte) (*Definitions, error) {
+	var d Definitions
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for name := range d.Values {
+		names[name] = true
+	}
+	for name := range d.Counters {
+		if names[name] {
+			return nil, fmt.Errorf("%q is both a value and a counter", name)
+		}
+		names[name] = true
+		d.counterNames = append(d.counterNames, name)
+	}
+	// Counters are updated in the same order every time
+	slices.Sort(d.counterNames)
+
+	for _, name := range d.counterNames {
+		counter := d.Counters[name]
+		count, err := parse(counter.Count, names)
+		if err != nil {
+			return nil, fmt.Errorf("counter %q: %w", name, err)
+		}
+		var reset expr = number(0)
+		if counter.Reset != "" {
+			if reset, err = parse(counter.Reset, names); err != nil {
+				return nil, fmt.Errorf("counter %q reset: %w", name, err)
+			}
+		}
+		d.counters = append(d.counters, compiledCounter{count: count, reset: reset})
+	}
+
+	ids := map[string]bool{}
+	for _, achievement := range d.Achievements {
+		if achievement.ID == "" || ids[achievement.ID] {
+			return nil, fmt.Errorf("achievement %q needs an ID of its own", achievement.Name)
+		}
+		ids[achievement.ID] = true
+		condition, err := parse(achievement.Condition, names)
+		if err != nil {
+			return nil, fmt.Errorf("achievement %q: %w", achievement.ID, err)
+		}
+		d.conditions = append(d.conditions, condition)
+	}
+	return &d, nil
+}
+
+// LoadDefinitions reads definitions from a data file.
+func LoadDefinitions(path string) (*Definitions, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Progress is what's been done towards the achievements, saved between runs.
+type Progress struct {
+	// Unlocked are when each achievement unlocked, by ID
+	Unlocked map[string]time.Time `json:"unlocked"`
+	// Counters are the counters' values
+	Counters map[string]int `json:"counters"`
+}
+
+// LoadProgress reads progress from a file. A missing file is no progress.
+func LoadProgress(path string) (*Progress, error) {
+	p := &Progress{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, err
+		}
+	}
+	if p.Unlocked == nil {
+		p.Unlocked = map[string]time.Time{}
+	}
+	if p.Counters == nil {
+		p.Counters = map[string]int{}
+	}
+	return p, nil
+}
+
+// Save writes the progress to a file.
+func (p *Progress) Save(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Tracker checks the achievements after each frame.
+type Tracker struct {
+	// Paused stops achievements unlocking and counters counting, while the tracker keeps
+	// following the game
+	Paused bool
+
+	definitions *Definitions
+	progress    *Progress
+	memory      *[0x10000]byte
+	// last and lastCounters are memory and the counters as they were after the frame before,
+	// for prev
+	last         [0x10000]byte
+	lastCounters map[string]int
+	started      bool
+	frame        int
+}
+
+// NewTracker returns a tracker for achievements in a machine's memory, carrying on from some
+// progress, which it keeps up to date.
+func NewTracker(definitions *Definitions, memory *[0x10000]byte, progress *Progress) *Tracker {
+	return &Tracker{definitions: definitions, progress: progress, memory: memory}
+}
+
+// Update checks the achievements after a frame, returning those that just unlocked.
+func (t *Tracker) Update() []Achievement {
+	if !t.started {
+		// There's no frame before the first, so prev sees this one
+		t.last = *t.memory
+		t.lastCounters = t.progress.Counters
+		t.started = true
+	}
+	t.frame++
+	d := t.definitions
+	last := &context{memory: &t.last, values: d.Values, counters: t.lastCounters, frame: t.frame - 1}
+	now := &context{memory: t.memory, values: d.Values, counters: t.progress.Counters, frame: t.frame, prev: last}
+
+	var unlocked []Achievement
+	if !t.Paused {
+		for i, achievement := range d.Achievements {
+			if _, done := t.progress.Unlocked[achievement.ID]; done {
+				continue
+			}
+			if d.conditions[i].eval(now) != 0 {
+				t.progress.Unlocked[achievement.ID] = time.Now()
+				unlocked = append(unlocked, achievement)
+			}
+		}
+
+		// Counters change together, each seeing the others as they were
+		counters := make(map[string]int, len(d.counterNames))
+		for i, name := range d.counterNames {
+			value := t.progress.Counters[name]
+			if d.counters[i].reset.eval(now) != 0 {
+				value = 0
+			}
+			if d.counters[i].count.eval(now) != 0 {
+				value++
+			}
+			counters[name] = value
+		}
+		t.lastCounters = t.progress.Counters
+		t.progress.Counters = counters
+	} else {
+		t.lastCounters = t.progress.Counters
+	}
+
+	t.last = *t.memory
+	return unlocked
+}
@@ -0,0 +1,131 @@
+package achievement
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testDefinitions = `{
+  "values": {
+    "playing": {"address": "0x20EF"},
+    "alive": {"address": 8213},
+    "wave": {"address": "0x21FE"}
+  },
+  "counters": {
+    "wave_deaths": {
+      "count": "prev(alive) == 0xFF && alive != 0xFF",
+      "reset": "wave != prev(wave)"
+    }
+  },
+  "achievements": [
+    {"id": "start", "name": "Started", "condition": "playing"},
+    {"id": "flawless", "name": "Flawless", "condition": "wave == prev(wave) + 1 && wave_deaths == 0"}
+  ]
+}`
+
+func TestTracker(t *testing.T) {
+	definitions, err := Parse([]byte(testDefinitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress, err := LoadProgress(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var memory [0x10000]byte
+	tracker := NewTracker(definitions, &memory, progress)
+	// frame changes memory and returns the IDs of the achievements unlocked after it
+	frame := func(change func()) []string {
+		change()
+		var ids []string
+		for _, a := range tracker.Update() {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+
+	if got := frame(func() { memory[0x2015] = 0xFF }); got != nil {
+		t.Errorf("expected nothing before the game, got %v", got)
+	}
+	if got := frame(func() { memory[0x20EF] = 1 }); len(got) != 1 || got[0] != "start" {
+		t.Errorf("expected start, got %v", got)
+	}
+	if got := frame(func() {}); got != nil {
+		t.Errorf("expected achievements to unlock once, got %v", got)
+	}
+
+	// Dying on a wave spoils it
+	frame(func() { memory[0x2015] = 0 })
+	frame(func() { memory[0x2015] = 0xFF })
+	if progress.Counters["wave_deaths"] != 1 {
+		t.Errorf("expected a death counted, got %v", progress.Counters)
+	}
+	if got := frame(func() { memory[0x21FE] = 1 }); got != nil {
+		t.Errorf("expected no flawless wave, got %v", got)
+	}
+	if progress.Counters["wave_deaths"] != 0 {
+		t.Errorf("expected the counter reset by the new wave, got %v", progress.Counters)
+	}
+
+	// The bot playing doesn't count
+	tracker.Paused = true
+	if got := frame(func() { memory[0x21FE] = 2 }); got != nil {
+		t.Errorf("expected nothing unlocked while paused, got %v", got)
+	}
+	tracker.Paused = false
+	if got := frame(func() { memory[0x21FE] = 3 }); len(got) != 1 || got[0] != "flawless" {
+		t.Errorf("expected flawless, got %v", got)
+	}
+}
+
+func TestProgressRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "achievements.json")
+	progress, err := LoadProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress.Counters["wave_deaths"] = 2
+	definitions, err := Parse([]byte(testDefinitions))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var memory [0x10000]byte
+	memory[0x20EF] = 1
+	NewTracker(definitions, &memory, progress).Update()
+	if err := progress.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadProgress(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.Unlocked["start"]; !ok || len(loaded.Unlocked) != 1 {
+		t.Errorf("expected start unlocked, got %v", loaded.Unlocked)
+	}
+	if loaded.Counters["wave_deaths"] != 2 {
+		t.Errorf("expected the counter kept, got %v", loaded.Counters)
+	}
+}
+
+func TestParseDefinitionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Bad JSON", data: `{`},
+		{name: "Bad address", data: `{"values": {"x": {"address": "nowhere"}}}`},
+		{name: "Unknown name", data: `{"achievements": [{"id": "a", "condition": "lives == 0"}]}`},
+		{name: "Missing ID", data: `{"achievements": [{"name": "A", "condition": "1"}]}`},
+		{name: "Duplicate ID", data: `{"achievements": [{"id": "a", "condition": "1"}, {"id": "a", "condition": "1"}]}`},
+		{name: "Bad counter", data: `{"counters": {"c": {"count": "1 +"}}}`},
+		{name: "Value and counter", data: `{"values": {"c": {"address": 1}}, "counters": {"c": {"count": "1"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.data)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,323 @@
+package achievement
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// An expression is a condition or a number worked out from memory. Numbers are integers,
+// and conditions are numbers where 0 is false and anything else true. Expressions are written
+// like Go or C:
+//
+//	score >= 1000 && lives == 3
+//	(score + 10000 - prev(score)) % 10000 == 300
+//
+// with the operators, from binding loosest to tightest:
+//
+//	||
+//	&&
+//	== != < <= > >=
+//	+ -
+//	* / %
+//	! - (unary)
+//
+// Names are values read from memory or counters, as defined alongside the achievements, or
+// frame, the number of frames since tracking started. Numbers are decimal, or hex with 0x.
+// The functions are:
+//
+//	prev(expr)  expr as it was the frame before
+//	peek(addr)  the byte at an address in memory
+type expr interface {
+	eval(c *context) int
+}
+
+// context is what an expression is worked out from.
+type context struct {
+	memory *[0x10000]byte
+	// values are the values defined, by name
+	values map[string]Value
+	// counters are the counters by name, and frame the number of frames tracked
+	counters map[string]int
+	frame    int
+	// prev is the context of the frame before
+	prev *context
+}
+
+type number int
+
+func (n number) eval(*context) int { return int(n) }
+
+// ident is the name of a value, a counter or frame.
+type ident string
+
+func (n ident) eval(c *context) int {
+	if value, ok := c.values[string(n)]; ok {
+		return value.read(c.memory)
+	}
+	if string(n) == "frame" {
+		return c.frame
+	}
+	return c.counters[string(n)]
+}
+
+type prev struct{ x expr }
+
+func (p prev) eval(c *context) int {
+	if c.prev == nil {
+		return p.x.eval(c)
+	}
+	return p.x.eval(c.prev)
+}
+
+type peek struct{ address expr }
+
+func (p peek) eval(c *context) int {
+	return int(c.memory[uint16(p.address.eval(c))])
+}
+
+type unary struct {
+	op string
+	x  expr
+}
+
+func (u unary) eval(c *context) int {
+	x := u.x.eval(c)
+	if u.op == "-" {
+		return -x
+	}
+	return truth(x == 0)
+}
+
+type binary struct {
+	op   string
+	x, y expr
+}
+
+func (b binary) eval(c *context) int {
+	x := b.x.eval(c)
+	// Conditions stop as soon as they're decided
+	switch b.op {
+	case "&&":
+		if x == 0 {
+			return 0
+		}
+		return truth(b.y.eval(c) != 0)
+	case "||":
+		if x != 0 {
+			return 1
+		}
+		return truth(b.y.eval(c) != 0)
+	}
+
+	y := b.y.eval(c)
+	switch b.op {
+	case "+":
+		return x + y
+	case "-":
+		return x - y
+	case "*":
+		return x * y
+	case "/", "%":
+		// Nothing divides by 0: it's 0
+		if y == 0 {
+			return 0
+		}
+		if b.op == "/" {
+			return x / y
+		}
+		return x % y
+	case "==":
+		return truth(x == y)
+	case "!=":
+		return truth(x != y)
+	case "<":
+		return truth(x < y)
+	case "<=":
+		return truth(x <= y)
+	case ">":
+		return truth(x > y)
+	case ">=":
+		return truth(x >= y)
+	}
+	panic("unknown operator " + b.op)
+}
+
+func truth(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// levels are the binary operators, from binding loosest to tightest.
+var levels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<=", ">=", "<", ">"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// parser parses an expression from its tokens.
+type parser struct {
+	tokens []string
+	pos    int
+	// names are the names that can be used, other than frame
+	names map[string]bool
+}
+
+// parse parses an expression, checking it only uses the names given.
+func parse(source string, names map[string]bool) (expr, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, names: names}
+	x, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos])
+	}
+	return x, nil
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *parser) expect(token string) error {
+	if got := p.next(); got != token {
+		if got == "" {
+			return fmt.Errorf("expected %q at the end", token)
+		}
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+// binary parses operators of a level and tighter.
+func (p *parser) binary(level int) (expr, error) {
+	if level == len(levels) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.peek()
+		if !slices.Contains(levels[level], op) {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if op := p.peek(); op == "!" || op == "-" {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unary{op: op, x: x}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("unexpected end")
+	case token == "(":
+		x, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		return x, p.expect(")")
+	case unicode.IsDigit(rune(token[0])):
+		n, err := strconv.ParseInt(token, 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", token)
+		}
+		return number(n), nil
+	case token == "prev" || token == "peek":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		x, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if token == "prev" {
+			return prev{x}, nil
+		}
+		return peek{x}, nil
+	case isNameStart(rune(token[0])):
+		if token != "frame" && !p.names[token] {
+			return nil, fmt.Errorf("unknown name %q", token)
+		}
+		return ident(token), nil
+	}
+	return nil, fmt.Errorf("unexpected %q", token)
+}
+
+// tokenize splits an expression into names, numbers, operators and parentheses.
+func tokenize(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		r := rune(source[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isNameStart(r) || unicode.IsDigit(r):
+			start := i
+			for i < len(source) && (isNameStart(rune(source[i])) || unicode.IsDigit(rune(source[i]))) {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		case strings.ContainsRune("()+-*/%", r):
+			tokens = append(tokens, source[i:i+1])
+			i++
+		default:
+			// The two character operators, and those that are one on their own
+			if i+1 < len(source) && slices.Contains([]string{"&&", "||", "==", "!=", "<=", ">="}, source[i:i+2]) {
+				tokens = append(tokens, source[i:i+2])
+				i += 2
+			} else if strings.ContainsRune("!<>", r) {
+				tokens = append(tokens, source[i:i+1])
+				i++
+			} else {
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+		}
+	}
+	return tokens, nil
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
@@ -0,0 +1,71 @@
+package achievement
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	var memory, last [0x10000]byte
+	memory[0x20F8], memory[0x20F9] = 0x50, 0x12
+	last[0x20F8], last[0x20F9] = 0x50, 0x09
+	memory[0x2015] = 0xFF
+	memory[0x4000] = 7
+
+	values := map[string]Value{
+		"score": {Address: 0x20F8, Size: 2, BCD: true},
+		"word":  {Address: 0x20F8, Size: 2},
+		"alive": {Address: 0x2015},
+	}
+	prevContext := &context{memory: &last, values: values, counters: map[string]int{"deaths": 1}, frame: 9}
+	c := &context{memory: &memory, values: values, counters: map[string]int{"deaths": 2}, frame: 10, prev: prevContext}
+	names := map[string]bool{"score": true, "word": true, "alive": true, "deaths": true}
+
+	tests := []struct {
+		source   string
+		expected int
+	}{
+		{source: "score", expected: 1250},
+		{source: "word", expected: 0x1250},
+		{source: "prev(score)", expected: 950},
+		{source: "score - prev(score)", expected: 300},
+		{source: "(prev(score) + 10000 - score) % 10000", expected: 9700},
+		{source: "alive == 0xFF && deaths > prev(deaths)", expected: 1},
+		{source: "alive != 0xFF || frame == 10", expected: 1},
+		{source: "!(frame >= 10)", expected: 0},
+		{source: "1 + 2 * 3 - -4", expected: 11},
+		{source: "7 / 2 + 7 % 2", expected: 4},
+		{source: "5 / 0", expected: 0},
+		{source: "1 < 2 == 1", expected: 1},
+		{source: "peek(0x3FFF + 1)", expected: 7},
+		{source: "prev(prev(deaths))", expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			x, err := parse(tt.source, names)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := x.eval(c); got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	names := map[string]bool{"score": true}
+	for _, source := range []string{
+		"",
+		"lives > 0",
+		"score >",
+		"(score",
+		"score)",
+		"prev score",
+		"score = 1",
+		"score & 1",
+		"0xZZ",
+	} {
+		if _, err := parse(source, names); err == nil {
+			t.Errorf("expected %q not to parse", source)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package autoplay plays the game without a player, for soak tests and demos. It reads where
+// the ship, the invaders, their bombs and the UFO are from RAM each frame, and holds the
+// controls a player would.
+package autoplay
+
+import (
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// The ship's size and where it can go, in screen pixels
+const (
+	shipWidth  = 16
+	shipTop    = 0x28
+	shipMinX   = 0x10
+	shipMaxX   = 0xB9
+	shipMiddle = shipWidth / 2
+)
+
+// How fast things move, in pixels a frame
+const (
+	shotSpeed = 4
+	// bombSpeed is the fastest the bombs fall, once there are few invaders left
+	bombSpeed = 5
+	// ufoSpeed is 2 pixels every 3 frames
+	ufoSpeed = 2.0 / 3
+)
+
+const (
+	// ufoY is the height the UFO flies at
+	ufoY = 0xD0
+	// ufoMiddle is how far the middle of the UFO is from its left edge
+	ufoMiddle = 12
+	// invaderMiddle is how far the middle of an invader is from its left edge
+	invaderMiddle = 8
+	// invaderLeft and invaderRight are where the widest invader is drawn from and to, from
+	// its left edge, with a pixel to spare either side
+	invaderLeft  = 1
+	invaderRight = 15
+	// aim is how far off the middle of the target a shot can be fired
+	aim = 3
+	// bombMargin is how close a bomb can pass the ship
+	bombMargin = 3
+	// ufoPoints is the most the UFO is worth, the score the bot waits for. It's the 8th shot
+	// of a game, and the 23rd, and every 15th after that. See gamestate.UFOPoints.
+	ufoPoints = 300
+	// ufoDue is how many frames before the UFO comes the bot keeps the shot worth 300 for it.
+	// It's about as long as it takes to fire the 15 shots round to it again.
+	ufoDue = 0x200
+	// ufoMinInvaders is the fewest invaders the UFO comes with
+	ufoMinInvaders = 8
+)
+
+// startPresses is how many frames coins and start buttons are held and let go, so the game
+// sees each press.
+const startPresses = 4
+
+// Bot plays the game for the players picked. It's a source of controls.
+type Bot struct {
+	// Players are the players the bot plays for
+	Players [2]bool
+	// StartGames inserts coins and starts a game when none is being played: a one player
+	// game if the bot only plays player 1, or two player game otherwise
+	StartGames bool
+
+	ram []byte
+	// fired is set when fire was held in the last frame. The ship fires each time fire is
+	// pressed, so it's let go in between.
+	fired bool
+	// frame counts the frames polled, for timing presses
+	frame int
+}
+
+// New returns a bot that reads the game from RAM, playing for no one until players are picked.
+func New(ram []byte) *Bot {
+	return &Bot{ram: ram}
+}
+
+// Enabled reports whether the bot plays for anyone.
+func (b *Bot) Enabled() bool {
+	return b.Players[0] || b.Players[1]
+}
+
+// Poll fulfills input.Source, deciding the controls for a frame.
+func (b *Bot) Poll() input.State {
+	b.frame++
+	state := gamestate.Decode(b.ram)
+	if !state.Playing {
+		b.fired = false
+		if b.StartGames && b.Enabled() {
+			return b.startGame(state)
+		}
+		return 0
+	}
+	if !b.Players[state.Player] || !state.PlayerAlive {
+		b.fired = false
+		return 0
+	}
+
+	move, fire := b.decide(state)
+	fire = fire && !b.fired
+	b.fired = fire
+
+	fireAction, leftAction, rightAction := input.P1Fire, input.P1Left, input.P1Right
+	if state.Player == 1 {
+		fireAction, leftAction, rightAction = input.P2Fire, input.P2Left, input.P2Right
+	}
+	var controls input.State
+	controls = controls.With(fireAction, fire)
+	controls = controls.With(leftAction, move < 0)
+	controls = controls.With(rightAction, move > 0)
+	return controls
+}
+
+// startGame presses the coin and start buttons, one at a time.
+func (b *Bot) startGame(state gamestate.State) input.State {
+	if b.frame/startPresses%2 == 1 {
+		return 0
+	}
+	players := 1
+	if b.Players[1] {
+		players = 2
+	}
+	var controls input.State
+	switch {
+	case state.Credits < players:
+		controls = controls.With(input.Coin, true)
+	case players == 1:
+		controls = controls.With(input.Start1P, true)
+	default:
+		controls = controls.With(input.Start2P, true)
+	}
+	return controls
+}
+
+// decide picks which way to move, -1 for left, 0 to stay and 1 for right, and whether to fire.
+func (b *Bot) decide(state gamestate.State) (move int, fire bool) {
+	shotX := state.PlayerX + shipMiddle
+	targetX, ok := target(state)
+	toward := 0
+	if ok {
+		switch {
+		case targetX > shotX+aim:
+			toward = 1
+		case targetX < shotX-aim:
+			toward = -1
+		}
+	}
+
+	// Head for the target unless it walks into a bomb, otherwise stay or get out of the way.
+	// With nowhere safe, run from the bomb that lands first.
+	move = escape(state, toward)
+	for _, choice := range []int{toward, 0, -toward, 1, -1} {
+		if safe(state, choice) {
+			move = choice
+			break
+		}
+	}
+	ready := !state.Shot.Active && !state.Shot.Exploding
+	fire = ok && ready && abs(targetX-shotX) <= aim
+	if chasingUFO(state) {
+		// A shot at the UFO that hits an invader on the way up wastes the 300
+		return move, fire && !blocked(state, shotX)
+	}
+	return move, fire && !saveShot(state)
+}
+
+// chasingUFO reports whether the UFO is flying and the next shot is worth the most for it.
+func chasingUFO(state gamestate.State) bool {
+	ufo := state.UFO
+	return ufo.Active && !ufo.Exploding && ufo.Points == ufoPoints
+}
+
+// saveShot reports whether to hold fire, keeping the shot worth 300 for a UFO that's due. The
+// shots before it go on the invaders, counting up to it.
+func saveShot(state gamestate.State) bool {
+	ufo := state.UFO
+	if ufo.Active || ufo.Points != ufoPoints || state.InvadersLeft < ufoMinInvaders {
+		return false
+	}
+	return ufo.Due <= ufoDue
+}
+
+// target returns where a shot fired now should be to hit something. It's the UFO when the
+// shot is worth the most, and otherwise the lowest invader, since they're the ones that land
+// first.
+func target(state gamestate.State) (int, bool) {
+	ufo := state.UFO
+	if chasingUFO(state) {
+		// Lead it by how far it flies while the shot goes up
+		flight := float64(ufoY-shipTop) / shotSpeed
+		return ufo.X + ufoMiddle + int(float64(ufo.Direction)*ufoSpeed*flight), true
+	}
+
+	bestRow, bestX := gamestate.Rows, 0
+	for column := range gamestate.Columns {
+		for row := range gamestate.Rows {
+			if !state.Invaders[row][column] {
+				continue
+			}
+			// The lowest in each column, taking the one nearest the ship between equals
+			position := state.InvaderPosition(row, column)
+			x := position.X + invaderMiddle + fleetLead(state, position.Y)
+			if row < bestRow || (row == bestRow && abs(x-state.PlayerX) < abs(bestX-state.PlayerX)) {
+				bestRow, bestX = row, x
+			}
+			break
+		}
+	}
+	return bestX, bestRow < gamestate.Rows
+}
+
+// blocked reports whether a shot fired from x hits an invader on its way up.
+func blocked(state gamestate.State, x int) bool {
+	for row := range gamestate.Rows {
+		for column := range gamestate.Columns {
+			if !state.Invaders[row][column] {
+				continue
+			}
+			position := state.InvaderPosition(row, column)
+			left := position.X + fleetLead(state, position.Y)
+			if x >= left+invaderLeft && x < left+invaderRight {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fleetLead returns how far the fleet moves while a shot goes up to a height. The fleet moves
+// one invader each frame, so it moves faster the fewer there are, and the last one moves 3
+// pixels at a time going right.
+func fleetLead(state gamestate.State, y int) int {
+	frames := (y - shipTop) / shotSpeed
+	step := 2
+	if state.InvadersLeft == 1 && state.FleetDirection > 0 {
+		step = 3
+	}
+	return state.FleetDirection * step * frames / max(state.InvadersLeft, 1)
+}
+
+// safe reports whether moving one way keeps the ship out of the way of every bomb.
+func safe(state gamestate.State, move int) bool {
+	for _, bomb := range state.Bombs {
+		if !bomb.Active || bomb.Position.Y < shipTop-8 {
+			continue
+		}
+		// Where the ship is when the bomb gets down to it, and while it passes through
+		frames := max(bomb.Position.Y-shipTop, 0) / bombSpeed
+		for _, wait := range []int{frames, frames + 2} {
+			x := min(max(state.PlayerX+move*wait, shipMinX), shipMaxX)
+			if bomb.Position.X+2 >= x-bombMargin && bomb.Position.X <= x+shipWidth+bombMargin {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// escape returns the way away from the lowest bomb over the ship, or the way it was going if
+// there isn't one.
+func escape(state gamestate.State, toward int) int {
+	move, lowest := toward, 0
+	for _, bomb := range state.Bombs {
+		if !bomb.Active || bomb.Position.Y < shipTop-8 || (lowest != 0 && bomb.Position.Y > lowest) {
+			continue
+		}
+		move, lowest = 1, bomb.Position.Y
+		if bomb.Position.X > state.PlayerX+shipMiddle {
+			move = -1
+		}
+	}
+	// Running into the wall doesn't help, so the other way is the better bet
+	x := state.PlayerX + move
+	if x < shipMinX || x > shipMaxX {
+		move = -move
+	}
+	return move
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
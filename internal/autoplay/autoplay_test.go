@@ -0,0 +1,204 @@
+package autoplay
+
+import (
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+// newGame returns RAM for a game being played by player 1, with the ship alive at the left
+// wall and one invader left, at the bottom of the fifth column, with the fleet moving left.
+func newGame() []byte {
+	ram := make([]byte, gamestate.RAMSize)
+	ram[gamestate.GameMode] = 1
+	ram[gamestate.CurrentPlayer] = 0x21
+	ram[gamestate.PlayerAlive] = 0xFF
+	ram[gamestate.PlayerX] = 0x30
+	ram[gamestate.FleetY], ram[gamestate.FleetX], ram[gamestate.FleetDelta] = 0x78, 0x38, 0xFE
+	ram[gamestate.Player1Invaders+5] = 1
+	return ram
+}
+
+func TestPoll(t *testing.T) {
+	tests := []struct {
+		name    string
+		players [2]bool
+		setup   func(ram []byte)
+		// fired is whether fire was held last frame
+		fired    bool
+		expected input.State
+	}{
+		{
+			name:    "NotPlaying",
+			players: [2]bool{true, false},
+			setup:   func(ram []byte) { ram[gamestate.GameMode] = 0 },
+		},
+		{
+			name:    "OtherPlayersTurn",
+			players: [2]bool{false, true},
+		},
+		{
+			name:    "ShipDead",
+			players: [2]bool{true, false},
+			setup:   func(ram []byte) { ram[gamestate.PlayerAlive] = 0 },
+		},
+		{
+			name:     "MovesToLowestInvader",
+			players:  [2]bool{true, false},
+			expected: holding(input.P1Right),
+		},
+		{
+			// The fleet moves 40 pixels left while the shot goes up, so the ship fires at 72
+			// for the invader with its middle at 112
+			name:     "FiresWhenLinedUp",
+			players:  [2]bool{true, false},
+			setup:    func(ram []byte) { ram[gamestate.PlayerX] = 0x60 },
+			expected: holding(input.P1Fire),
+		},
+		{
+			name:    "LetsGoOfFire",
+			players: [2]bool{true, false},
+			setup:   func(ram []byte) { ram[gamestate.PlayerX] = 0x60 },
+			fired:   true,
+		},
+		{
+			name:    "PlaysForPlayer2",
+			players: [2]bool{false, true},
+			setup: func(ram []byte) {
+				ram[gamestate.CurrentPlayer] = 0x22
+				ram[gamestate.Player1Invaders+5] = 0
+				ram[gamestate.Player2Invaders+5] = 1
+			},
+			expected: holding(input.P2Right),
+		},
+		{
+			name:    "WaitsForBombToPass",
+			players: [2]bool{true, false},
+			setup: func(ram []byte) {
+				// Just to the right of the ship and about to land
+				ram[gamestate.PlungerShot+5] = 0x80
+				ram[gamestate.PlungerShot+13], ram[gamestate.PlungerShot+14] = 0x30, 0x44
+			},
+		},
+		{
+			name:    "ChasesUFOWorth300",
+			players: [2]bool{true, false},
+			setup: func(ram []byte) {
+				ram[gamestate.PlayerX] = 0xA0
+				ram[gamestate.UFOActive], ram[gamestate.UFOX], ram[gamestate.UFODelta] = 1, 0xB0, 0x02
+				// The next shot is the 8th
+				ram[gamestate.UFOScore] = 0x54 + 7
+			},
+			expected: holding(input.P1Right),
+		},
+		{
+			name:    "SavesShotForUFO",
+			players: [2]bool{true, false},
+			setup: func(ram []byte) {
+				// With 9 invaders the fleet moves 4 pixels left while the shot goes up, so the ship
+				// fires at 108
+				ram[gamestate.PlayerX] = 0x84
+				// Enough invaders for the UFO, behind the one lined up
+				for column := range 8 {
+					ram[gamestate.Player1Invaders+gamestate.Columns+column] = 1
+				}
+				ram[gamestate.UFOScore] = 0x54 + 7
+				ram[gamestate.UFOTimer], ram[gamestate.UFOTimer+1] = 0x80, 0
+			},
+		},
+		{
+			name:    "SpendsShotWhenUFONotDue",
+			players: [2]bool{true, false},
+			setup: func(ram []byte) {
+				ram[gamestate.PlayerX] = 0x84
+				for column := range 8 {
+					ram[gamestate.Player1Invaders+gamestate.Columns+column] = 1
+				}
+				ram[gamestate.UFOScore] = 0x54 + 7
+				ram[gamestate.UFOTimer], ram[gamestate.UFOTimer+1] = 0x00, 0x03
+			},
+			expected: holding(input.P1Fire),
+		},
+		{
+			name:    "IgnoresUFOWorthLess",
+			players: [2]bool{true, false},
+			setup: func(ram []byte) {
+				ram[gamestate.PlayerX] = 0xA0
+				ram[gamestate.UFOActive], ram[gamestate.UFOX], ram[gamestate.UFODelta] = 1, 0xB0, 0x02
+				ram[gamestate.UFOScore] = 0x54
+			},
+			expected: holding(input.P1Left),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ram := newGame()
+			if tt.setup != nil {
+				tt.setup(ram)
+			}
+			bot := New(ram)
+			bot.Players = tt.players
+			bot.fired = tt.fired
+			if got := bot.Poll(); got != tt.expected {
+				t.Errorf("expected %08b, got %08b", tt.expected, got)
+			}
+		})
+	}
+}
+
+// holding returns the controls with one action held.
+func holding(action input.Action) input.State {
+	return input.State(0).With(action, true)
+}
+
+func TestStartsGames(t *testing.T) {
+	vm, hardware := replay.NewMachine()
+	if err := replay.ApplySettings(vm, hardware, movie.Settings{Ships: 3, Cabinet: "Upright"}); err != nil {
+		t.Fatal(err)
+	}
+	bot := New(hardware.RAM())
+	bot.Players[0] = true
+	bot.StartGames = true
+	hardware.Input = bot
+
+	ram := hardware.RAM()
+	for frame := 0; gamestate.Score(ram, gamestate.Player1Score) == 0; frame++ {
+		if frame == 3000 {
+			t.Fatalf("expected the bot to start a game and score, got %+v", gamestate.Decode(ram))
+		}
+		vm.Update()
+	}
+	if state := gamestate.Decode(ram); !state.Playing || state.Player != 0 {
+		t.Errorf("expected a one player game, got %+v", state)
+	}
+}
+
+func TestShootsUFOWorth300(t *testing.T) {
+	vm, hardware := replay.NewMachine()
+	if err := replay.ApplySettings(vm, hardware, movie.Settings{Ships: 3, Cabinet: "Upright"}); err != nil {
+		t.Fatal(err)
+	}
+	ram := hardware.RAM()
+	bot := New(ram)
+	bot.Players[0] = true
+	bot.StartGames = true
+	hardware.Input = bot
+
+	// The first UFO comes about 40 seconds into the game. Nothing else scores 300 at once.
+	scores := gamestate.Scores(ram)
+	for frame := 0; ; frame++ {
+		if frame == 6000 {
+			t.Fatalf("expected the bot to shoot a UFO worth %d, scored %v", ufoPoints, scores)
+		}
+		vm.Update()
+		last := scores
+		scores = gamestate.Scores(ram)
+		if scores[0]-last[0] == ufoPoints {
+			return
+		}
+	}
+}
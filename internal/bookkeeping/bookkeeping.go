@@ -0,0 +1,117 @@
+// Package bookkeeping keeps the operator's books, like the meters in an arcade cabinet: the
+// coins taken, the games played in each mode and how long they were played for. They're kept
+// on disk across runs.
+package bookkeeping
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+)
+
+// The modes games are played in.
+const (
+	OnePlayer  = "1 player"
+	TwoPlayers = "2 players"
+)
+
+// Modes are the modes games are played in, in the order they're shown.
+var Modes = []string{OnePlayer, TwoPlayers}
+
+// FramesPerSecond is how many frames the machine plays each second.
+const FramesPerSecond = 60
+
+// Books are the operator's books.
+type Books struct {
+	// Since is when the books were started
+	Since time.Time `json:"since"`
+	// CoinsIn counts the coins taken. The credits free play adds aren't coins.
+	CoinsIn int `json:"coins_in"`
+	// Games counts the games played by mode. See Modes.
+	Games map[string]int `json:"games"`
+	// PlayFrames is the number of frames played, while a game was on
+	PlayFrames int `json:"play_frames"`
+}
+
+// TotalGames returns the number of games played in any mode.
+func (b *Books) TotalGames() int {
+	total := 0
+	for _, count := range b.Games {
+		total += count
+	}
+	return total
+}
+
+// PlayTime returns how long games were played for.
+func (b *Books) PlayTime() time.Duration {
+	return time.Duration(b.PlayFrames) * time.Second / FramesPerSecond
+}
+
+// Load reads the books from a file. A missing file starts new books.
+func Load(path string) (*Books, error) {
+	b := &Books{}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, b); err != nil {
+			return nil, err
+		}
+	}
+	if b.Since.IsZero() {
+		b.Since = time.Now()
+	}
+	if b.Games == nil {
+		b.Games = map[string]int{}
+	}
+	return b, nil
+}
+
+// Save writes the books to a file.
+func (b *Books) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Meter adds what happens on the machine to the books, after each frame.
+type Meter struct {
+	Books *Books
+
+	ram     []byte
+	playing bool
+}
+
+// NewMeter returns a meter keeping books of the game in RAM.
+func NewMeter(books *Books, ram []byte) *Meter {
+	return &Meter{Books: books, ram: ram}
+}
+
+// Update adds the frame just played, and the coins taken in it, to the books. It reports
+// whether they're worth saving: when a coin was taken or a game ended.
+func (m *Meter) Update(coins int) (changed bool) {
+	m.Books.CoinsIn += coins
+	playing := gamestate.Playing(m.ram)
+	wasPlaying := m.playing
+	m.playing = playing
+
+	if playing {
+		m.Books.PlayFrames++
+		if !wasPlaying {
+			// The mode is set on the frame the game starts
+			mode := OnePlayer
+			if m.ram[gamestate.TwoPlayers] == 1 {
+				mode = TwoPlayers
+			}
+			m.Books.Games[mode]++
+		}
+	}
+	return coins > 0 || wasPlaying && !playing
+}
@@ -0,0 +1,83 @@
+package bookkeeping
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+)
+
+func TestMeter(t *testing.T) {
+	ram := make([]byte, gamestate.RAMSize)
+	books := &Books{Games: map[string]int{}}
+	meter := NewMeter(books, ram)
+
+	tests := []struct {
+		name       string
+		coins      int
+		playing    bool
+		twoPlayers bool
+		changed    bool
+	}{
+		{name: "Demo", changed: false},
+		{name: "Coins in", coins: 2, changed: true},
+		{name: "Two player game starts", playing: true, twoPlayers: true},
+		{name: "Game played", playing: true, twoPlayers: true},
+		{name: "Game over", changed: true},
+		{name: "Coin in", coins: 1, changed: true},
+		{name: "One player game starts", playing: true},
+		{name: "Game over again", changed: true},
+	}
+	for _, tt := range tests {
+		ram[gamestate.GameMode] = 0
+		if tt.playing {
+			ram[gamestate.GameMode] = 1
+		}
+		ram[gamestate.TwoPlayers] = 0
+		if tt.twoPlayers {
+			ram[gamestate.TwoPlayers] = 1
+		}
+		if changed := meter.Update(tt.coins); changed != tt.changed {
+			t.Errorf("%s: expected changed %v, got %v", tt.name, tt.changed, changed)
+		}
+	}
+
+	if books.CoinsIn != 3 {
+		t.Errorf("expected 3 coins in, got %d", books.CoinsIn)
+	}
+	if books.Games[OnePlayer] != 1 || books.Games[TwoPlayers] != 1 || books.TotalGames() != 2 {
+		t.Errorf("expected a game in each mode, got %v", books.Games)
+	}
+	if books.PlayFrames != 3 || books.PlayTime() != 50*time.Millisecond {
+		t.Errorf("expected 3 frames played, got %d", books.PlayFrames)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bookkeeping.json")
+	books, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if books.Since.IsZero() || books.Games == nil {
+		t.Fatalf("expected new books, got %+v", books)
+	}
+
+	books.CoinsIn = 4
+	books.Games[TwoPlayers] = 2
+	books.PlayFrames = 600
+	if err := books.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CoinsIn != 4 || loaded.Games[TwoPlayers] != 2 || loaded.PlayTime() != 10*time.Second {
+		t.Errorf("expected the books back, got %+v", loaded)
+	}
+	if !loaded.Since.Equal(books.Since) {
+		t.Errorf("expected the books started at %v, got %v", books.Since, loaded.Since)
+	}
+}
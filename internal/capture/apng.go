@@ -0,0 +1,165 @@
+package capture
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+	"os"
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+
+// apngControlOffset is where the animation control chunk starts, right after the signature and
+// the header chunk. The frame count is only known at the end, so it is written there last.
+const apngControlOffset = 8 + 12 + 13
+
+// pngChunk is one chunk of a PNG file.
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// apngWriter streams frames to an animated PNG.
+// Each frame is encoded by image/png, and its image data is moved into APNG frame chunks.
+type apngWriter struct {
+	file *os.File
+	w    *bufio.Writer
+	fps  int
+
+	encoder png.Encoder
+	// header is the header chunk of the first frame. Every frame must match it.
+	header []byte
+	// frames is the number of frames written
+	frames int
+	// sequence numbers the animation chunks
+	sequence uint32
+}
+
+func newAPNGWriter(file *os.File, fps int) *apngWriter {
+	return &apngWriter{
+		file:    file,
+		w:       bufio.NewWriter(file),
+		fps:     fps,
+		encoder: png.Encoder{CompressionLevel: png.BestSpeed},
+	}
+}
+
+func (a *apngWriter) writeFrame(frame image.Image) error {
+	var buf bytes.Buffer
+	if err := a.encoder.Encode(&buf, frame); err != nil {
+		return err
+	}
+	chunks, err := readPNGChunks(buf.Bytes())
+	if err != nil {
+		return err
+	}
+
+	var imageData [][]byte
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "IHDR":
+			if a.header == nil {
+				a.header = chunk.data
+				a.w.Write(pngSignature)
+				writePNGChunk(a.w, "IHDR", chunk.data)
+				writePNGChunk(a.w, "acTL", make([]byte, 8))
+			} else if !bytes.Equal(a.header, chunk.data) {
+				return errors.New("frame size or color type changed during APNG recording")
+			}
+		case "IDAT":
+			imageData = append(imageData, chunk.data)
+		case "PLTE", "tRNS":
+			if a.frames == 0 {
+				writePNGChunk(a.w, chunk.kind, chunk.data)
+			}
+		}
+	}
+
+	// Frame control: the whole image, shown for one emulated frame
+	bounds := frame.Bounds()
+	control := make([]byte, 26)
+	binary.BigEndian.PutUint32(control[0:], a.nextSequence())
+	binary.BigEndian.PutUint32(control[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(control[8:], uint32(bounds.Dy()))
+	binary.BigEndian.PutUint16(control[20:], 1)
+	binary.BigEndian.PutUint16(control[22:], uint16(a.fps))
+	writePNGChunk(a.w, "fcTL", control)
+
+	for _, data := range imageData {
+		if a.frames == 0 {
+			// The first frame doubles as the still image shown by viewers without APNG support
+			writePNGChunk(a.w, "IDAT", data)
+		} else {
+			frameData := binary.BigEndian.AppendUint32(nil, a.nextSequence())
+			writePNGChunk(a.w, "fdAT", append(frameData, data...))
+		}
+	}
+	a.frames++
+
+	return a.w.Flush()
+}
+
+func (a *apngWriter) nextSequence() uint32 {
+	sequence := a.sequence
+	a.sequence++
+	return sequence
+}
+
+func (a *apngWriter) close() error {
+	if a.frames > 0 {
+		writePNGChunk(a.w, "IEND", nil)
+	}
+	if err := a.w.Flush(); err != nil {
+		a.file.Close()
+		return err
+	}
+
+	if a.frames > 0 {
+		// Now the frame count is known, fill in the animation control chunk, looping forever
+		control := make([]byte, 8)
+		binary.BigEndian.PutUint32(control, uint32(a.frames))
+		if _, err := a.file.Seek(apngControlOffset, io.SeekStart); err != nil {
+			a.file.Close()
+			return err
+		}
+		if err := writePNGChunk(a.file, "acTL", control); err != nil {
+			a.file.Close()
+			return err
+		}
+	}
+	return a.file.Close()
+}
+
+// readPNGChunks splits an encoded PNG into its chunks.
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errors.New("invalid PNG signature")
+	}
+	data = data[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data))
+		if len(data) < 12+length {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{kind: string(data[4:8]), data: data[8 : 8+length]})
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes a chunk with its length and checksum.
+func writePNGChunk(w io.Writer, kind string, data []byte) error {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	_, err := w.Write(chunk)
+	return err
+}
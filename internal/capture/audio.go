@@ -0,0 +1,145 @@
+// Package capture records gameplay audio and video to files.
+package capture
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-audio/audio"
+	"github.com/go-audio/wav"
+)
+
+// audioCushionFrames is how many frames of audio are held back before writing starts.
+// The audio device pulls audio in bursts that don't line up with frames, so a small
+// cushion smooths them out and avoids writing gaps of silence.
+const audioCushionFrames = 3
+
+// AudioRecorder writes the mixed game audio to a 16-bit PCM WAV file.
+//
+// Audio arrives in whatever chunks the audio device asks for, but the file is written one
+// emulated frame at a time: every call to EndFrame writes exactly one frame's worth of
+// samples. The length of the recording always matches the number of frames recorded, so it
+// lines up with video captured over the same frames. If the audio device falls behind, the
+// gap is filled with silence, and if it runs ahead, the excess is dropped.
+type AudioRecorder struct {
+	mu sync.Mutex
+
+	file    *os.File
+	encoder *wav.Encoder
+	format  *audio.Format
+
+	// samplesPerFrame is how many samples make up one emulated frame, per channel
+	samplesPerFrame float64
+	// pending holds samples received from the mixer that haven't been written yet
+	pending []int16
+	// started is set once the cushion has filled and writing began
+	started bool
+
+	// startFrame and frames record which emulated frames the recording covers
+	startFrame int
+	frames     int
+	// written is the number of samples written so far, per channel
+	written int
+}
+
+// NewAudioRecorder creates a WAV file at path and prepares to record audio in the given format.
+// startFrame is the emulated frame the recording starts on, and fps is the emulated frame rate.
+func NewAudioRecorder(path string, sampleRate, channels, fps, startFrame int) (*AudioRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AudioRecorder{
+		file:            file,
+		encoder:         wav.NewEncoder(file, sampleRate, 16, channels, 1),
+		format:          &audio.Format{NumChannels: channels, SampleRate: sampleRate},
+		samplesPerFrame: float64(sampleRate) / float64(fps),
+		startFrame:      startFrame,
+	}, nil
+}
+
+// Write fulfills io.Writer, receiving mixed signed 16-bit little endian samples.
+func (r *AudioRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := 0; i+1 < len(p); i += 2 {
+		r.pending = append(r.pending, int16(uint16(p[i])|uint16(p[i+1])<<8))
+	}
+	return len(p), nil
+}
+
+// EndFrame writes the audio for one emulated frame.
+func (r *AudioRecorder) EndFrame() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	channels := r.format.NumChannels
+	cushion := int(r.samplesPerFrame*audioCushionFrames) * channels
+	if !r.started {
+		if len(r.pending) < cushion {
+			// Still filling the cushion. Write silence so the recording stays frame aligned.
+			r.frames++
+			return r.writeUntilFrame(nil)
+		}
+		r.started = true
+	}
+
+	// Drop audio if the device has run far ahead of the emulator, keeping the cushion
+	if excess := len(r.pending) - cushion*2; excess > 0 {
+		excess -= excess % channels
+		r.pending = r.pending[excess:]
+	}
+
+	r.frames++
+	return r.writeUntilFrame(r.pending)
+}
+
+// writeUntilFrame writes samples from source until the file covers every recorded frame,
+// padding with silence if source runs out. The caller must hold the lock.
+func (r *AudioRecorder) writeUntilFrame(source []int16) error {
+	channels := r.format.NumChannels
+	target := int(float64(r.frames) * r.samplesPerFrame)
+	count := (target - r.written) * channels
+	if count <= 0 {
+		return nil
+	}
+
+	data := make([]int, count)
+	taken := min(count, len(source))
+	for i := 0; i < taken; i++ {
+		data[i] = int(source[i])
+	}
+	if source != nil {
+		r.pending = r.pending[taken:]
+	}
+
+	r.written = target
+	return r.encoder.Write(&audio.IntBuffer{Format: r.format, Data: data, SourceBitDepth: 16})
+}
+
+// Frames returns the number of emulated frames recorded so far.
+func (r *AudioRecorder) Frames() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.frames
+}
+
+// Close finishes the WAV file, noting the frames it covers in its metadata.
+func (r *AudioRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.encoder.Metadata = &wav.Metadata{
+		Software: "space-invaders",
+		Comments: fmt.Sprintf("Emulated frames %d to %d.", r.startFrame, r.startFrame+r.frames),
+	}
+	if err := r.encoder.Close(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
@@ -0,0 +1,119 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-audio/wav"
+)
+
+// samplesBytes encodes samples as signed 16-bit little endian bytes.
+func samplesBytes(samples []int16) []byte {
+	p := make([]byte, len(samples)*2)
+	for i, sample := range samples {
+		p[i*2] = byte(sample)
+		p[i*2+1] = byte(sample >> 8)
+	}
+	return p
+}
+
+// readWav decodes the samples and metadata comments from a WAV file.
+func readWav(t *testing.T, path string) ([]int, string) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	decoder := wav.NewDecoder(file)
+	buffer, err := decoder.FullPCMBuffer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoder.SampleRate != 600 || decoder.BitDepth != 16 || decoder.NumChans != 1 {
+		t.Errorf("unexpected format: %d Hz, %d bits, %d channels", decoder.SampleRate, decoder.BitDepth, decoder.NumChans)
+	}
+	decoder.ReadMetadata()
+	comments := ""
+	if decoder.Metadata != nil {
+		comments = decoder.Metadata.Comments
+	}
+	return buffer.Data, comments
+}
+
+func TestAudioRecorderFrameAligned(t *testing.T) {
+	tests := []struct {
+		name string
+		// samplesPerWrite is how much audio the device pulls each frame
+		samplesPerWrite int
+	}{
+		{name: "Device keeps pace", samplesPerWrite: 10},
+		{name: "Device falls behind", samplesPerWrite: 4},
+		{name: "Device runs ahead", samplesPerWrite: 25},
+		{name: "No audio at all", samplesPerWrite: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audio.wav")
+			// 10 samples per frame
+			recorder, err := NewAudioRecorder(path, 600, 1, 60, 120)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			frames := 20
+			next := int16(1)
+			for i := 0; i < frames; i++ {
+				samples := make([]int16, tt.samplesPerWrite)
+				for j := range samples {
+					samples[j] = next
+					next++
+				}
+				recorder.Write(samplesBytes(samples))
+				if err := recorder.EndFrame(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := recorder.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, comments := readWav(t, path)
+			if len(data) != frames*10 {
+				t.Errorf("expected %d samples for %d frames, got %d", frames*10, frames, len(data))
+			}
+			if !strings.Contains(comments, "120 to 140") {
+				t.Errorf("expected the frames to be noted in the metadata, got %q", comments)
+			}
+		})
+	}
+}
+
+func TestAudioRecorderKeepsOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audio.wav")
+	recorder, err := NewAudioRecorder(path, 600, 1, 60, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]int16, 50)
+	for i := range samples {
+		samples[i] = int16(i + 1)
+	}
+	recorder.Write(samplesBytes(samples))
+	for i := 0; i < 5; i++ {
+		recorder.EndFrame()
+	}
+	recorder.Close()
+
+	data, _ := readWav(t, path)
+	for i, sample := range data {
+		if sample != i+1 {
+			t.Fatalf("sample %d: expected %d, got %d", i, i+1, sample)
+		}
+	}
+}
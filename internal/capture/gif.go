@@ -0,0 +1,210 @@
+package capture
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"os"
+)
+
+// minGIFDelay is the shortest delay between GIF frames, in hundredths of a second.
+// Most viewers play anything faster than this far too slowly, so frames that would
+// be shown for less time are dropped instead. At 60 FPS that's a third of them; APNG
+// and PNG frames keep every one.
+const minGIFDelay = 2
+
+// gifMaxColors is the most colors a GIF color table holds.
+const gifMaxColors = 256
+
+// gifPalette returns the colors to write a frame with. The standard color schemes draw few
+// enough colors that they're kept exactly, but the CV scheme's overlay and the Cabinet
+// scheme's backdrop and gels can draw far more, so those frames get a fixed palette and are
+// dithered to it. dither reports which.
+func gifPalette(frame image.Image) (palette color.Palette, dither bool) {
+	bounds := frame.Bounds()
+	rgba, ok := frame.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(bounds)
+		draw.Draw(rgba, bounds, frame, bounds.Min, draw.Src)
+	}
+
+	seen := make(map[color.RGBA]bool, gifMaxColors)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := rgba.Pix[rgba.PixOffset(bounds.Min.X, y):rgba.PixOffset(bounds.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			c := color.RGBA{row[i], row[i+1], row[i+2], row[i+3]}
+			if seen[c] {
+				continue
+			}
+			if len(palette) == gifMaxColors {
+				return gifDitherPalette, true
+			}
+			seen[c] = true
+			palette = append(palette, c)
+		}
+	}
+	return palette, false
+}
+
+// gifDitherPalette is the palette frames with too many colors are dithered to.
+var gifDitherPalette = palette.Plan9
+
+// gifPaletteBits returns the size of the color table for a palette, as a power of 2. GIF
+// tables have at least 2 entries.
+func gifPaletteBits(palette color.Palette) int {
+	bits := 1
+	for 1<<bits < len(palette) {
+		bits++
+	}
+	return bits
+}
+
+// gifWriter streams frames to an animated GIF, so long recordings don't have to be held in memory.
+// image/gif can only encode a whole animation at once.
+type gifWriter struct {
+	file *os.File
+	w    *bufio.Writer
+	fps  int
+
+	// frames is the number of frames received
+	frames int
+	// pending is the latest frame kept, which is written once its delay is known
+	pending      *image.Paletted
+	pendingStart int
+}
+
+func newGIFWriter(file *os.File, fps int) *gifWriter {
+	return &gifWriter{file: file, w: bufio.NewWriter(file), fps: fps}
+}
+
+// frameTime returns when the given frame starts, in hundredths of a second.
+func (g *gifWriter) frameTime(frame int) int {
+	return (frame*100 + g.fps/2) / g.fps
+}
+
+func (g *gifWriter) writeFrame(frame image.Image) error {
+	bounds := frame.Bounds()
+	if g.frames == 0 {
+		g.writeHeader(bounds.Dx(), bounds.Dy())
+	}
+
+	start := g.frameTime(g.frames)
+	g.frames++
+
+	if g.pending != nil {
+		delay := start - g.pendingStart
+		if delay < minGIFDelay {
+			// Too soon after the kept frame, drop this one
+			return nil
+		}
+		g.writeImage(g.pending, delay)
+	}
+
+	palette, dither := gifPalette(frame)
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette)
+	if dither {
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, bounds.Min)
+	} else {
+		draw.Draw(paletted, paletted.Bounds(), frame, bounds.Min, draw.Src)
+	}
+	g.pending = paletted
+	g.pendingStart = start
+
+	return g.w.Flush()
+}
+
+func (g *gifWriter) close() error {
+	if g.pending != nil {
+		delay := max(g.frameTime(g.frames)-g.pendingStart, minGIFDelay)
+		g.writeImage(g.pending, delay)
+		// Trailer
+		g.w.WriteByte(0x3B)
+	}
+
+	if err := g.w.Flush(); err != nil {
+		g.file.Close()
+		return err
+	}
+	return g.file.Close()
+}
+
+// writeHeader writes the GIF header, and makes the animation loop forever. Each frame has its
+// own color table, so there's no global one.
+func (g *gifWriter) writeHeader(width, height int) {
+	g.w.WriteString("GIF89a")
+
+	// Logical screen descriptor, with colors of 8 bits per channel
+	binary.Write(g.w, binary.LittleEndian, uint16(width))
+	binary.Write(g.w, binary.LittleEndian, uint16(height))
+	g.w.Write([]byte{0x70, 0x00, 0x00})
+
+	// Application extension to loop forever
+	g.w.Write([]byte{0x21, 0xFF, 0x0B})
+	g.w.WriteString("NETSCAPE2.0")
+	g.w.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+// writeImage writes one frame of the animation, shown for delay hundredths of a second.
+func (g *gifWriter) writeImage(frame *image.Paletted, delay int) {
+	// Graphic control extension, leaving the frame in place when the next is drawn
+	g.w.Write([]byte{0x21, 0xF9, 0x04, 0x04})
+	binary.Write(g.w, binary.LittleEndian, uint16(delay))
+	g.w.Write([]byte{0x00, 0x00})
+
+	// Image descriptor, covering the whole screen, and the frame's color table padded out to
+	// a power of 2
+	g.w.WriteByte(0x2C)
+	bounds := frame.Bounds()
+	binary.Write(g.w, binary.LittleEndian, [4]uint16{0, 0, uint16(bounds.Dx()), uint16(bounds.Dy())})
+	bits := gifPaletteBits(frame.Palette)
+	g.w.WriteByte(0x80 | byte(bits-1))
+	for i := range 1 << bits {
+		var r, gr, b uint32
+		if i < len(frame.Palette) {
+			r, gr, b, _ = frame.Palette[i].RGBA()
+		}
+		g.w.Write([]byte{byte(r >> 8), byte(gr >> 8), byte(b >> 8)})
+	}
+
+	// Compressed image data, split into blocks. The codes start at least 2 bits wide.
+	codeBits := max(bits, 2)
+	g.w.WriteByte(byte(codeBits))
+	blocks := &gifBlockWriter{w: g.w}
+	compressor := lzw.NewWriter(blocks, lzw.LSB, codeBits)
+	compressor.Write(frame.Pix)
+	compressor.Close()
+	blocks.flush()
+	g.w.WriteByte(0x00)
+}
+
+// gifBlockWriter splits data into the length prefixed blocks GIF uses, up to 255 bytes each.
+type gifBlockWriter struct {
+	w      *bufio.Writer
+	buffer [255]byte
+	used   int
+}
+
+func (b *gifBlockWriter) Write(p []byte) (int, error) {
+	for _, value := range p {
+		b.buffer[b.used] = value
+		b.used++
+		if b.used == len(b.buffer) {
+			b.flush()
+		}
+	}
+	return len(p), nil
+}
+
+// flush writes the buffered data as a block.
+func (b *gifBlockWriter) flush() {
+	if b.used == 0 {
+		return
+	}
+	b.w.WriteByte(byte(b.used))
+	b.w.Write(b.buffer[:b.used])
+	b.used = 0
+}
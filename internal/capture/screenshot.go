@@ -0,0 +1,38 @@
+package capture
+
+import (
+	"image"
+	"image/png"
+	"os"
+)
+
+// SavePNG saves an image as a PNG at path.
+func SavePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ScaleImage enlarges an image by a whole number factor, keeping pixels sharp like the display does.
+func ScaleImage(img image.Image, factor int) *image.RGBA {
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx()*factor, bounds.Dy()*factor))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			c := img.At(bounds.Min.X+x, bounds.Min.Y+y)
+			for dy := 0; dy < factor; dy++ {
+				for dx := 0; dx < factor; dx++ {
+					scaled.Set(x*factor+dx, y*factor+dy, c)
+				}
+			}
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,64 @@
+package capture
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScaleImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	red := color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+	green := color.RGBA{0x00, 0xFF, 0x00, 0xFF}
+	img.Set(0, 0, red)
+	img.Set(1, 0, green)
+
+	scaled := ScaleImage(img, 3)
+	if scaled.Bounds().Dx() != 6 || scaled.Bounds().Dy() != 3 {
+		t.Fatalf("expected a 6x3 image, got %v", scaled.Bounds())
+	}
+
+	tests := []struct {
+		x, y     int
+		expected color.RGBA
+	}{
+		{x: 0, y: 0, expected: red},
+		{x: 2, y: 2, expected: red},
+		{x: 3, y: 0, expected: green},
+		{x: 5, y: 2, expected: green},
+	}
+	for _, tt := range tests {
+		if got := scaled.RGBAAt(tt.x, tt.y); got != tt.expected {
+			t.Errorf("pixel (%d, %d): expected %v, got %v", tt.x, tt.y, tt.expected, got)
+		}
+	}
+}
+
+func TestSavePNG(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "screenshot.png")
+	img := image.NewRGBA(image.Rect(0, 0, 224, 256))
+	img.Set(10, 20, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF})
+
+	if err := SavePNG(path, img); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	decoded, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds() != img.Bounds() {
+		t.Errorf("expected bounds %v, got %v", img.Bounds(), decoded.Bounds())
+	}
+	if r, _, _, _ := decoded.At(10, 20).RGBA(); r>>8 != 0xFF {
+		t.Error("expected the lit pixel to be saved")
+	}
+}
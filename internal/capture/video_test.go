@@ -0,0 +1,252 @@
+package capture
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testFrame returns a small frame filled with a single color.
+func testFrame(c color.RGBA) *image.RGBA {
+	frame := image.NewRGBA(image.Rect(0, 0, 8, 4))
+	for i := 0; i < len(frame.Pix); i += 4 {
+		frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return frame
+}
+
+var testColors = []color.RGBA{
+	{0xFF, 0xFF, 0xFF, 0xFF},
+	{0xFF, 0x00, 0x00, 0xFF},
+	{0x00, 0xFF, 0x00, 0xFF},
+	{0x00, 0x00, 0x00, 0xFF},
+}
+
+// recordFrames records count frames, cycling through testColors.
+func recordFrames(t *testing.T, path string, format VideoFormat, fps, count int) {
+	t.Helper()
+	recorder, err := NewVideoRecorder(path, format, fps, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		if err := recorder.AddFrame(testFrame(testColors[i%len(testColors)])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if recorder.Frames() != count {
+		t.Errorf("expected %d frames, got %d", count, recorder.Frames())
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseVideoFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected VideoFormat
+		wantErr  bool
+	}{
+		{name: "gif", expected: GIFVideo},
+		{name: "APNG", expected: APNGVideo},
+		{name: "png", expected: PNGSequence},
+		{name: "mp4", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := ParseVideoFormat(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && format != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, format)
+			}
+		})
+	}
+}
+
+func TestGIFRecording(t *testing.T) {
+	tests := []struct {
+		name   string
+		fps    int
+		frames int
+		// expectedFrames is how many frames are kept, after dropping ones shown too briefly
+		expectedFrames int
+		// expectedTime is the length of the animation, in hundredths of a second
+		expectedTime int
+	}{
+		{name: "60 FPS keeps real time", fps: 60, frames: 60, expectedFrames: 40, expectedTime: 100},
+		{name: "30 FPS keeps every frame", fps: 30, frames: 15, expectedFrames: 15, expectedTime: 50},
+		{name: "Single frame", fps: 60, frames: 1, expectedFrames: 1, expectedTime: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "video.gif")
+			recordFrames(t, path, GIFVideo, tt.fps, tt.frames)
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			animation, err := gif.DecodeAll(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(animation.Image) != tt.expectedFrames {
+				t.Errorf("expected %d frames, got %d", tt.expectedFrames, len(animation.Image))
+			}
+			total := 0
+			for _, delay := range animation.Delay {
+				if delay < minGIFDelay {
+					t.Errorf("frame delay %d is too short", delay)
+				}
+				total += delay
+			}
+			if total != tt.expectedTime {
+				t.Errorf("expected %d hundredths of a second, got %d", tt.expectedTime, total)
+			}
+			if animation.LoopCount != 0 {
+				t.Errorf("expected the animation to loop forever, got %d", animation.LoopCount)
+			}
+			r, g, b, _ := animation.Image[0].At(0, 0).RGBA()
+			if r>>8 != 0xFF || g>>8 != 0xFF || b>>8 != 0xFF {
+				t.Errorf("expected the first frame to be white, got %d %d %d", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
+
+func TestGIFPalette(t *testing.T) {
+	// A few colors the standard schemes don't draw are kept exactly
+	few := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	draw.Draw(few, few.Bounds(), image.NewUniform(color.RGBA{0xE0, 0x70, 0x20, 0xFF}), image.Point{}, draw.Src)
+	few.Set(0, 0, color.RGBA{0x30, 0x60, 0x90, 0xFF})
+	// A gradient like the backdrop's has too many
+	many := image.NewRGBA(few.Bounds())
+	for y := range 32 {
+		for x := range 32 {
+			many.Set(x, y, color.RGBA{uint8(x * 8), uint8(y * 8), 0x40, 0xFF})
+		}
+	}
+
+	tests := []struct {
+		name   string
+		frame  image.Image
+		colors int
+		dither bool
+	}{
+		{name: "Exact", frame: few, colors: 2},
+		{name: "Dithered", frame: many, colors: len(gifDitherPalette), dither: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			palette, dither := gifPalette(tt.frame)
+			if len(palette) != tt.colors || dither != tt.dither {
+				t.Errorf("expected %d colors and dither %v, got %d and %v", tt.colors, tt.dither, len(palette), dither)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "video.gif")
+	recorder, err := NewVideoRecorder(path, GIFVideo, 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, frame := range []image.Image{few, many} {
+		if err := recorder.AddFrame(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	animation, err := gif.DecodeAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(animation.Image) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(animation.Image))
+	}
+	if got := color.RGBAModel.Convert(animation.Image[0].At(1, 1)); got != (color.RGBA{0xE0, 0x70, 0x20, 0xFF}) {
+		t.Errorf("expected the color kept exactly, got %v", got)
+	}
+}
+
+func TestAPNGRecording(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.png")
+	recordFrames(t, path, APNGVideo, 60, 6)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	controls := 0
+	for _, chunk := range chunks {
+		switch chunk.kind {
+		case "acTL":
+			if frames := binary.BigEndian.Uint32(chunk.data); frames != 6 {
+				t.Errorf("expected the animation to have 6 frames, got %d", frames)
+			}
+		case "fcTL":
+			controls++
+			if den := binary.BigEndian.Uint16(chunk.data[22:]); den != 60 {
+				t.Errorf("expected each frame to last 1/60th of a second, got 1/%d", den)
+			}
+		}
+	}
+	if controls != 6 {
+		t.Errorf("expected 6 frame control chunks, got %d", controls)
+	}
+
+	// Viewers without APNG support show the first frame
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	still, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := still.At(0, 0).RGBA(); r>>8 != 0xFF {
+		t.Errorf("expected the still image to be the first frame")
+	}
+}
+
+func TestPNGSequenceRecording(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "video")
+	recordFrames(t, dir, PNGSequence, 60, 3)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 frames, got %d", len(entries))
+	}
+	if entries[1].Name() != "frame-000001.png" {
+		t.Errorf("unexpected frame name %q", entries[1].Name())
+	}
+}
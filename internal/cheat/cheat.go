@@ -0,0 +1,151 @@
+// Package cheat finds and changes values in a machine's memory. A search narrows down where a
+// value is kept by comparing memory between snapshots, and cheats write to memory after every
+// frame, holding values where they are or patching the game's code. Cheats are kept for each
+// ROM, by its hash.
+package cheat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+)
+
+// Address is an address in memory. In a data file it's a number, or a string like "0x21FF".
+type Address uint16
+
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+	n, err := strconv.ParseUint(s, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid address %s", data)
+	}
+	*a = Address(n)
+	return nil
+}
+
+func (a Address) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a Address) String() string {
+	return fmt.Sprintf("0x%04X", uint16(a))
+}
+
+// Patch is a write to memory a cheat makes after every frame.
+type Patch struct {
+	Address Address `json:"address"`
+	// Value is written to the address. Without one, the address is frozen at the value it
+	// had when the cheat was turned on.
+	Value *byte `json:"value,omitempty"`
+}
+
+// Cheat is a named set of patches, turned on and off together.
+type Cheat struct {
+	Name    string  `json:"name"`
+	Patches []Patch `json:"patches"`
+	// Enabled turns the cheat on. It isn't saved, so no game is cheated without asking, and
+	// nothing is frozen at the values memory has as the machine boots.
+	Enabled bool `json:"-"`
+
+	// applied is set while the cheat's patches are being made, with the values frozen and
+	// what the patches to ROM wrote over
+	applied  bool
+	frozen   map[Address]byte
+	original map[Address]byte
+}
+
+// Freeze returns a cheat that freezes an address.
+func Freeze(address Address) *Cheat {
+	return &Cheat{Name: "Freeze " + address.String(), Patches: []Patch{{Address: address}}, Enabled: true}
+}
+
+// Apply makes the patches of the cheats that are on, after a frame. Memory below romSize is
+// ROM, so what's patched there is put back when a cheat is turned off. Cheats turned off
+// leave RAM as it is.
+func Apply(cheats []*Cheat, memory *[0x10000]byte, romSize int) {
+	for _, c := range cheats {
+		if !c.Enabled {
+			if c.applied {
+				for address, b := range c.original {
+					memory[address] = b
+				}
+				c.applied, c.frozen, c.original = false, nil, nil
+			}
+			continue
+		}
+
+		if !c.applied {
+			c.applied = true
+			c.frozen = map[Address]byte{}
+			c.original = map[Address]byte{}
+			for _, patch := range c.Patches {
+				if patch.Value == nil {
+					c.frozen[patch.Address] = memory[patch.Address]
+				} else if int(patch.Address) < romSize {
+					c.original[patch.Address] = memory[patch.Address]
+				}
+			}
+		}
+		for _, patch := range c.Patches {
+			if patch.Value == nil {
+				memory[patch.Address] = c.frozen[patch.Address]
+			} else {
+				memory[patch.Address] = *patch.Value
+			}
+		}
+	}
+}
+
+// Any reports whether any of the cheats are on.
+func Any(cheats []*Cheat) bool {
+	for _, c := range cheats {
+		if c.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// File is the cheats kept on disk, by the hash of the ROM they're for.
+type File struct {
+	ROMs map[string][]*Cheat `json:"roms"`
+}
+
+// Parse reads cheats from a data file's contents.
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if f.ROMs == nil {
+		f.ROMs = map[string][]*Cheat{}
+	}
+	return f, nil
+}
+
+// Load reads cheats from a file. A missing file has none.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &File{ROMs: map[string][]*Cheat{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Save writes the cheats to a file.
+func (f *File) Save(path string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,115 @@
+package cheat
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func value(b byte) *byte {
+	return &b
+}
+
+func TestApply(t *testing.T) {
+	memory := &[0x10000]byte{}
+	memory[0x0100] = 0x32
+	memory[0x2000] = 3
+	memory[0x2001] = 7
+
+	patch := &Cheat{Name: "Patch", Enabled: true, Patches: []Patch{{Address: 0x0100, Value: value(0)}, {Address: 0x2000, Value: value(9)}}}
+	freeze := &Cheat{Name: "Freeze", Enabled: true, Patches: []Patch{{Address: 0x2001}}}
+	cheats := []*Cheat{patch, freeze}
+
+	Apply(cheats, memory, 0x2000)
+	memory[0x2000], memory[0x2001] = 2, 6
+	Apply(cheats, memory, 0x2000)
+	if memory[0x0100] != 0 || memory[0x2000] != 9 {
+		t.Errorf("expected the values patched, got %#x and %d", memory[0x0100], memory[0x2000])
+	}
+	if memory[0x2001] != 7 {
+		t.Errorf("expected the frozen value to stay 7, got %d", memory[0x2001])
+	}
+
+	// Turning the cheats off puts the ROM back and leaves RAM as it is
+	patch.Enabled, freeze.Enabled = false, false
+	Apply(cheats, memory, 0x2000)
+	memory[0x2001] = 6
+	Apply(cheats, memory, 0x2000)
+	if memory[0x0100] != 0x32 || memory[0x2000] != 9 || memory[0x2001] != 6 {
+		t.Errorf("expected the ROM put back and RAM left, got %#x, %d and %d", memory[0x0100], memory[0x2000], memory[0x2001])
+	}
+
+	// Freezing again holds the value it has then
+	freeze.Enabled = true
+	Apply(cheats, memory, 0x2000)
+	memory[0x2001] = 1
+	Apply(cheats, memory, 0x2000)
+	if memory[0x2001] != 6 {
+		t.Errorf("expected the value frozen at 6, got %d", memory[0x2001])
+	}
+	if !Any(cheats) {
+		t.Error("expected a cheat on")
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected Address
+		err      bool
+	}{
+		{data: `8703`, expected: 0x21FF},
+		{data: `"0x21FF"`, expected: 0x21FF},
+		{data: `"0x10000"`, err: true},
+		{data: `"lives"`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			var a Address
+			err := json.Unmarshal([]byte(tt.data), &a)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got %v", a)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, a)
+			}
+		})
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cheats.json")
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ROMs["abc"] = []*Cheat{
+		{Name: "Infinite lives", Patches: []Patch{{Address: 0x21FF, Value: value(3)}}, Enabled: true},
+		Freeze(0x20F8),
+	}
+	if err := f.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheats := loaded.ROMs["abc"]
+	if len(cheats) != 2 || cheats[0].Name != "Infinite lives" || *cheats[0].Patches[0].Value != 3 {
+		t.Errorf("expected the cheats saved, got %+v", cheats)
+	}
+	if cheats[0].Enabled || cheats[1].Enabled {
+		t.Error("expected the cheats to load turned off")
+	}
+	if cheats[1].Name != "Freeze 0x20F8" || cheats[1].Patches[0].Address != 0x20F8 || cheats[1].Patches[0].Value != nil {
+		t.Errorf("expected the freeze saved, got %+v", cheats[1])
+	}
+}
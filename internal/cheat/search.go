@@ -0,0 +1,99 @@
+package cheat
+
+import "slices"
+
+// Comparison picks the addresses whose values compare a way with the last snapshot.
+type Comparison int
+
+const (
+	// Equal keeps the addresses holding a value
+	Equal Comparison = iota
+	Changed
+	Unchanged
+	Increased
+	Decreased
+)
+
+var ComparisonNames = []string{
+	"Equal to",
+	"Changed",
+	"Unchanged",
+	"Increased",
+	"Decreased",
+}
+
+// Result is an address a search has kept.
+type Result struct {
+	Address Address
+	// Value is its value now, and Previous at the snapshot before
+	Value    byte
+	Previous byte
+}
+
+// Search narrows down where a value is kept, from a range of memory. It starts with every
+// address in the range, and each comparison keeps those that compare with the last snapshot,
+// taking a new one.
+type Search struct {
+	memory     *[0x10000]byte
+	start, end int
+	// candidates are the addresses still in the running
+	candidates []Address
+	// snapshot is memory at the last comparison, and previous at the one before
+	snapshot [0x10000]byte
+	previous [0x10000]byte
+}
+
+// NewSearch starts a search of memory from start up to end, taking a snapshot.
+func NewSearch(memory *[0x10000]byte, start, end int) *Search {
+	s := &Search{memory: memory, start: start, end: end}
+	s.Reset()
+	return s
+}
+
+// Reset starts the search again, with every address in its range.
+func (s *Search) Reset() {
+	s.candidates = s.candidates[:0]
+	for address := s.start; address < s.end; address++ {
+		s.candidates = append(s.candidates, Address(address))
+	}
+	s.snapshot = *s.memory
+	s.previous = s.snapshot
+}
+
+// Compare keeps the addresses whose values compare with the last snapshot, or with value for
+// Equal, and takes a new snapshot.
+func (s *Search) Compare(comparison Comparison, value byte) {
+	s.candidates = slices.DeleteFunc(s.candidates, func(address Address) bool {
+		now, then := s.memory[address], s.snapshot[address]
+		switch comparison {
+		case Equal:
+			return now != value
+		case Changed:
+			return now == then
+		case Unchanged:
+			return now != then
+		case Increased:
+			return now <= then
+		case Decreased:
+			return now >= then
+		}
+		return true
+	})
+	s.previous = s.snapshot
+	s.snapshot = *s.memory
+}
+
+// Count returns how many addresses are left.
+func (s *Search) Count() int {
+	return len(s.candidates)
+}
+
+// Results returns up to limit of the addresses left, with their values at the last two
+// snapshots.
+func (s *Search) Results(limit int) []Result {
+	var results []Result
+	for _, address := range s.candidates[:min(limit, len(s.candidates))] {
+		results = append(results, Result{Address: address, Value: s.snapshot[address], Previous: s.previous[address]})
+	}
+	return results
+}
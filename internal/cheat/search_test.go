@@ -0,0 +1,49 @@
+package cheat
+
+import "testing"
+
+func TestSearch(t *testing.T) {
+	memory := &[0x10000]byte{}
+	memory[0x2010] = 3
+	memory[0x2020] = 3
+	memory[0x2030] = 5
+	search := NewSearch(memory, 0x2000, 0x2040)
+	if search.Count() != 0x40 {
+		t.Fatalf("expected every address, got %d", search.Count())
+	}
+
+	steps := []struct {
+		name       string
+		change     func()
+		comparison Comparison
+		value      byte
+		expected   []Address
+	}{
+		{name: "Equal", change: func() {}, comparison: Equal, value: 3, expected: []Address{0x2010, 0x2020}},
+		{name: "Unchanged", change: func() {}, comparison: Unchanged, expected: []Address{0x2010, 0x2020}},
+		{name: "Decreased", change: func() { memory[0x2010], memory[0x2020] = 2, 4 }, comparison: Decreased, expected: []Address{0x2010}},
+		{name: "Changed", change: func() { memory[0x2010] = 1 }, comparison: Changed, expected: []Address{0x2010}},
+		{name: "Increased", change: func() {}, comparison: Increased, expected: nil},
+	}
+	for _, step := range steps {
+		step.change()
+		search.Compare(step.comparison, step.value)
+		results := search.Results(10)
+		if len(results) != len(step.expected) {
+			t.Fatalf("%s: expected %v, got %+v", step.name, step.expected, results)
+		}
+		for i, result := range results {
+			if result.Address != step.expected[i] {
+				t.Errorf("%s: expected %v, got %v", step.name, step.expected[i], result.Address)
+			}
+		}
+	}
+
+	search.Reset()
+	memory[0x2030] = 6
+	search.Compare(Increased, 0)
+	results := search.Results(10)
+	if len(results) != 1 || results[0] != (Result{Address: 0x2030, Value: 6, Previous: 5}) {
+		t.Errorf("expected 0x2030 to have gone from 5 to 6, got %+v", results)
+	}
+}
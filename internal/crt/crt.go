@@ -0,0 +1,245 @@
+// Package crt imitates how a CRT monitor displays a picture, on the CPU.
+//
+// The effects work on plain RGBA framebuffers so they can run, and be tested, without a GPU.
+package crt
+
+import "math"
+
+// Settings control the strength of each effect. Every value is from 0, which turns the effect off, to 1.
+type Settings struct {
+	// PhosphorDecay is how much of the previous frame remains lit. The phosphors of a CRT
+	// fade slowly, so moving sprites leave trails.
+	PhosphorDecay float64
+	// Scanlines darkens the gaps between the lines the electron beam draws.
+	Scanlines float64
+	// Bloom spreads bright pixels sideways, as the beam blurs along each line.
+	Bloom float64
+	// Curvature bends the picture like the curved glass of the tube.
+	Curvature float64
+}
+
+// Enabled reports whether any effect is turned on.
+func (s Settings) Enabled() bool {
+	return s != Settings{}
+}
+
+// Preset is a named combination of settings.
+type Preset int
+
+const (
+	Off Preset = iota
+	Subtle
+	Arcade
+	Worn
+)
+
+// PresetNames are the names of the presets.
+var PresetNames = []string{
+	"Off",
+	"Subtle",
+	"Arcade",
+	"Worn",
+}
+
+var presetSettings = []Settings{
+	Off:    {},
+	Subtle: {PhosphorDecay: 0.3, Scanlines: 0.25, Bloom: 0.15},
+	Arcade: {PhosphorDecay: 0.55, Scanlines: 0.45, Bloom: 0.3, Curvature: 0.3},
+	Worn:   {PhosphorDecay: 0.75, Scanlines: 0.6, Bloom: 0.5, Curvature: 0.6},
+}
+
+// Settings returns the settings for the preset.
+func (p Preset) Settings() Settings {
+	if p < 0 || int(p) >= len(presetSettings) {
+		return Settings{}
+	}
+	return presetSettings[p]
+}
+
+// maxCurvature is how far the corners of the picture are pulled in at full curvature,
+// as a fraction of the picture size.
+const maxCurvature = 0.12
+
+// Processor applies CRT effects to frames. It remembers the previous frame for phosphor decay.
+type Processor struct {
+	Settings Settings
+
+	width, height int
+	scale         int
+
+	// phosphor holds how brightly each channel of each pixel glows, at native resolution
+	phosphor []float64
+	// glow is the phosphor with bloom applied, reused between frames
+	glow []float64
+	// scaled is the enlarged picture before curvature, reused between frames
+	scaled []byte
+	// output is the finished frame
+	output []byte
+
+	// warp maps each output pixel to the offset it samples in scaled, or -1 for black.
+	// It only changes with the curvature, so it is kept between frames.
+	warp       []int
+	warpAmount float64
+}
+
+// NewProcessor creates a processor for RGBA frames of the given size, enlarged by scale in the output.
+func NewProcessor(width, height, scale int) *Processor {
+	return &Processor{
+		width:    width,
+		height:   height,
+		scale:    scale,
+		phosphor: make([]float64, width*height*3),
+		glow:     make([]float64, width*height*3),
+		scaled:   make([]byte, width*height*scale*scale*4),
+		output:   make([]byte, width*height*scale*scale*4),
+	}
+}
+
+// Size returns the width and height of the processed frames.
+func (p *Processor) Size() (width, height int) {
+	return p.width * p.scale, p.height * p.scale
+}
+
+// Reset clears the phosphor, removing any trails.
+func (p *Processor) Reset() {
+	for i := range p.phosphor {
+		p.phosphor[i] = 0
+	}
+}
+
+// Process applies the effects to an RGBA frame and returns the enlarged result as RGBA.
+// The returned slice is reused by the next call.
+func (p *Processor) Process(pixels []byte) []byte {
+	p.Decay(pixels)
+	return p.Render()
+}
+
+// Decay lights the phosphor with a new RGBA frame, keeping what remains of the previous one.
+// Call it once for each frame the picture shows, so the trails fade at the frame rate.
+func (p *Processor) Decay(pixels []byte) {
+	persistence := clamp(p.Settings.PhosphorDecay)
+	for i := 0; i < p.width*p.height; i++ {
+		for channel := 0; channel < 3; channel++ {
+			lit := float64(pixels[i*4+channel])
+			remaining := p.phosphor[i*3+channel] * persistence
+			if lit > remaining {
+				remaining = lit
+			}
+			p.phosphor[i*3+channel] = remaining
+		}
+	}
+}
+
+// Render applies the rest of the effects to the phosphor and returns the enlarged result as
+// RGBA, without fading it. The returned slice is reused by the next call.
+func (p *Processor) Render() []byte {
+	p.bloom()
+	p.enlarge()
+	p.curve()
+	return p.output
+}
+
+// bloom spreads each pixel into its neighbors on the same line.
+func (p *Processor) bloom() {
+	strength := clamp(p.Settings.Bloom)
+	if strength == 0 {
+		copy(p.glow, p.phosphor)
+		return
+	}
+
+	// Weights for the neighbors one and two pixels away
+	near, far := strength*0.5, strength*0.25
+	row := p.width * 3
+	for y := 0; y < p.height; y++ {
+		line := p.phosphor[y*row : (y+1)*row]
+		glow := p.glow[y*row : (y+1)*row]
+		for i := range line {
+			x := i / 3
+			value := line[i]
+			if x >= 1 {
+				value += line[i-3] * near
+			}
+			if x+1 < p.width {
+				value += line[i+3] * near
+			}
+			if x >= 2 {
+				value += line[i-6] * far
+			}
+			if x+2 < p.width {
+				value += line[i+6] * far
+			}
+			glow[i] = math.Min(value, 0xFF)
+		}
+	}
+}
+
+// enlarge scales the picture up, darkening the last row of each line for scanlines.
+func (p *Processor) enlarge() {
+	outWidth, outHeight := p.Size()
+	darken := 1 - clamp(p.Settings.Scanlines)
+	for oy := 0; oy < outHeight; oy++ {
+		level := 1.0
+		if p.scale > 1 && oy%p.scale == p.scale-1 {
+			level = darken
+		}
+		glow := p.glow[(oy/p.scale)*p.width*3:]
+		scaled := p.scaled[oy*outWidth*4 : (oy+1)*outWidth*4]
+		for x := 0; x < p.width; x++ {
+			r, g, b := byte(glow[x*3]*level), byte(glow[x*3+1]*level), byte(glow[x*3+2]*level)
+			for i := 0; i < p.scale; i++ {
+				index := (x*p.scale + i) * 4
+				scaled[index], scaled[index+1], scaled[index+2], scaled[index+3] = r, g, b, 0xFF
+			}
+		}
+	}
+}
+
+// curve bends the picture like the glass of the tube. Areas outside the bent picture are black.
+func (p *Processor) curve() {
+	amount := clamp(p.Settings.Curvature) * maxCurvature
+	if amount == 0 {
+		copy(p.output, p.scaled)
+		return
+	}
+	if p.warp == nil || p.warpAmount != amount {
+		p.buildWarp(amount)
+	}
+
+	for i, source := range p.warp {
+		index := i * 4
+		if source < 0 {
+			p.output[index], p.output[index+1], p.output[index+2], p.output[index+3] = 0, 0, 0, 0xFF
+			continue
+		}
+		copy(p.output[index:index+4], p.scaled[source:source+4])
+	}
+}
+
+// buildWarp works out where each output pixel samples the enlarged picture from, for the given curvature.
+func (p *Processor) buildWarp(amount float64) {
+	outWidth, outHeight := p.Size()
+	p.warp = make([]int, outWidth*outHeight)
+	p.warpAmount = amount
+	for oy := 0; oy < outHeight; oy++ {
+		// Position from the center, from -1 to 1
+		v := (float64(oy)+0.5)/float64(outHeight)*2 - 1
+		for ox := 0; ox < outWidth; ox++ {
+			u := (float64(ox)+0.5)/float64(outWidth)*2 - 1
+
+			// Barrel distortion: the further from the center, the further out to sample
+			distortion := 1 + amount*(u*u+v*v)
+			sx := int(math.Floor((u*distortion + 1) / 2 * float64(outWidth)))
+			sy := int(math.Floor((v*distortion + 1) / 2 * float64(outHeight)))
+
+			if sx < 0 || sx >= outWidth || sy < 0 || sy >= outHeight {
+				p.warp[oy*outWidth+ox] = -1
+			} else {
+				p.warp[oy*outWidth+ox] = (sy*outWidth + sx) * 4
+			}
+		}
+	}
+}
+
+func clamp(value float64) float64 {
+	return math.Max(0, math.Min(value, 1))
+}
@@ -0,0 +1,154 @@
+package crt
+
+import "testing"
+
+// frame returns a width x height RGBA frame with the listed pixels lit white.
+func frame(width, height int, lit ...[2]int) []byte {
+	pixels := make([]byte, width*height*4)
+	for i := 3; i < len(pixels); i += 4 {
+		pixels[i] = 0xFF
+	}
+	for _, pixel := range lit {
+		index := (pixel[1]*width + pixel[0]) * 4
+		pixels[index], pixels[index+1], pixels[index+2] = 0xFF, 0xFF, 0xFF
+	}
+	return pixels
+}
+
+// red returns the red channel of a pixel in an output frame.
+func red(p *Processor, output []byte, x, y int) byte {
+	width, _ := p.Size()
+	return output[(y*width+x)*4]
+}
+
+func TestProcessOff(t *testing.T) {
+	p := NewProcessor(4, 4, 2)
+	output := p.Process(frame(4, 4, [2]int{1, 2}))
+
+	width, height := p.Size()
+	if width != 8 || height != 8 {
+		t.Fatalf("expected an 8x8 output, got %dx%d", width, height)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			expected := byte(0)
+			if x/2 == 1 && y/2 == 2 {
+				expected = 0xFF
+			}
+			if got := red(p, output, x, y); got != expected {
+				t.Errorf("pixel (%d, %d): expected %d, got %d", x, y, expected, got)
+			}
+		}
+	}
+}
+
+func TestPhosphorDecay(t *testing.T) {
+	tests := []struct {
+		name     string
+		decay    float64
+		expected []byte
+	}{
+		{name: "No decay", decay: 0, expected: []byte{0xFF, 0, 0}},
+		{name: "Half decay", decay: 0.5, expected: []byte{0xFF, 127, 63}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewProcessor(2, 2, 1)
+			p.Settings.PhosphorDecay = tt.decay
+
+			// The pixel is lit for one frame, then fades
+			frames := [][]byte{frame(2, 2, [2]int{0, 0}), frame(2, 2), frame(2, 2)}
+			for i, f := range frames {
+				if got := red(p, p.Process(f), 0, 0); got != tt.expected[i] {
+					t.Errorf("frame %d: expected %d, got %d", i, tt.expected[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderDoesNotDecay(t *testing.T) {
+	p := NewProcessor(2, 2, 1)
+	p.Settings.PhosphorDecay = 0.5
+	p.Decay(frame(2, 2, [2]int{0, 0}))
+	p.Decay(frame(2, 2))
+
+	// Drawing the same frame again mustn't fade the trail any further
+	for i := 0; i < 3; i++ {
+		if got := red(p, p.Render(), 0, 0); got != 127 {
+			t.Errorf("render %d: expected 127, got %d", i, got)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	p := NewProcessor(2, 2, 1)
+	p.Settings.PhosphorDecay = 1
+	p.Process(frame(2, 2, [2]int{0, 0}))
+	p.Reset()
+
+	if got := red(p, p.Process(frame(2, 2)), 0, 0); got != 0 {
+		t.Errorf("expected trails to be cleared, got %d", got)
+	}
+}
+
+func TestScanlines(t *testing.T) {
+	p := NewProcessor(1, 2, 3)
+	p.Settings.Scanlines = 0.5
+	output := p.Process(frame(1, 2, [2]int{0, 0}, [2]int{0, 1}))
+
+	// The last row of each line is darkened
+	expected := []byte{0xFF, 0xFF, 127, 0xFF, 0xFF, 127}
+	for y, value := range expected {
+		if got := red(p, output, 0, y); got != value {
+			t.Errorf("row %d: expected %d, got %d", y, value, got)
+		}
+	}
+}
+
+func TestBloom(t *testing.T) {
+	p := NewProcessor(5, 1, 1)
+	p.Settings.Bloom = 1
+	output := p.Process(frame(5, 1, [2]int{2, 0}))
+
+	expected := []byte{63, 127, 0xFF, 127, 63}
+	for x, value := range expected {
+		if got := red(p, output, x, 0); got != value {
+			t.Errorf("pixel %d: expected %d, got %d", x, value, got)
+		}
+	}
+}
+
+func TestCurvature(t *testing.T) {
+	lit := [][2]int{}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			lit = append(lit, [2]int{x, y})
+		}
+	}
+	p := NewProcessor(20, 20, 1)
+	p.Settings.Curvature = 1
+	output := p.Process(frame(20, 20, lit...))
+
+	if got := red(p, output, 0, 0); got != 0 {
+		t.Errorf("expected the corner to bend out of the picture, got %d", got)
+	}
+	if got := red(p, output, 10, 10); got != 0xFF {
+		t.Errorf("expected the center to be unchanged, got %d", got)
+	}
+}
+
+func TestPresets(t *testing.T) {
+	if len(PresetNames) != len(presetSettings) {
+		t.Fatalf("expected a name for each of the %d presets, got %d", len(presetSettings), len(PresetNames))
+	}
+	if Off.Settings().Enabled() {
+		t.Error("expected the Off preset to disable every effect")
+	}
+	for preset := Subtle; int(preset) < len(PresetNames); preset++ {
+		if !preset.Settings().Enabled() {
+			t.Errorf("expected the %s preset to enable effects", PresetNames[preset])
+		}
+	}
+}
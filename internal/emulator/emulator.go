@@ -3,6 +3,7 @@ package emulator
 import (
 	"fmt"
 	"os"
+	"slices"
 	"sync"
 	"time"
 
@@ -58,6 +59,8 @@ type CPU8080 struct {
 	// For timing sync
 	cycleCount  int
 	totalCycles int
+	// frameCount is the number of frames emulated so far
+	frameCount int
 	// Hardware is the struct holding HardwareIO device interface methods
 	Hardware HardwareIO
 	// Mutex for thread-safe access to the CPU state
@@ -66,6 +69,11 @@ type CPU8080 struct {
 	interruptsEnabled bool
 	// InterruptRequest is a channel to request an interrupt by sending an opcode.
 	InterruptRequest chan byte
+	// scheduledInterrupts are raised by runCycles as the frame's cycle count reaches them,
+	// in order of their cycle. See ScheduleInterrupts.
+	scheduledInterrupts []Interrupt
+	// nextInterrupt is the index of the next scheduled interrupt to raise in this frame
+	nextInterrupt int
 }
 
 // EmulatorOptions describe tunable settings about emulator execution
@@ -118,6 +126,10 @@ func NewEmulator(io HardwareIO) *CPU8080 {
 
 	// Give the hardware initialization time with the hardware
 	vm.Hardware.Init(&vm.Memory)
+	// Hardware that cares about CPU timing gets a view of the cycle counter
+	if clocked, ok := io.(ClockedHardware); ok {
+		clocked.SetClock(func() int { return vm.totalCycles })
+	}
 
 	// Define all supported opcodes
 	vm.opcodeTable = map[byte]opcodeExec{
@@ -372,6 +384,26 @@ func (vm *CPU8080) StartInterruptRoutines() {
 	}
 }
 
+// ScheduleInterrupts raises each interrupt condition from the CPU loop, as soon as the frame's
+// cycle count reaches it, instead of from goroutines like StartInterruptRoutines. Interrupts then
+// land on the same instruction every run, so the same inputs always give the same game.
+func (vm *CPU8080) ScheduleInterrupts() {
+	vm.scheduledInterrupts = slices.Clone(vm.Hardware.InterruptConditions())
+	slices.SortStableFunc(vm.scheduledInterrupts, func(a, b Interrupt) int {
+		return a.Cycle - b.Cycle
+	})
+}
+
+// raiseScheduledInterrupts raises the scheduled interrupts the frame's cycle count has reached.
+func (vm *CPU8080) raiseScheduledInterrupts() {
+	for vm.nextInterrupt < len(vm.scheduledInterrupts) && vm.cycleCount >= vm.scheduledInterrupts[vm.nextInterrupt].Cycle {
+		if vm.interruptsEnabled {
+			vm.scheduledInterrupts[vm.nextInterrupt].Action(vm)
+		}
+		vm.nextInterrupt++
+	}
+}
+
 // runCycles executes the CPU for cycleCount amount of times.
 // This is the main execution loop of the emulator.
 func (vm *CPU8080) runCycles(cycleCount int) {
@@ -381,6 +413,7 @@ func (vm *CPU8080) runCycles(cycleCount int) {
 	if vm.Options.LimitTPS {
 		startTime = time.Now()
 	}
+	vm.nextInterrupt = 0
 
 	for vm.cycleCount < cycleCount {
 		select {
@@ -410,6 +443,7 @@ func (vm *CPU8080) runCycles(cycleCount int) {
 			} else {
 				vm.Logger.Fatal("unsupported", "address", fmt.Sprintf("%04X", vm.PC-1), "opcode", fmt.Sprintf("%02X", op), "totalCycles", vm.totalCycles)
 			}
+			vm.raiseScheduledInterrupts()
 		}
 	}
 
@@ -511,14 +545,31 @@ func toUint16(high, low byte) uint16 {
 // This runs the emulator for one frame.
 func (vm *CPU8080) Update() error {
 
+	observer, observing := vm.Hardware.(FrameObserver)
+	// Let the hardware know a frame is starting
+	if observing {
+		observer.StartFrame()
+	}
+
 	// Reset cycle count
 	vm.cycleCount = 0
 	// Execute opcodes
 	vm.runCycles(vm.Hardware.CyclesPerFrame())
+	vm.frameCount++
+	// Let the hardware know the frame is done
+	if observing {
+		observer.EndFrame()
+	}
 
 	return nil
 }
 
+// FrameCount returns the number of frames emulated so far.
+// Captures use it to line up with each other.
+func (vm *CPU8080) FrameCount() int {
+	return vm.frameCount
+}
+
 // Draw fulfills the Game interface for ebiten
 func (vm *CPU8080) Draw(screen *ebiten.Image) {
 	// Use hardware to draw on the display
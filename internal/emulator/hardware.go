@@ -71,6 +71,24 @@ type HardwareIO interface {
 	Cleanup()
 }
 
+// ClockedHardware is an optional interface for hardware that needs to know when, in CPU time,
+// an I/O operation happened. This is useful for devices like sound circuits that must react
+// to port writes with sub-frame accuracy.
+type ClockedHardware interface {
+	// SetClock is called once by NewEmulator with a function that reports the total number
+	// of CPU cycles executed so far.
+	SetClock(clock func() int)
+}
+
+// FrameObserver is an optional interface for hardware that needs to do work at the start and
+// end of every emulated frame.
+type FrameObserver interface {
+	// StartFrame is called before the CPU executes a frame's worth of cycles.
+	StartFrame()
+	// EndFrame is called after the CPU has executed a frame's worth of cycles.
+	EndFrame()
+}
+
 // NullHardware implements HardwareIO with no-op methods for testing purposes.
 type NullHardware struct{}
 
@@ -0,0 +1,58 @@
+package emulator
+
+import "testing"
+
+// interruptHardware runs a ROM of NOPs and records the cycle each interrupt is raised on.
+type interruptHardware struct {
+	NullHardware
+	raised []int
+}
+
+func (h *interruptHardware) ROM() []byte {
+	return make([]byte, 0x2000)
+}
+
+func (h *interruptHardware) InterruptConditions() []Interrupt {
+	record := func(vm *CPU8080) {
+		h.raised = append(h.raised, vm.cycleCount)
+	}
+	// Out of order, to check they are sorted
+	return []Interrupt{
+		{Cycle: 1000, Action: record},
+		{Cycle: 402, Action: record},
+	}
+}
+
+func TestScheduleInterrupts(t *testing.T) {
+	hardware := &interruptHardware{}
+	vm := NewEmulator(hardware)
+	vm.ScheduleInterrupts()
+
+	for frame := 0; frame < 2; frame++ {
+		vm.cycleCount = 0
+		vm.runCycles(1200)
+	}
+
+	// NOPs take 4 cycles, so the interrupts land on the first instruction to reach their cycle
+	expected := []int{404, 1000, 404, 1000}
+	if len(hardware.raised) != len(expected) {
+		t.Fatalf("expected %d interrupts, got %v", len(expected), hardware.raised)
+	}
+	for i, cycle := range expected {
+		if hardware.raised[i] != cycle {
+			t.Errorf("interrupt %d: expected cycle %d, got %d", i, cycle, hardware.raised[i])
+		}
+	}
+}
+
+func TestScheduledInterruptsSkippedWhileDisabled(t *testing.T) {
+	hardware := &interruptHardware{}
+	vm := NewEmulator(hardware)
+	vm.ScheduleInterrupts()
+	vm.interruptsEnabled = false
+
+	vm.runCycles(1200)
+	if len(hardware.raised) != 0 {
+		t.Errorf("expected no interrupts while disabled, got %v", hardware.raised)
+	}
+}
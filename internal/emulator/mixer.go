@@ -0,0 +1,320 @@
+package emulator
+
+import (
+	"io"
+	"math"
+	"sync"
+)
+
+// maxVoicesPerSound limits how many copies of the same sound can play on top of each other.
+// When the limit is reached, the oldest copy is cut off.
+const maxVoicesPerSound = 4
+
+// voice is one playing instance of a sound.
+type voice struct {
+	name     string
+	samples  []int16
+	position int
+}
+
+// mixerStream is a continuous audio source, like a synthesizer.
+type mixerStream struct {
+	reader  io.Reader
+	playing bool
+	scratch []byte
+}
+
+// Mixer combines every playing sound into a single stream of signed 16-bit little endian samples.
+// It applies a volume to each sound, a master volume on top of that, and can be muted.
+// Sounds can also duck the others, lowering them while they play.
+// Mixer fulfills io.Reader so it can feed an audio player.
+type Mixer struct {
+	mu sync.Mutex
+
+	// sounds holds the decoded samples for each named sound
+	sounds map[string][]int16
+	// streams holds the continuous sources, by name
+	streams map[string]*mixerStream
+	// voices are the sounds currently playing
+	voices []*voice
+
+	// masterVolume scales the final mix, from 0 to 1
+	masterVolume float64
+	// volumes holds the volume for each sound or stream, from 0 to 1. Missing entries are at full volume.
+	volumes map[string]float64
+	// muted silences the output without losing the volume settings
+	muted bool
+
+	// ducks maps a sound to the gain applied to every other sound while it plays.
+	// This keeps important sounds audible over busy background sounds.
+	ducks map[string]float64
+	// duckGain is the ducking gain currently applied. It moves smoothly towards
+	// its target to avoid clicks.
+	duckGain float64
+
+	// mix is reused between reads to accumulate samples
+	mix []int32
+	// tee receives a copy of everything the mixer outputs, if set
+	tee io.Writer
+}
+
+// NewMixer creates an empty mixer at full volume.
+func NewMixer() *Mixer {
+	return &Mixer{
+		sounds:       make(map[string][]int16),
+		streams:      make(map[string]*mixerStream),
+		volumes:      make(map[string]float64),
+		ducks:        make(map[string]float64),
+		masterVolume: 1,
+		duckGain:     1,
+	}
+}
+
+// AddSound registers decoded samples under the given name.
+func (m *Mixer) AddSound(name string, samples []int16) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sounds[name] = samples
+}
+
+// AddStream registers a continuous source under the given name. It is silent until played.
+func (m *Mixer) AddStream(name string, reader io.Reader) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.streams[name] = &mixerStream{reader: reader}
+}
+
+// Play starts a new instance of the named sound, on top of any instances already playing.
+// For a stream, it starts mixing the stream in.
+func (m *Mixer) Play(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stream, exists := m.streams[name]; exists {
+		stream.playing = true
+		return
+	}
+
+	samples, exists := m.sounds[name]
+	if !exists {
+		return
+	}
+
+	// Make room by cutting off the oldest instance of this sound
+	playing := 0
+	oldest := -1
+	for i, v := range m.voices {
+		if v.name == name {
+			if oldest == -1 {
+				oldest = i
+			}
+			playing++
+		}
+	}
+	if playing >= maxVoicesPerSound {
+		m.voices = append(m.voices[:oldest], m.voices[oldest+1:]...)
+	}
+
+	m.voices = append(m.voices, &voice{name: name, samples: samples})
+}
+
+// Stop ends every playing instance of the named sound, or stops mixing in the named stream.
+func (m *Mixer) Stop(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stream, exists := m.streams[name]; exists {
+		stream.playing = false
+		return
+	}
+
+	remaining := m.voices[:0]
+	for _, v := range m.voices {
+		if v.name != name {
+			remaining = append(remaining, v)
+		}
+	}
+	m.voices = remaining
+}
+
+// IsPlaying reports whether any instance of the named sound or stream is playing.
+func (m *Mixer) IsPlaying(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stream, exists := m.streams[name]; exists {
+		return stream.playing
+	}
+	for _, v := range m.voices {
+		if v.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetMasterVolume sets the volume of the whole mix, from 0 to 1.
+func (m *Mixer) SetMasterVolume(volume float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.masterVolume = clampVolume(volume)
+}
+
+// MasterVolume returns the volume of the whole mix.
+func (m *Mixer) MasterVolume() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.masterVolume
+}
+
+// SetVolume sets the volume of the named sound or stream, from 0 to 1.
+func (m *Mixer) SetVolume(name string, volume float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.volumes[name] = clampVolume(volume)
+}
+
+// Volume returns the volume of the named sound or stream.
+func (m *Mixer) Volume(name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.volume(name)
+}
+
+// SetDucking lowers every other sound to the given gain, from 0 to 1, while the named sound plays.
+// A gain of 1 turns ducking off for that sound.
+func (m *Mixer) SetDucking(name string, gain float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if gain >= 1 {
+		delete(m.ducks, name)
+		return
+	}
+	m.ducks[name] = clampVolume(gain)
+}
+
+// SetTee sends a copy of the mixed output to w, or stops copying if w is nil.
+// Errors writing to w are ignored so they can't interrupt playback.
+func (m *Mixer) SetTee(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tee = w
+}
+
+// SetMuted silences or restores the output.
+func (m *Mixer) SetMuted(muted bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.muted = muted
+}
+
+// Muted reports whether the output is silenced.
+func (m *Mixer) Muted() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.muted
+}
+
+// Read fulfills io.Reader, mixing the next len(p)/2 samples of every playing sound.
+// It always fills p, with silence if nothing is playing.
+func (m *Mixer) Read(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := len(p) / 2
+	if cap(m.mix) < count {
+		m.mix = make([]int32, count)
+	}
+	mix := m.mix[:count]
+	for i := range mix {
+		mix[i] = 0
+	}
+
+	// Find how much to duck for the sounds playing now, and ramp to it over this read
+	targetDuck := 1.0
+	for _, v := range m.voices {
+		if gain, ducking := m.ducks[v.name]; ducking {
+			targetDuck = math.Min(targetDuck, gain)
+		}
+	}
+	duckStart := m.duckGain
+	duckStep := (targetDuck - duckStart) / float64(max(count, 1))
+	m.duckGain = targetDuck
+	duckAt := func(name string, i int) float64 {
+		if _, ducking := m.ducks[name]; ducking {
+			return 1
+		}
+		return duckStart + duckStep*float64(i)
+	}
+
+	// Mix in the sampled sounds, dropping the ones that finish
+	remaining := m.voices[:0]
+	for _, v := range m.voices {
+		volume := m.volume(v.name)
+		n := min(count, len(v.samples)-v.position)
+		for i := 0; i < n; i++ {
+			mix[i] += int32(float64(v.samples[v.position+i]) * volume * duckAt(v.name, i))
+		}
+		v.position += n
+		if v.position < len(v.samples) {
+			remaining = append(remaining, v)
+		}
+	}
+	m.voices = remaining
+
+	// Mix in the streams
+	for name, stream := range m.streams {
+		if !stream.playing {
+			continue
+		}
+		if cap(stream.scratch) < count*2 {
+			stream.scratch = make([]byte, count*2)
+		}
+		buf := stream.scratch[:count*2]
+		n, _ := io.ReadFull(stream.reader, buf)
+		volume := m.volume(name)
+		for i := 0; i < n/2; i++ {
+			sample := int16(uint16(buf[i*2]) | uint16(buf[i*2+1])<<8)
+			mix[i] += int32(float64(sample) * volume * duckAt(name, i))
+		}
+	}
+
+	master := m.masterVolume
+	if m.muted {
+		master = 0
+	}
+	for i, sample := range mix {
+		scaled := float64(sample) * master
+		scaled = math.Max(math.Min(scaled, math.MaxInt16), math.MinInt16)
+		out := int16(scaled)
+		p[i*2] = byte(out)
+		p[i*2+1] = byte(out >> 8)
+	}
+	if m.tee != nil {
+		m.tee.Write(p[:count*2])
+	}
+
+	return count * 2, nil
+}
+
+// volume returns the volume for the named sound. The caller must hold the lock.
+func (m *Mixer) volume(name string) float64 {
+	if volume, exists := m.volumes[name]; exists {
+		return volume
+	}
+	return 1
+}
+
+func clampVolume(volume float64) float64 {
+	return math.Max(0, math.Min(volume, 1))
+}
@@ -0,0 +1,157 @@
+package emulator
+
+import (
+	"bytes"
+	"testing"
+)
+
+// readSamples reads count samples from the mixer.
+func readSamples(t *testing.T, m *Mixer, count int) []int16 {
+	t.Helper()
+	p := make([]byte, count*2)
+	n, err := m.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(p) {
+		t.Fatalf("expected to read %d bytes, got %d", len(p), n)
+	}
+	samples := make([]int16, count)
+	for i := range samples {
+		samples[i] = int16(uint16(p[i*2]) | uint16(p[i*2+1])<<8)
+	}
+	return samples
+}
+
+func TestMixerPolyphony(t *testing.T) {
+	m := NewMixer()
+	m.AddSound("beep", []int16{100, 100, 100, 100})
+
+	m.Play("beep")
+	first := readSamples(t, m, 2)
+	// A second instance starts while the first is still playing
+	m.Play("beep")
+	overlap := readSamples(t, m, 2)
+	tail := readSamples(t, m, 3)
+
+	expected := []int16{100, 100, 200, 200, 100, 100, 0}
+	got := append(append(first, overlap...), tail...)
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("sample %d: expected %d, got %d", i, expected[i], got[i])
+		}
+	}
+	if m.IsPlaying("beep") {
+		t.Error("expected every instance to have finished")
+	}
+}
+
+func TestMixerVoiceLimit(t *testing.T) {
+	m := NewMixer()
+	m.AddSound("beep", []int16{1, 1})
+
+	for i := 0; i < maxVoicesPerSound+2; i++ {
+		m.Play("beep")
+	}
+
+	if got := readSamples(t, m, 1)[0]; got != maxVoicesPerSound {
+		t.Errorf("expected %d overlapping instances, got %d", maxVoicesPerSound, got)
+	}
+}
+
+func TestMixerVolume(t *testing.T) {
+	tests := []struct {
+		name     string
+		master   float64
+		volume   float64
+		muted    bool
+		expected int16
+	}{
+		{name: "Full volume", master: 1, volume: 1, expected: 1000},
+		{name: "Half sound volume", master: 1, volume: 0.5, expected: 500},
+		{name: "Half master volume", master: 0.5, volume: 1, expected: 500},
+		{name: "Both halved", master: 0.5, volume: 0.5, expected: 250},
+		{name: "Muted", master: 1, volume: 1, muted: true, expected: 0},
+		{name: "Out of range volume is clamped", master: 2, volume: 1, expected: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMixer()
+			m.AddSound("beep", []int16{1000})
+			m.SetMasterVolume(tt.master)
+			m.SetVolume("beep", tt.volume)
+			m.SetMuted(tt.muted)
+			m.Play("beep")
+
+			if got := readSamples(t, m, 1)[0]; got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMixerClipping(t *testing.T) {
+	m := NewMixer()
+	m.AddSound("loud", []int16{30000, -30000})
+	m.Play("loud")
+	m.Play("loud")
+
+	samples := readSamples(t, m, 2)
+	if samples[0] != 32767 || samples[1] != -32768 {
+		t.Errorf("expected clipping to 16 bits, got %v", samples)
+	}
+}
+
+func TestMixerStop(t *testing.T) {
+	m := NewMixer()
+	m.AddSound("beep", []int16{5, 5, 5})
+	m.AddSound("boop", []int16{7, 7, 7})
+	m.Play("beep")
+	m.Play("boop")
+
+	m.Stop("beep")
+
+	if got := readSamples(t, m, 1)[0]; got != 7 {
+		t.Errorf("expected only the other sound to remain, got %d", got)
+	}
+}
+
+func TestMixerStream(t *testing.T) {
+	m := NewMixer()
+	m.AddStream("synth", bytes.NewReader([]byte{0x10, 0x00, 0x20, 0x00}))
+
+	if got := readSamples(t, m, 1)[0]; got != 0 {
+		t.Errorf("expected stream to be silent until played, got %d", got)
+	}
+
+	m.Play("synth")
+	m.SetVolume("synth", 0.5)
+	samples := readSamples(t, m, 2)
+	if samples[0] != 8 || samples[1] != 16 {
+		t.Errorf("expected stream samples at half volume, got %v", samples)
+	}
+}
+
+func TestMixerDucking(t *testing.T) {
+	m := NewMixer()
+	m.AddSound("music", []int16{1000, 1000, 1000, 1000, 1000, 1000})
+	m.AddSound("boom", []int16{100, 100})
+	m.SetDucking("boom", 0.5)
+	m.Play("music")
+	m.Play("boom")
+
+	// The duck ramps down over the first read, the ducking sound itself is untouched
+	ramp := readSamples(t, m, 2)
+	if ramp[0] != 1100 || ramp[1] != 850 {
+		t.Errorf("expected ducking to ramp in, got %v", ramp)
+	}
+	// Once the ducking sound ends, the others ramp back up
+	release := readSamples(t, m, 2)
+	if release[0] != 500 || release[1] != 750 {
+		t.Errorf("expected ducking to ramp out, got %v", release)
+	}
+	if got := readSamples(t, m, 1)[0]; got != 1000 {
+		t.Errorf("expected full volume after ducking, got %d", got)
+	}
+}
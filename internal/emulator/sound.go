@@ -15,23 +15,27 @@ import (
 	"github.com/go-audio/wav"
 )
 
-// Sound Manager is a helper to provide Hardware with audio players.
+// Sound Manager is a helper to provide Hardware with audio.
+// Every sound is mixed together by a Mixer and played through a single player.
 type SoundManager struct {
 	// ctx is the singleton oto context
 	ctx *oto.Context
-	// players is a collection of players, one for each sound file
-	players map[string]*oto.Player
+	// mixer combines all playing sounds
+	mixer *Mixer
+	// player plays the output of the mixer
+	player *oto.Player
 }
 
 // NewSoundManager creates a new SoundManager.
-// An audio context is created and files are loaded into players.
-func NewSoundManager(sampleRate int, channelCount int, soundFiles embed.FS) (*SoundManager, error) {
+// An audio context is created and files are decoded and loaded into the mixer. The files must be
+// mono and recorded at sampleRate, the same as the audio context.
+func NewSoundManager(sampleRate int, soundFiles embed.FS) (*SoundManager, error) {
 	ctx, ready, err := oto.NewContext(
 		&oto.NewContextOptions{
 			// Typically 44100 or 48000
 			SampleRate: sampleRate,
-			// only 1 or 2 are supported by oto
-			ChannelCount: channelCount,
+			// The mixer produces mono audio
+			ChannelCount: 1,
 			Format:       oto.FormatSignedInt16LE,
 		})
 	if err != nil {
@@ -41,12 +45,12 @@ func NewSoundManager(sampleRate int, channelCount int, soundFiles embed.FS) (*So
 	// Wait for the audio context to be ready
 	<-ready
 
-	sm := &SoundManager{}
-	// Initialize sound players, one per unique sound
-	sm.players = make(map[string]*oto.Player)
-	sm.ctx = ctx
+	sm := &SoundManager{
+		ctx:   ctx,
+		mixer: NewMixer(),
+	}
 
-	// Find all files, open them, decode them, and load them into a player.
+	// Find all files, open them, decode them, and load them into the mixer.
 	err = fs.WalkDir(soundFiles, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -56,20 +60,20 @@ func NewSoundManager(sampleRate int, channelCount int, soundFiles embed.FS) (*So
 			if err != nil {
 				log.Fatal(err)
 			}
-			var player *oto.Player
+			var samples []int16
 			switch filepath.Ext(path) {
 			case ".wav":
-				player, err = setupWavPlayer(data, sm.ctx)
+				samples, err = decodeWav(data, sampleRate)
 				if err != nil {
-					log.Fatal(err)
+					log.Fatal(path, "err", err)
 				}
 			case ".qoa":
-				player, err = setupQoaPlayer(data, sm.ctx)
+				samples, err = decodeQoa(data, sampleRate)
 				if err != nil {
-					log.Fatal(err)
+					log.Fatal(path, "err", err)
 				}
 			}
-			sm.players[path] = player
+			sm.mixer.AddSound(path, samples)
 		}
 		return nil
 	})
@@ -77,64 +81,130 @@ func NewSoundManager(sampleRate int, channelCount int, soundFiles embed.FS) (*So
 		return nil, err
 	}
 
+	// The mix is generated live, so keep the player's buffer small to avoid lag.
+	// 1/30th of a second is enough to cover a frame or two of jitter.
+	bytesPerSample := 2
+	sm.player = ctx.NewPlayer(sm.mixer)
+	sm.player.SetBufferSize(sampleRate / 30 * bytesPerSample)
+	sm.player.Play()
+
 	return sm, nil
 }
 
 // NewSoundManagerWithDefaults creates a new SoundManager with default values.
 func NewSoundManagerWithDefaults(soundFiles embed.FS) (*SoundManager, error) {
-	return NewSoundManager(44100, 2, soundFiles)
+	return NewSoundManager(44100, soundFiles)
 }
 
 // Plays the sound at the given path from the beginning.
+// If the sound is already playing, another copy plays on top of it.
 func (sm *SoundManager) Play(filePath string) {
-	if player, exists := sm.players[filePath]; exists {
-		player.Seek(0, io.SeekStart)
-		player.Play()
-	}
+	sm.mixer.Play(filePath)
+}
+
+// AddStream registers a continuous audio stream, like a synthesizer, under the given name.
+// The stream must produce audio in the same format as the audio context. It can then be
+// started and stopped with Play and Pause like any other sound.
+func (sm *SoundManager) AddStream(name string, stream io.Reader) {
+	sm.mixer.AddStream(name, stream)
 }
 
 // Pause stop the sound at the given path, if it's playing.
 func (sm *SoundManager) Pause(filePath string) {
-	if player, exists := sm.players[filePath]; exists && player.IsPlaying() {
-		player.Pause()
+	if sm.mixer.IsPlaying(filePath) {
+		sm.mixer.Stop(filePath)
 	}
 }
 
+// SetMasterVolume sets the volume of all sounds, from 0 to 1.
+func (sm *SoundManager) SetMasterVolume(volume float64) {
+	sm.mixer.SetMasterVolume(volume)
+}
+
+// SetVolume sets the volume of the sound at the given path, from 0 to 1.
+func (sm *SoundManager) SetVolume(filePath string, volume float64) {
+	sm.mixer.SetVolume(filePath, volume)
+}
+
+// SetDucking lowers every other sound to the given gain, from 0 to 1, while the sound at the
+// given path plays. A gain of 1 turns ducking off for that sound.
+func (sm *SoundManager) SetDucking(filePath string, gain float64) {
+	sm.mixer.SetDucking(filePath, gain)
+}
+
+// ToggleMute silences or restores all sound, returning true if sound is now muted.
+func (sm *SoundManager) ToggleMute() bool {
+	muted := !sm.mixer.Muted()
+	sm.mixer.SetMuted(muted)
+	return muted
+}
+
+// SetTee sends a copy of the mixed audio to w, in the format of the audio context,
+// or stops copying if w is nil.
+func (sm *SoundManager) SetTee(w io.Writer) {
+	sm.mixer.SetTee(w)
+}
+
 func (sm *SoundManager) Cleanup() {
-	for _, player := range sm.players {
-		player.Close()
+	sm.player.Close()
+}
+
+// checkFormat returns an error unless audio with the given channels and sample rate can be
+// mixed as it is. The mixer doesn't convert, so sounds must be mono at the context's rate.
+func checkFormat(channels, rate, sampleRate int) error {
+	if channels != 1 {
+		return fmt.Errorf("sound has %d channels, only mono is supported", channels)
+	}
+	if rate != sampleRate {
+		return fmt.Errorf("sound is sampled at %d Hz, expected %d Hz", rate, sampleRate)
 	}
+	return nil
 }
 
-// setupWavPlayer decodes WAV data and creates a new player from it.
-func setupWavPlayer(data []byte, ctx *oto.Context) (*oto.Player, error) {
+// decodeWav decodes mono WAV data at sampleRate into signed 16-bit samples.
+func decodeWav(data []byte, sampleRate int) ([]int16, error) {
 	wavReader := bytes.NewReader(data)
 	wavDecoder := wav.NewDecoder(wavReader)
 	if !wavDecoder.IsValidFile() {
 		return nil, errors.New("invalid WAV file")
 	}
+	if err := checkFormat(int(wavDecoder.NumChans), int(wavDecoder.SampleRate), sampleRate); err != nil {
+		return nil, err
+	}
 
 	pcmBuffer, err := wavDecoder.FullPCMBuffer()
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert to bytes.
-	byteData := make([]byte, len(pcmBuffer.Data))
+	// Scale the samples to 16 bits.
+	samples := make([]int16, len(pcmBuffer.Data))
 	for i, sample := range pcmBuffer.Data {
-		byteData[i] = byte(sample & 0xFF)
+		switch pcmBuffer.SourceBitDepth {
+		case 8:
+			// 8-bit WAV data is unsigned
+			samples[i] = int16((sample - 128) << 8)
+		case 24:
+			samples[i] = int16(sample >> 8)
+		case 32:
+			samples[i] = int16(sample >> 16)
+		default:
+			samples[i] = int16(sample)
+		}
 	}
 
-	return ctx.NewPlayer(bytes.NewReader(byteData)), nil
+	return samples, nil
 }
 
-// setupWavPlayer decodes QOA data and creates a new player from it.
-func setupQoaPlayer(data []byte, ctx *oto.Context) (*oto.Player, error) {
-	qoaMetadata, qoaAudioData, err := qoa.Decode(data)
+// decodeQoa decodes mono QOA data at sampleRate into signed 16-bit samples.
+func decodeQoa(data []byte, sampleRate int) ([]int16, error) {
+	header, samples, err := qoa.Decode(data)
 	if err != nil {
 		return nil, fmt.Errorf("error decoding QOA data: %v", err)
 	}
+	if err := checkFormat(int(header.Channels), int(header.SampleRate), sampleRate); err != nil {
+		return nil, err
+	}
 
-	reader := qoa.NewReader(qoaAudioData, int(qoaMetadata.Channels))
-	return ctx.NewPlayer(reader), nil
+	return samples, nil
 }
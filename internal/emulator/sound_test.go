@@ -0,0 +1,58 @@
+package emulator
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// wavFile builds a 16-bit PCM WAV file holding the samples.
+func wavFile(channels, rate int, samples ...int16) []byte {
+	data := make([]byte, 0, 44+len(samples)*2)
+	data = append(data, "RIFF"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(36+len(samples)*2))
+	data = append(data, "WAVEfmt "...)
+	data = binary.LittleEndian.AppendUint32(data, 16)
+	data = binary.LittleEndian.AppendUint16(data, 1) // PCM
+	data = binary.LittleEndian.AppendUint16(data, uint16(channels))
+	data = binary.LittleEndian.AppendUint32(data, uint32(rate))
+	data = binary.LittleEndian.AppendUint32(data, uint32(rate*channels*2))
+	data = binary.LittleEndian.AppendUint16(data, uint16(channels*2))
+	data = binary.LittleEndian.AppendUint16(data, 16)
+	data = append(data, "data"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(samples)*2))
+	for _, sample := range samples {
+		data = binary.LittleEndian.AppendUint16(data, uint16(sample))
+	}
+	return data
+}
+
+func TestDecodeWav(t *testing.T) {
+	tests := []struct {
+		name     string
+		channels int
+		rate     int
+		valid    bool
+	}{
+		{name: "Mono", channels: 1, rate: 44100, valid: true},
+		{name: "Stereo", channels: 2, rate: 44100},
+		{name: "OtherRate", channels: 1, rate: 22050},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := decodeWav(wavFile(tt.channels, tt.rate, 100, -100), 44100)
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("expected the WAV to be rejected, got %v", samples)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(samples) != 2 || samples[0] != 100 || samples[1] != -100 {
+				t.Errorf("expected [100 -100], got %v", samples)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package emulator
+
+// State is a snapshot of everything the CPU needs to carry on from a point, for save states.
+// The fields are exported so it can be encoded, with gob for instance.
+type State struct {
+	PC        uint16
+	SP        uint16
+	Registers Registers
+	// Flags are packed the way PUSH PSW packs them
+	Flags             byte
+	InterruptsEnabled bool
+	Memory            [64 * 1024]byte
+	CycleCount        int
+	TotalCycles       int
+	FrameCount        int
+	NextInterrupt     int
+	// PendingInterrupt is the opcode of an interrupt requested but not handled yet, if any
+	PendingInterrupt    byte
+	HasPendingInterrupt bool
+}
+
+// SaveState returns a snapshot of the CPU and memory. It should be taken between frames,
+// when the interrupt routines aren't running.
+func (vm *CPU8080) SaveState() *State {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	state := &State{
+		PC:                vm.PC,
+		SP:                vm.sp,
+		Registers:         vm.Registers,
+		Flags:             vm.flags.toByte(),
+		InterruptsEnabled: vm.interruptsEnabled,
+		Memory:            vm.Memory,
+		CycleCount:        vm.cycleCount,
+		TotalCycles:       vm.totalCycles,
+		FrameCount:        vm.frameCount,
+		NextInterrupt:     vm.nextInterrupt,
+	}
+	// Peek at the interrupt waiting to be handled by taking it and putting it back
+	select {
+	case opcode := <-vm.InterruptRequest:
+		state.PendingInterrupt = opcode
+		state.HasPendingInterrupt = true
+		vm.InterruptRequest <- opcode
+	default:
+	}
+	return state
+}
+
+// LoadState puts the CPU and memory back the way they were when the snapshot was taken.
+func (vm *CPU8080) LoadState(state *State) {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	vm.PC = state.PC
+	vm.sp = state.SP
+	vm.Registers = state.Registers
+	vm.flags = *fromByte(state.Flags)
+	vm.interruptsEnabled = state.InterruptsEnabled
+	vm.Memory = state.Memory
+	vm.cycleCount = state.CycleCount
+	vm.totalCycles = state.TotalCycles
+	vm.frameCount = state.FrameCount
+	vm.nextInterrupt = state.NextInterrupt
+
+	// Replace whatever interrupt is waiting with the one that was
+	select {
+	case <-vm.InterruptRequest:
+	default:
+	}
+	if state.HasPendingInterrupt {
+		vm.InterruptRequest <- state.PendingInterrupt
+	}
+}
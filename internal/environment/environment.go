@@ -0,0 +1,168 @@
+// Package environment runs the game without a window as an environment for training agents:
+// reset it to the start of a game, then step it with an action at a time and get back what the
+// screen shows, the points scored and whether the game is over.
+package environment
+
+import (
+	"fmt"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/braheezy/space-invaders/internal/savestate"
+)
+
+// Observations are grayscale frames at the native resolution, a byte a pixel in rows from the
+// top. See invaders.SpaceInvadersHardware.Grayscale.
+const (
+	Width  = 224
+	Height = 256
+)
+
+// Action is what the agent does for a step. The actions are the controls of player 1's ship.
+// The ship fires once each time fire is pressed, so it has to be let go between shots.
+type Action byte
+
+const (
+	Noop Action = iota
+	Fire
+	Right
+	Left
+	RightFire
+	LeftFire
+	// ActionCount is the number of actions
+	ActionCount
+)
+
+// ActionNames are the names of the actions.
+var ActionNames = []string{"noop", "fire", "right", "left", "right+fire", "left+fire"}
+
+// controls returns the controls held for an action.
+func (a Action) controls() input.State {
+	var state input.State
+	state = state.With(input.P1Fire, a == Fire || a == RightFire || a == LeftFire)
+	state = state.With(input.P1Right, a == Right || a == RightFire)
+	state = state.With(input.P1Left, a == Left || a == LeftFire)
+	return state
+}
+
+// DefaultFrameskip is how many frames a step plays by default.
+const DefaultFrameskip = 4
+
+// MaxFrameskip is the most frames a step can play. The hello sends the frameskip in a byte.
+const MaxFrameskip = 255
+
+// startFrames is how long starting a game may take before giving up.
+const startFrames = 1200
+
+// Config configures an environment.
+type Config struct {
+	// Frameskip is how many frames each step plays, with the action held for all of them,
+	// from 1 to MaxFrameskip
+	Frameskip int
+	// Ships is the number of ships a game starts with, from 3 to 6
+	Ships int
+}
+
+// DefaultConfig returns the factory settings, with DefaultFrameskip.
+func DefaultConfig() Config {
+	return Config{Frameskip: DefaultFrameskip, Ships: 3}
+}
+
+// Environment is a machine for an agent to play one player games on.
+type Environment struct {
+	config   Config
+	vm       *emulator.CPU8080
+	hardware *invaders.SpaceInvadersHardware
+	// start is the machine as a game starts, for Reset to go back to
+	start *savestate.State
+	// controls are held for the frames of a step
+	controls input.State
+	// score is player 1's score after the last step
+	score int
+	done  bool
+}
+
+// New powers on a machine and starts a game on it, ready for the first Reset.
+func New(config Config) (*Environment, error) {
+	if config.Frameskip < 1 || config.Frameskip > MaxFrameskip {
+		return nil, fmt.Errorf("invalid frameskip %d", config.Frameskip)
+	}
+	e := &Environment{config: config}
+	e.vm, e.hardware = replay.NewMachine()
+	if err := replay.ApplySettings(e.vm, e.hardware, movie.Settings{Ships: config.Ships, Cabinet: "Upright"}); err != nil {
+		return nil, err
+	}
+	e.hardware.Input = e
+
+	// Insert a coin and press start until the game starts, and wait for the fleet to be drawn.
+	// The game looks for a coin going in, so the controls are released between presses.
+	ram := e.hardware.RAM()
+	for frame := 0; !gamestate.Playing(ram) || ram[gamestate.GameTasks] == 0; frame++ {
+		if frame == startFrames {
+			return nil, fmt.Errorf("game didn't start after %d frames", startFrames)
+		}
+		e.controls = 0
+		if frame%10 < 5 && !gamestate.Playing(ram) {
+			if ram[gamestate.Credits] == 0 {
+				e.controls = e.controls.With(input.Coin, true)
+			} else {
+				e.controls = e.controls.With(input.Start1P, true)
+			}
+		}
+		e.vm.Update()
+	}
+	e.controls = 0
+	e.start = savestate.Save(e.vm, e.hardware)
+	return e, nil
+}
+
+// Poll returns the controls for the frame being played, as the machine's input source.
+func (e *Environment) Poll() input.State {
+	return e.controls
+}
+
+// Reset goes back to the start of a game and returns the first observation.
+func (e *Environment) Reset() []byte {
+	e.start.Load(e.vm, e.hardware)
+	e.controls = 0
+	e.score = 0
+	e.done = false
+	return e.hardware.Grayscale()
+}
+
+// Step holds the controls for an action for a few frames, and returns the observation after
+// them, the points scored and whether the game is over. Once it is, Step does nothing until
+// the environment is Reset.
+func (e *Environment) Step(action Action) (observation []byte, reward int, done bool) {
+	if action >= ActionCount {
+		action = Noop
+	}
+	if !e.done {
+		e.controls = action.controls()
+		ram := e.hardware.RAM()
+		for range e.config.Frameskip {
+			e.vm.Update()
+			if !gamestate.Playing(ram) {
+				e.done = true
+				break
+			}
+		}
+		score := gamestate.Score(ram, gamestate.Player1Score)
+		reward = score - e.score
+		if reward < 0 {
+			// The score rolls over past 9999
+			reward += gamestate.MaxScore + 1
+		}
+		e.score = score
+	}
+	return e.hardware.Grayscale(), reward, e.done
+}
+
+// State returns the state of the game, decoded from RAM.
+func (e *Environment) State() gamestate.State {
+	return gamestate.Decode(e.hardware.RAM())
+}
@@ -0,0 +1,101 @@
+package environment
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+)
+
+func TestReset(t *testing.T) {
+	e, err := New(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := e.Reset()
+	if len(first) != Width*Height {
+		t.Fatalf("expected a %dx%d observation, got %d bytes", Width, Height, len(first))
+	}
+	if state := e.State(); !state.Playing || state.Lives != 3 || state.Scores[0] != 0 {
+		t.Errorf("expected a new game with 3 lives, got %+v", state)
+	}
+
+	for range 50 {
+		e.Step(RightFire)
+	}
+	if !bytes.Equal(e.Reset(), first) {
+		t.Error("expected Reset to go back to the same start")
+	}
+}
+
+func TestRewards(t *testing.T) {
+	e, err := New(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Reset()
+
+	total := 0
+	for step := 0; total == 0; step++ {
+		if step == 300 {
+			t.Fatal("expected to score by firing")
+		}
+		// The game fires once for each press
+		action := Fire
+		if step%2 == 1 {
+			action = Noop
+		}
+		_, reward, done := e.Step(action)
+		if done {
+			t.Fatal("expected the game to carry on")
+		}
+		total += reward
+	}
+	if score := e.State().Scores[0]; total != score {
+		t.Errorf("expected rewards to add up to the score %d, got %d", score, total)
+	}
+}
+
+func TestDone(t *testing.T) {
+	e, err := New(Config{Frameskip: 1, Ships: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Reset()
+	if _, _, done := e.Step(Noop); done {
+		t.Fatal("expected the game to carry on")
+	}
+
+	// End the game as the game does
+	e.hardware.RAM()[gamestate.GameMode] = 0
+	if _, _, done := e.Step(Noop); !done {
+		t.Fatal("expected the game to be over")
+	}
+	if _, reward, done := e.Step(Fire); !done || reward != 0 {
+		t.Errorf("expected nothing more once the game is over, got %d and %v", reward, done)
+	}
+
+	e.Reset()
+	if _, _, done := e.Step(Noop); done {
+		t.Error("expected Reset to start a new game")
+	}
+}
+
+func TestNewRejectsBadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{name: "NoFrameskip", config: Config{Frameskip: 0, Ships: 3}},
+		{name: "FrameskipTooBig", config: Config{Frameskip: MaxFrameskip + 1, Ships: 3}},
+		{name: "TooFewShips", config: Config{Frameskip: 4, Ships: 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
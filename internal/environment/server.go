@@ -0,0 +1,119 @@
+package environment
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/charmbracelet/log"
+)
+
+// Version is the version of the protocol the server speaks.
+const Version = 1
+
+// magic starts the hello the server sends, so clients can tell they've reached one.
+const magic = "SIEV"
+
+// The protocol is binary, in little endian. When a client connects, the server sends a hello:
+//
+//	magic     4 bytes, "SIEV"
+//	version   1 byte
+//	actions   1 byte, the number of actions
+//	width     2 bytes
+//	height    2 bytes
+//	frameskip 1 byte
+//
+// The client then sends requests, each answered with a reply. A request is a command byte,
+// followed by the action for a step:
+//
+//	'r'          reset to the start of a game
+//	's' action   step with an action, from 0 to actions-1
+//
+// A reply is the outcome, followed by the observation, width*height bytes:
+//
+//	reward  4 bytes, signed
+//	done    1 byte, 1 once the game is over
+//	lives   1 byte
+const (
+	resetCommand = 'r'
+	stepCommand  = 's'
+)
+
+// Serve gives each client that connects its own environment, until the listener is closed.
+func Serve(listener net.Listener, config Config, logger *log.Logger) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			logger.Info("Client connected", "addr", conn.RemoteAddr())
+			if err := ServeConn(conn, config); err != nil {
+				logger.Error("Client failed", "addr", conn.RemoteAddr(), "err", err)
+				return
+			}
+			logger.Info("Client disconnected", "addr", conn.RemoteAddr())
+		}()
+	}
+}
+
+// ServeConn serves an environment to one client, until it disconnects.
+func ServeConn(conn io.ReadWriteCloser, config Config) error {
+	defer conn.Close()
+	e, err := New(config)
+	if err != nil {
+		return err
+	}
+
+	hello := make([]byte, 0, 11)
+	hello = append(hello, magic...)
+	hello = append(hello, Version, byte(ActionCount))
+	hello = binary.LittleEndian.AppendUint16(hello, Width)
+	hello = binary.LittleEndian.AppendUint16(hello, Height)
+	hello = append(hello, byte(config.Frameskip))
+	if _, err := conn.Write(hello); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 6+Width*Height)
+	for {
+		var command [1]byte
+		if _, err := io.ReadFull(conn, command[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		var observation []byte
+		reward, done := 0, false
+		switch command[0] {
+		case resetCommand:
+			observation = e.Reset()
+		case stepCommand:
+			var action [1]byte
+			if _, err := io.ReadFull(conn, action[:]); err != nil {
+				return err
+			}
+			if Action(action[0]) >= ActionCount {
+				return fmt.Errorf("unknown action %d", action[0])
+			}
+			observation, reward, done = e.Step(Action(action[0]))
+		default:
+			return fmt.Errorf("unknown command %q", command[0])
+		}
+
+		binary.LittleEndian.PutUint32(reply, uint32(int32(reward)))
+		reply[4] = 0
+		if done {
+			reply[4] = 1
+		}
+		reply[5] = byte(e.State().Lives)
+		copy(reply[6:], observation)
+		if _, err := conn.Write(reply); err != nil {
+			return err
+		}
+	}
+}
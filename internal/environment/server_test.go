@@ -0,0 +1,46 @@
+package environment
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestServeConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	served := make(chan error, 1)
+	go func() { served <- ServeConn(server, DefaultConfig()) }()
+
+	hello := make([]byte, 11)
+	if _, err := io.ReadFull(client, hello); err != nil {
+		t.Fatal(err)
+	}
+	if string(hello[:4]) != magic || hello[4] != Version || hello[5] != byte(ActionCount) {
+		t.Fatalf("unexpected hello % X", hello)
+	}
+	if width, height := binary.LittleEndian.Uint16(hello[6:]), binary.LittleEndian.Uint16(hello[8:]); width != Width || height != Height {
+		t.Fatalf("expected %dx%d, got %dx%d", Width, Height, width, height)
+	}
+
+	reply := make([]byte, 6+Width*Height)
+	for _, request := range [][]byte{{resetCommand}, {stepCommand, byte(Fire)}} {
+		if _, err := client.Write(request); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadFull(client, reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply[4] != 0 || reply[5] != 3 {
+			t.Errorf("expected a game with 3 lives, got done %d and %d lives", reply[4], reply[5])
+		}
+	}
+
+	if _, err := client.Write([]byte{'x'}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err == nil {
+		t.Error("expected an unknown command to fail")
+	}
+}
@@ -0,0 +1,126 @@
+// Package gamestate decodes the state of a game of Space Invaders from the machine's RAM.
+//
+// Addresses are offsets from the start of RAM at 0x2000, so they index the slice returned by
+// the hardware's RAM method.
+package gamestate
+
+// RAM addresses
+const (
+	// FleetDelta is how far the fleet moves across each step: 2 going right and -2 going left,
+	// or 3 going right with one invader left
+	FleetDelta = 0x0008
+	// FleetY and FleetX are the position of the invader in the bottom left of the fleet, the
+	// one the others are placed from
+	FleetY = 0x0009
+	FleetX = 0x000A
+	// PlayerAlive is 0xFF while the player's ship is alive, and counts down while it explodes
+	PlayerAlive = 0x0015
+	// PlayerX is the position of the left edge of the player's ship
+	PlayerX = 0x001B
+	// PlayerShotStatus is 0 while the player can fire, 1 or 2 while their shot flies and 3 to
+	// 5 while it blows up. PlayerShotY and PlayerShotX are its position.
+	PlayerShotStatus = 0x0025
+	PlayerShotY      = 0x0029
+	PlayerShotX      = 0x002A
+	// RollingShot, PlungerShot and SquigglyShot start the invaders' three shots. See bombAt.
+	RollingShot  = 0x0030
+	PlungerShot  = 0x0040
+	SquigglyShot = 0x0050
+	// CurrentPlayer is the page of RAM with the data of the player whose turn it is, 0x21 for
+	// player 1 and 0x22 for player 2
+	CurrentPlayer = 0x0067
+	// Invaded is 1 once the fleet has reached the bottom of the screen, blowing up the ship and
+	// ending the player's game. It's cleared as the next turn starts.
+	Invaded = 0x006D
+	// UFOActive is 1 while the UFO crosses the screen, and UFOHit while it blows up
+	UFOActive = 0x0084
+	UFOHit    = 0x0085
+	// UFOX is the position of the UFO, and UFODelta is how far it moves each step: 2 going
+	// right and -2 going left
+	UFOX     = 0x008A
+	UFODelta = 0x008C
+	// UFOScore points into the table of UFO scores in ROM. It moves on with every shot fired.
+	UFOScore = 0x008D
+	// UFOTimer counts the frames down to when the UFO next comes, two bytes, least
+	// significant first. It starts again from 0x600 each time.
+	UFOTimer = 0x0091
+	// TwoPlayers is 1 while a two player game is being played, and 0 for one player
+	TwoPlayers = 0x00CE
+	// GameTasks is 1 while the game's tasks run, moving the fleet, the ship and the shots. It's
+	// 0 while a game is set up.
+	GameTasks = 0x00E9
+	// Credits is the number of credits, as one BCD byte
+	Credits = 0x00EB
+	// GameMode is 1 while a game is being played, and 0 in the demo
+	GameMode = 0x00EF
+	// HighScore and the players' scores are two BCD bytes, least significant first
+	HighScore    = 0x00F4
+	Player1Score = 0x00F8
+	Player2Score = 0x00FC
+	// Player1Rack and Player2Rack count the waves each player has cleared. A game starts at
+	// 0, and each wave cleared moves the counter on to (rack&7)+1, so it goes from 1 to 8 and
+	// from 8 back round to 1, never returning to 0 or reaching 9.
+	Player1Rack = 0x01FE
+	Player2Rack = 0x02FE
+	// Player1Invaders and Player2Invaders are the players' tables of invaders, a byte for
+	// each that is 1 while it's alive. See Invaders.
+	Player1Invaders = 0x0100
+	Player2Invaders = 0x0200
+	// Player1FleetY and Player2FleetY are the height each player's fleet starts their turn
+	// at. They're set to the height the wave starts at as each begins, and keep the fleet's
+	// height while the other player has their turn.
+	Player1FleetY = 0x01FC
+	Player2FleetY = 0x02FC
+	// Player1Ships and Player2Ships count the ships each player has left, besides the one
+	// being played
+	Player1Ships = 0x01FF
+	Player2Ships = 0x02FF
+)
+
+// BootHighScore is the address in ROM the high score is copied into RAM from when the
+// machine boots. The boot code copies a page of starting values from 0x1B00 to 0x2000.
+const BootHighScore = 0x1B00 + HighScore
+
+// FleetStartY is the table in ROM of the heights each wave's fleet starts at, indexed by the
+// rack counter from 1 to 8. The first wave of a game, at rack 0, starts at FirstFleetY.
+const (
+	FleetStartY = 0x1DA2
+	FirstFleetY = 0x78
+)
+
+// MaxWave is the furthest wave a game goes to, at rack 8, before going round again from wave 2.
+const MaxWave = 9
+
+// MaxScore is the highest score four BCD digits hold.
+const MaxScore = 9999
+
+// Playing reports whether a game is being played, rather than the demo.
+func Playing(ram []byte) bool {
+	return ram[GameMode] == 1
+}
+
+// Scores returns the players' scores.
+func Scores(ram []byte) [2]int {
+	return [2]int{Score(ram, Player1Score), Score(ram, Player2Score)}
+}
+
+// Score decodes a score stored as two BCD bytes, least significant first.
+func Score(ram []byte, address int) int {
+	return bcd(ram[address+1])*100 + bcd(ram[address])
+}
+
+// PutScore stores a score as two BCD bytes, least significant first. Scores past MaxScore
+// are stored as MaxScore.
+func PutScore(memory []byte, address int, score int) {
+	score = max(min(score, MaxScore), 0)
+	memory[address] = toBCD(score % 100)
+	memory[address+1] = toBCD(score / 100)
+}
+
+func bcd(value byte) int {
+	return int(value>>4)*10 + int(value&0x0F)
+}
+
+func toBCD(value int) byte {
+	return byte(value/10)<<4 | byte(value%10)
+}
@@ -0,0 +1,40 @@
+package gamestate
+
+import "testing"
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name  string
+		bytes [2]byte
+		score int
+	}{
+		{name: "Zero", bytes: [2]byte{0x00, 0x00}, score: 0},
+		{name: "Tens", bytes: [2]byte{0x50, 0x00}, score: 50},
+		{name: "Hundreds", bytes: [2]byte{0x50, 0x12}, score: 1250},
+		{name: "Most", bytes: [2]byte{0x99, 0x99}, score: 9999},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ram := make([]byte, 0x2000)
+			ram[Player1Score], ram[Player1Score+1] = tt.bytes[0], tt.bytes[1]
+			if got := Score(ram, Player1Score); got != tt.score {
+				t.Errorf("expected %d, got %d", tt.score, got)
+			}
+
+			written := make([]byte, 0x2000)
+			PutScore(written, Player1Score, tt.score)
+			if got := [2]byte{written[Player1Score], written[Player1Score+1]}; got != tt.bytes {
+				t.Errorf("expected % X, got % X", tt.bytes, got)
+			}
+		})
+	}
+}
+
+func TestPutScoreLimits(t *testing.T) {
+	ram := make([]byte, 0x2000)
+	PutScore(ram, HighScore, 12345)
+	if got := Score(ram, HighScore); got != MaxScore {
+		t.Errorf("expected %d, got %d", MaxScore, got)
+	}
+}
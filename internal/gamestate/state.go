@@ -0,0 +1,186 @@
+package gamestate
+
+// The fleet is 5 rows of 11 invaders.
+const (
+	Rows    = 5
+	Columns = 11
+)
+
+// InvaderSpacing is how far apart the invaders are, in both directions.
+const InvaderSpacing = 16
+
+// UFOPoints are the scores the UFO is worth, by the number of shots fired in the game,
+// counting the one that hits it, round a cycle of 15. It's the table at 0x1D54 in ROM. The 8th
+// shot of a game is worth 300, so the 23rd is too, and every 15th after that.
+var UFOPoints = [15]int{100, 50, 50, 100, 150, 100, 100, 50, 300, 100, 100, 100, 50, 150, 100}
+
+// ufoScoreTable is the low byte of the address of the table UFOScore points into.
+const ufoScoreTable = 0x54
+
+// RAMSize is the size of the RAM, from 0x2000 up to the end of the video memory.
+const RAMSize = 0x2000
+
+// Point is a position on the screen, in pixels, with the cabinet's monitor upright. X is from
+// the left edge and goes up to 223, and Y is up from the bottom and goes up to 255.
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// Shot is the player's shot or one of the invaders' bombs.
+type Shot struct {
+	// Active is set while the shot is flying
+	Active bool `json:"active"`
+	// Exploding is set while it blows up, on whatever it hit
+	Exploding bool  `json:"exploding"`
+	Position  Point `json:"position"`
+}
+
+// UFO is the mystery ship that crosses the top of the screen.
+type UFO struct {
+	Active    bool `json:"active"`
+	Exploding bool `json:"exploding"`
+	// X is the position of its left edge
+	X int `json:"x"`
+	// Direction is 1 when it flies to the right, and -1 to the left
+	Direction int `json:"direction"`
+	// Shot counts the shots fired in the game, round the cycle of 15 that picks what the UFO
+	// is worth. See UFOPoints.
+	Shot int `json:"shot"`
+	// Points is what it's worth to the player's shot in flight, or to the next one fired
+	Points int `json:"points"`
+	// Due is the number of frames until it next comes, if there are enough invaders left
+	Due int `json:"due"`
+}
+
+// State is the state of the game, decoded from RAM. The fleet, the ships and the shots
+// belong to the player whose turn it is.
+type State struct {
+	// Playing is set while a game is being played, rather than the demo
+	Playing bool `json:"playing"`
+	// TwoPlayers is set while a two player game is being played
+	TwoPlayers bool `json:"two_players"`
+	// Player is the player whose turn it is, 0 for player 1 and 1 for player 2
+	Player    int    `json:"player"`
+	Scores    [2]int `json:"scores"`
+	HighScore int    `json:"high_score"`
+	Credits   int    `json:"credits"`
+	// Lives are the ships the player has left, counting the one being played
+	Lives int `json:"lives"`
+	// Wave is the wave being played, the rack counter plus 1. It goes from 1 to 9, and
+	// then round again from 2, as the counter does from 8 back to 1.
+	Wave int `json:"wave"`
+	// PlayerX is the position of the left edge of the player's ship
+	PlayerX     int  `json:"player_x"`
+	PlayerAlive bool `json:"player_alive"`
+	// Invaded is set once the fleet has landed, ending the player's game
+	Invaded bool `json:"invaded"`
+	// Invaders says which invaders are alive, by row from the bottom and column from the left
+	Invaders [Rows][Columns]bool `json:"invaders"`
+	// InvadersLeft is the number of invaders alive
+	InvadersLeft int `json:"invaders_left"`
+	// Fleet is the position of the bottom left invader, which the others are placed from.
+	// See InvaderPosition.
+	Fleet Point `json:"fleet"`
+	// FleetDirection is 1 while the fleet moves right, and -1 while it moves left
+	FleetDirection int  `json:"fleet_direction"`
+	Shot           Shot `json:"shot"`
+	// Bombs are the invaders' rolling, plunger and squiggly shots
+	Bombs [3]Shot `json:"bombs"`
+	UFO   UFO     `json:"ufo"`
+}
+
+// Decode decodes the state of the game from RAM.
+func Decode(ram []byte) State {
+	player := 0
+	if ram[CurrentPlayer] == 0x22 {
+		player = 1
+	}
+	invaders := Player1Invaders + player*0x100
+
+	state := State{
+		Playing:        Playing(ram),
+		TwoPlayers:     ram[TwoPlayers] == 1,
+		Player:         player,
+		Scores:         Scores(ram),
+		HighScore:      Score(ram, HighScore),
+		Credits:        bcd(ram[Credits]),
+		Lives:          int(ram[Player1Ships+player*0x100]) + 1,
+		Wave:           int(ram[Player1Rack+player*0x100]) + 1,
+		PlayerX:        screenX(ram[PlayerX]),
+		PlayerAlive:    ram[PlayerAlive] == 0xFF,
+		Invaded:        ram[Invaded] == 1,
+		Fleet:          Point{X: screenX(ram[FleetX]), Y: int(ram[FleetY])},
+		FleetDirection: direction(ram[FleetDelta]),
+		Shot: Shot{
+			Active:    ram[PlayerShotStatus] == 1 || ram[PlayerShotStatus] == 2,
+			Exploding: ram[PlayerShotStatus] >= 3,
+			Position:  Point{X: screenX(ram[PlayerShotX]), Y: int(ram[PlayerShotY])},
+		},
+		Bombs: [3]Shot{bombAt(ram, RollingShot), bombAt(ram, PlungerShot), bombAt(ram, SquigglyShot)},
+		UFO:   decodeUFO(ram),
+	}
+	for row := range Rows {
+		for column := range Columns {
+			if ram[invaders+row*Columns+column] == 1 {
+				state.Invaders[row][column] = true
+				state.InvadersLeft++
+			}
+		}
+	}
+	if !state.Shot.Active {
+		state.UFO.Points = UFOPoints[(state.UFO.Shot+1)%len(UFOPoints)]
+	}
+	return state
+}
+
+// FromMemory decodes the state of the game from the machine's memory, like the CPU's.
+func FromMemory(memory *[64 * 1024]byte) State {
+	return Decode(memory[0x2000 : 0x2000+RAMSize])
+}
+
+// InvaderPosition returns where an invader is drawn. The fleet moves one invader each frame,
+// so some may be a step behind.
+func (s State) InvaderPosition(row, column int) Point {
+	return Point{X: s.Fleet.X + column*InvaderSpacing, Y: s.Fleet.Y + row*InvaderSpacing}
+}
+
+// screenX turns an X position in RAM into one on the screen. The game places things by their
+// address in memory, where the screen starts 32 lines in, at 0x2400.
+func screenX(x byte) int {
+	return int(x) - 0x20
+}
+
+// direction returns the direction of a signed distance moved, 1 for right and -1 for left.
+func direction(delta byte) int {
+	if int8(delta) < 0 {
+		return -1
+	}
+	return 1
+}
+
+// bombAt decodes one of the invaders' shots. Each has a status byte 5 bytes in, with the top
+// bit set while it's active and the bottom one while it blows up, and its position 13 bytes in.
+func bombAt(ram []byte, address int) Shot {
+	status := ram[address+5]
+	return Shot{
+		Active:    status&0x81 == 0x80,
+		Exploding: status&0x81 == 0x81,
+		Position:  Point{X: screenX(ram[address+14]), Y: int(ram[address+13])},
+	}
+}
+
+func decodeUFO(ram []byte) UFO {
+	// The pointer is moved on before each shot flies, from the start of the table
+	shot := (int(ram[UFOScore]) - ufoScoreTable) % len(UFOPoints)
+	shot = max(shot, 0)
+	return UFO{
+		Active:    ram[UFOActive] != 0,
+		Exploding: ram[UFOHit] != 0,
+		X:         screenX(ram[UFOX]),
+		Direction: direction(ram[UFODelta]),
+		Shot:      shot,
+		Points:    UFOPoints[shot],
+		Due:       int(ram[UFOTimer]) | int(ram[UFOTimer+1])<<8,
+	}
+}
@@ -0,0 +1,89 @@
+package gamestate
+
+import "testing"
+
+func TestDecode(t *testing.T) {
+	ram := make([]byte, RAMSize)
+	ram[GameMode] = 1
+	ram[TwoPlayers] = 1
+	ram[CurrentPlayer] = 0x22
+	ram[Player2Score], ram[Player2Score+1] = 0x50, 0x12
+	ram[Credits] = 0x12
+	ram[Player2Ships] = 2
+	ram[Player2Rack] = 3
+	ram[PlayerX] = 0x30
+	ram[PlayerAlive] = 0xFF
+	ram[FleetY], ram[FleetX], ram[FleetDelta] = 0x78, 0x38, 0xFE
+	ram[Player2Invaders] = 1
+	ram[Player2Invaders+Columns+2] = 1
+	ram[PlayerShotStatus], ram[PlayerShotY], ram[PlayerShotX] = 2, 0x90, 0x38
+	ram[PlungerShot+5], ram[PlungerShot+13], ram[PlungerShot+14] = 0x80, 0x6E, 0x47
+	ram[SquigglyShot+5] = 0x81
+	ram[UFOActive], ram[UFOX], ram[UFODelta] = 1, 0xC6, 0xFE
+	ram[UFOScore] = ufoScoreTable + 8
+
+	state := Decode(ram)
+	if !state.Playing || !state.TwoPlayers || state.Player != 1 {
+		t.Errorf("expected player 2 playing a two player game, got %v, %v and %d", state.Playing, state.TwoPlayers, state.Player)
+	}
+	if state.Scores != [2]int{0, 1250} || state.Credits != 12 {
+		t.Errorf("expected scores [0 1250] and 12 credits, got %v and %d", state.Scores, state.Credits)
+	}
+	if state.Lives != 3 || state.Wave != 4 {
+		t.Errorf("expected 3 lives on wave 4, got %d on %d", state.Lives, state.Wave)
+	}
+	if state.PlayerX != 0x10 || !state.PlayerAlive {
+		t.Errorf("expected a live ship at 0x10, got %v at %#x", state.PlayerAlive, state.PlayerX)
+	}
+	if !state.Invaders[0][0] || !state.Invaders[1][2] || state.InvadersLeft != 2 {
+		t.Errorf("expected invaders at (0, 0) and (1, 2), got %d", state.InvadersLeft)
+	}
+	if state.FleetDirection != -1 {
+		t.Errorf("expected the fleet to move left, got %d", state.FleetDirection)
+	}
+	if got := state.InvaderPosition(1, 2); got != (Point{X: 0x18 + 32, Y: 0x78 + 16}) {
+		t.Errorf("expected the invader at (1, 2) to be 16 up and 32 across, got %+v", got)
+	}
+	if !state.Shot.Active || state.Shot.Position != (Point{X: 0x18, Y: 0x90}) {
+		t.Errorf("expected a shot at (0x18, 0x90), got %+v", state.Shot)
+	}
+	if state.Bombs[0].Active || !state.Bombs[1].Active || !state.Bombs[2].Exploding {
+		t.Errorf("expected a plunger shot and a squiggly shot exploding, got %+v", state.Bombs)
+	}
+	if state.Bombs[1].Position != (Point{X: 0x27, Y: 0x6E}) {
+		t.Errorf("expected the plunger shot at (0x27, 0x6E), got %+v", state.Bombs[1].Position)
+	}
+	want := UFO{Active: true, X: 0xA6, Direction: -1, Shot: 8, Points: 300}
+	if state.UFO != want {
+		t.Errorf("expected %+v, got %+v", want, state.UFO)
+	}
+}
+
+func TestUFOPoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		shots  int
+		flying bool
+		points int
+	}{
+		{name: "FirstShot", shots: 0, points: 50},
+		{name: "ShotInFlight", shots: 1, flying: true, points: 50},
+		{name: "EighthShot", shots: 7, points: 300},
+		{name: "TwentyThirdShot", shots: 22, points: 300},
+		{name: "TwentyThirdShotInFlight", shots: 23, flying: true, points: 300},
+		{name: "RoundTheCycle", shots: 14, points: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ram := make([]byte, RAMSize)
+			ram[UFOScore] = byte(ufoScoreTable + tt.shots%len(UFOPoints))
+			if tt.flying {
+				ram[PlayerShotStatus] = 2
+			}
+			if got := Decode(ram).UFO.Points; got != tt.points {
+				t.Errorf("expected %d, got %d", tt.points, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Package highscore keeps a table of the best scores, with the initials of who got them and
+// when, saved to disk between runs.
+package highscore
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxEntries is how many scores the table keeps.
+const MaxEntries = 10
+
+// InitialsLength is how many letters of initials each score has.
+const InitialsLength = 3
+
+// Entry is one score in the table.
+type Entry struct {
+	Initials string    `json:"initials"`
+	Score    int       `json:"score"`
+	Date     time.Time `json:"date"`
+}
+
+// Table is the best scores, highest first.
+type Table struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Load reads a table from a file. A missing file is an empty table.
+func Load(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Table{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	// Keep the table in order however the file was edited
+	slices.SortStableFunc(t.Entries, func(a, b Entry) int { return b.Score - a.Score })
+	if len(t.Entries) > MaxEntries {
+		t.Entries = t.Entries[:MaxEntries]
+	}
+	return &t, nil
+}
+
+// Save writes the table to a file.
+func (t *Table) Save(path string) error {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Best returns the highest score in the table, or 0 if it is empty.
+func (t *Table) Best() int {
+	if len(t.Entries) == 0 {
+		return 0
+	}
+	return t.Entries[0].Score
+}
+
+// Qualifies reports whether a score would make it into the table.
+func (t *Table) Qualifies(score int) bool {
+	if score <= 0 {
+		return false
+	}
+	return len(t.Entries) < MaxEntries || score > t.Entries[len(t.Entries)-1].Score
+}
+
+// Add puts a score in the table, returning its place from 0, or -1 if it didn't make it.
+// A score that ties one already in the table goes below it.
+func (t *Table) Add(entry Entry) int {
+	if !t.Qualifies(entry.Score) {
+		return -1
+	}
+	entry.Initials = NormalizeInitials(entry.Initials)
+
+	place, _ := slices.BinarySearchFunc(t.Entries, entry.Score, func(e Entry, score int) int {
+		// Higher scores come first, and ties stay ahead of the new score
+		if e.Score >= score {
+			return -1
+		}
+		return 1
+	})
+	t.Entries = slices.Insert(t.Entries, place, entry)
+	if len(t.Entries) > MaxEntries {
+		t.Entries = t.Entries[:MaxEntries]
+	}
+	return place
+}
+
+// NormalizeInitials returns initials as the arcade would show them: InitialsLength capital
+// letters, with anything else dropped and spaces filling in the rest.
+func NormalizeInitials(initials string) string {
+	var letters []rune
+	for _, r := range strings.ToUpper(initials) {
+		if r >= 'A' && r <= 'Z' && len(letters) < InitialsLength {
+			letters = append(letters, r)
+		}
+	}
+	return string(letters) + strings.Repeat(" ", InitialsLength-len(letters))
+}
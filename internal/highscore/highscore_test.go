@@ -0,0 +1,106 @@
+package highscore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tableOf returns a table with the scores, which must be in order.
+func tableOf(scores ...int) *Table {
+	t := &Table{}
+	for _, score := range scores {
+		t.Entries = append(t.Entries, Entry{Initials: "AAA", Score: score})
+	}
+	return t
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name     string
+		table    *Table
+		score    int
+		place    int
+		expected []int
+	}{
+		{name: "Empty table", table: tableOf(), score: 100, place: 0, expected: []int{100}},
+		{name: "Top", table: tableOf(300, 200), score: 400, place: 0, expected: []int{400, 300, 200}},
+		{name: "Middle", table: tableOf(300, 200), score: 250, place: 1, expected: []int{300, 250, 200}},
+		{name: "Tie goes below", table: tableOf(300, 200), score: 200, place: 2, expected: []int{300, 200, 200}},
+		{name: "Zero never counts", table: tableOf(), score: 0, place: -1, expected: nil},
+		{name: "Full table drops the lowest", table: tableOf(1000, 900, 800, 700, 600, 500, 400, 300, 200, 100), score: 150, place: 9, expected: []int{1000, 900, 800, 700, 600, 500, 400, 300, 200, 150}},
+		{name: "Too low for a full table", table: tableOf(1000, 900, 800, 700, 600, 500, 400, 300, 200, 100), score: 100, place: -1, expected: []int{1000, 900, 800, 700, 600, 500, 400, 300, 200, 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if place := tt.table.Add(Entry{Initials: "BOB", Score: tt.score}); place != tt.place {
+				t.Errorf("expected place %d, got %d", tt.place, place)
+			}
+			var scores []int
+			for _, entry := range tt.table.Entries {
+				scores = append(scores, entry.Score)
+			}
+			if len(scores) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, scores)
+			}
+			for i := range scores {
+				if scores[i] != tt.expected[i] {
+					t.Fatalf("expected %v, got %v", tt.expected, scores)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeInitials(t *testing.T) {
+	tests := []struct {
+		initials string
+		expected string
+	}{
+		{initials: "abc", expected: "ABC"},
+		{initials: "a1b-c", expected: "ABC"},
+		{initials: "abcd", expected: "ABC"},
+		{initials: "z", expected: "Z  "},
+		{initials: "", expected: "   "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.initials, func(t *testing.T) {
+			if got := NormalizeInitials(tt.initials); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "highscores.json")
+
+	// No file yet is an empty table
+	table, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Entries) != 0 || table.Best() != 0 {
+		t.Errorf("expected an empty table, got %v", table.Entries)
+	}
+
+	date := time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC)
+	table.Add(Entry{Initials: "ABC", Score: 150, Date: date})
+	table.Add(Entry{Initials: "XYZ", Score: 990, Date: date})
+	if err := table.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Entries) != 2 || loaded.Best() != 990 {
+		t.Fatalf("expected the saved scores, got %v", loaded.Entries)
+	}
+	if entry := loaded.Entries[1]; entry.Initials != "ABC" || !entry.Date.Equal(date) {
+		t.Errorf("expected ABC on %v, got %v", date, entry)
+	}
+}
@@ -0,0 +1,114 @@
+// Package input reads the cabinet's controls from the keyboard, gamepads, scripts and the network.
+package input
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Action is a logical input on the cabinet: a button, a joystick direction or the coin slot.
+type Action int
+
+const (
+	Coin Action = iota
+	Start1P
+	Start2P
+	Tilt
+	P1Fire
+	P1Left
+	P1Right
+	P2Fire
+	P2Left
+	P2Right
+	// ActionCount is the number of actions
+	ActionCount
+)
+
+// ActionNames are the names of the actions, for showing to the player.
+var ActionNames = []string{
+	"Coin",
+	"1P start",
+	"2P start",
+	"Tilt",
+	"P1 fire",
+	"P1 left",
+	"P1 right",
+	"P2 fire",
+	"P2 left",
+	"P2 right",
+}
+
+// maxKeysPerAction limits how many keys can be bound to one action.
+// Binding another key replaces the oldest.
+const maxKeysPerAction = 3
+
+// Bindings maps each action to the keys that trigger it. An action with no keys can't be triggered.
+type Bindings [ActionCount][]ebiten.Key
+
+// DefaultBindings returns the standard key bindings.
+func DefaultBindings() Bindings {
+	return Bindings{
+		Coin:    {ebiten.KeyC},
+		Start1P: {ebiten.Key1},
+		Start2P: {ebiten.Key2},
+		Tilt:    {ebiten.KeyT},
+		P1Fire:  {ebiten.KeySpace},
+		P1Left:  {ebiten.KeyArrowLeft, ebiten.KeyA},
+		P1Right: {ebiten.KeyArrowRight, ebiten.KeyD},
+		P2Fire:  {ebiten.KeyK},
+		P2Left:  {ebiten.KeyJ},
+		P2Right: {ebiten.KeyL},
+	}
+}
+
+// Pressed reports whether any key bound to the action is held down.
+func (b *Bindings) Pressed(action Action) bool {
+	for _, key := range b[action] {
+		if ebiten.IsKeyPressed(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToggleKey binds the key to the action, or unbinds it if it already is.
+// When the action already has the most keys allowed, the oldest is replaced.
+func ToggleKey(keys []ebiten.Key, key ebiten.Key) []ebiten.Key {
+	for i, bound := range keys {
+		if bound == key {
+			return append(keys[:i:i], keys[i+1:]...)
+		}
+	}
+	if len(keys) >= maxKeysPerAction {
+		keys = keys[len(keys)-maxKeysPerAction+1:]
+	}
+	return append(keys[:len(keys):len(keys)], key)
+}
+
+// FormatKeys returns the names of the keys, separated by commas.
+func FormatKeys(keys []ebiten.Key) string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = key.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseKeys reads key names separated by commas, as written by FormatKeys.
+func ParseKeys(text string) ([]ebiten.Key, error) {
+	keys := []ebiten.Key{}
+	for _, name := range strings.Split(text, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var key ebiten.Key
+		if err := key.UnmarshalText([]byte(name)); err != nil {
+			return nil, fmt.Errorf("unknown key %q", name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
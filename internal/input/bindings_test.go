@@ -0,0 +1,85 @@
+package input
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestActionNames(t *testing.T) {
+	if len(ActionNames) != int(ActionCount) {
+		t.Errorf("expected a name for each of the %d actions, got %d", ActionCount, len(ActionNames))
+	}
+}
+
+func TestDefaultBindings(t *testing.T) {
+	bindings := DefaultBindings()
+	for action, keys := range bindings {
+		if len(keys) == 0 {
+			t.Errorf("expected %s to have a default key", ActionNames[action])
+		}
+	}
+
+	// The players have their own controls
+	for _, key := range bindings[P1Fire] {
+		if slices.Contains(bindings[P2Fire], key) {
+			t.Errorf("expected %s to only fire for player 1", key)
+		}
+	}
+}
+
+func TestToggleKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []ebiten.Key
+		key      ebiten.Key
+		expected []ebiten.Key
+	}{
+		{name: "Add", keys: []ebiten.Key{ebiten.KeyA}, key: ebiten.KeyB, expected: []ebiten.Key{ebiten.KeyA, ebiten.KeyB}},
+		{name: "Add to none", keys: nil, key: ebiten.KeyB, expected: []ebiten.Key{ebiten.KeyB}},
+		{name: "Remove", keys: []ebiten.Key{ebiten.KeyA, ebiten.KeyB}, key: ebiten.KeyA, expected: []ebiten.Key{ebiten.KeyB}},
+		{
+			name:     "Replace oldest when full",
+			keys:     []ebiten.Key{ebiten.KeyA, ebiten.KeyB, ebiten.KeyC},
+			key:      ebiten.KeyD,
+			expected: []ebiten.Key{ebiten.KeyB, ebiten.KeyC, ebiten.KeyD},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := slices.Clone(tt.keys)
+			got := ToggleKey(tt.keys, tt.key)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+			if !slices.Equal(tt.keys, original) {
+				t.Errorf("expected the original keys to be unchanged, got %v", tt.keys)
+			}
+		})
+	}
+}
+
+func TestFormatAndParseKeys(t *testing.T) {
+	keys := []ebiten.Key{ebiten.KeyArrowLeft, ebiten.KeyA, ebiten.KeySpace}
+	text := FormatKeys(keys)
+	if text != "ArrowLeft, A, Space" {
+		t.Errorf("unexpected format %q", text)
+	}
+
+	parsed, err := ParseKeys(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(parsed, keys) {
+		t.Errorf("expected %v, got %v", keys, parsed)
+	}
+
+	if parsed, err := ParseKeys(""); err != nil || len(parsed) != 0 {
+		t.Errorf("expected no keys, got %v, %v", parsed, err)
+	}
+	if _, err := ParseKeys("A, NotAKey"); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
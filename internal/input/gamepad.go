@@ -0,0 +1,301 @@
+package input
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// GamepadButton is a button on a gamepad. Gamepads that ebiten knows the layout of use the
+// standard layout, so the same button works on any of them. Others, like many arcade
+// encoders, only report raw button numbers.
+type GamepadButton struct {
+	// Standard is set when Button is an ebiten.StandardGamepadButton rather than a raw button
+	Standard bool
+	Button   int
+}
+
+// standardButtonNames are short names for the buttons of the standard layout,
+// named after an Xbox controller.
+var standardButtonNames = map[ebiten.StandardGamepadButton]string{
+	ebiten.StandardGamepadButtonRightBottom:      "A",
+	ebiten.StandardGamepadButtonRightRight:       "B",
+	ebiten.StandardGamepadButtonRightLeft:        "X",
+	ebiten.StandardGamepadButtonRightTop:         "Y",
+	ebiten.StandardGamepadButtonFrontTopLeft:     "LB",
+	ebiten.StandardGamepadButtonFrontTopRight:    "RB",
+	ebiten.StandardGamepadButtonFrontBottomLeft:  "LT",
+	ebiten.StandardGamepadButtonFrontBottomRight: "RT",
+	ebiten.StandardGamepadButtonCenterLeft:       "Back",
+	ebiten.StandardGamepadButtonCenterRight:      "Start",
+	ebiten.StandardGamepadButtonLeftStick:        "LS",
+	ebiten.StandardGamepadButtonRightStick:       "RS",
+	ebiten.StandardGamepadButtonLeftTop:          "Up",
+	ebiten.StandardGamepadButtonLeftBottom:       "Down",
+	ebiten.StandardGamepadButtonLeftLeft:         "Left",
+	ebiten.StandardGamepadButtonLeftRight:        "Right",
+	ebiten.StandardGamepadButtonCenterCenter:     "Guide",
+}
+
+// standardButton returns a button of the standard layout.
+func standardButton(button ebiten.StandardGamepadButton) GamepadButton {
+	return GamepadButton{Standard: true, Button: int(button)}
+}
+
+// rawButton returns a button by its raw number.
+func rawButton(button int) GamepadButton {
+	return GamepadButton{Button: button}
+}
+
+func (b GamepadButton) String() string {
+	if b.Standard {
+		if name, ok := standardButtonNames[ebiten.StandardGamepadButton(b.Button)]; ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("Button %d", b.Button)
+}
+
+// GamepadBindings maps each action to the gamepad buttons that trigger it.
+type GamepadBindings [ActionCount][]GamepadButton
+
+// DefaultGamepadBindings returns the standard gamepad bindings. Each player's controls
+// are read from their own gamepad, so both players use the same buttons.
+func DefaultGamepadBindings() GamepadBindings {
+	fire := []GamepadButton{standardButton(ebiten.StandardGamepadButtonRightBottom), rawButton(0)}
+	left := []GamepadButton{standardButton(ebiten.StandardGamepadButtonLeftLeft)}
+	right := []GamepadButton{standardButton(ebiten.StandardGamepadButtonLeftRight)}
+	start := []GamepadButton{standardButton(ebiten.StandardGamepadButtonCenterRight)}
+	return GamepadBindings{
+		Coin:    {standardButton(ebiten.StandardGamepadButtonCenterLeft)},
+		Start1P: start,
+		Start2P: start,
+		Tilt:    {},
+		P1Fire:  fire,
+		P1Left:  left,
+		P1Right: right,
+		P2Fire:  fire,
+		P2Left:  left,
+		P2Right: right,
+	}
+}
+
+// ToggleButton binds the button to an action, or unbinds it if it already is.
+// When the action already has the most buttons allowed, the oldest is replaced.
+func ToggleButton(buttons []GamepadButton, button GamepadButton) []GamepadButton {
+	for i, bound := range buttons {
+		if bound == button {
+			return append(buttons[:i:i], buttons[i+1:]...)
+		}
+	}
+	if len(buttons) >= maxKeysPerAction {
+		buttons = buttons[len(buttons)-maxKeysPerAction+1:]
+	}
+	return append(buttons[:len(buttons):len(buttons)], button)
+}
+
+// FormatButtons returns the names of the buttons, separated by commas.
+func FormatButtons(buttons []GamepadButton) string {
+	names := make([]string, len(buttons))
+	for i, button := range buttons {
+		names[i] = button.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseButtons reads button names separated by commas, as written by FormatButtons.
+func ParseButtons(text string) ([]GamepadButton, error) {
+	buttons := []GamepadButton{}
+	for _, name := range strings.Split(text, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		button, err := parseButton(name)
+		if err != nil {
+			return nil, err
+		}
+		buttons = append(buttons, button)
+	}
+	return buttons, nil
+}
+
+func parseButton(name string) (GamepadButton, error) {
+	for button, standardName := range standardButtonNames {
+		if strings.EqualFold(name, standardName) {
+			return standardButton(button), nil
+		}
+	}
+	if number, ok := strings.CutPrefix(name, "Button "); ok {
+		if button, err := strconv.Atoi(number); err == nil && button >= 0 && button <= int(ebiten.GamepadButtonMax) {
+			return rawButton(button), nil
+		}
+	}
+	return GamepadButton{}, fmt.Errorf("unknown gamepad button %q", name)
+}
+
+// NoGamepad is the slot of a player who doesn't use a gamepad.
+const NoGamepad = -1
+
+// GamepadSlotNames name the gamepads by the order they were connected, for picking a
+// player's gamepad. The last choice is NoGamepad.
+var GamepadSlotNames = []string{"1st", "2nd", "3rd", "4th", "None"}
+
+// GamepadSlot returns the slot for a choice from GamepadSlotNames.
+func GamepadSlot(choice int) int {
+	if choice < 0 || choice >= len(GamepadSlotNames)-1 {
+		return NoGamepad
+	}
+	return choice
+}
+
+// Gamepads reads the cabinet's controls from the connected gamepads.
+type Gamepads struct {
+	// Bindings maps each action to the buttons that trigger it
+	Bindings GamepadBindings
+	// Slots picks each player's gamepad, by the order the gamepads were connected
+	Slots [2]int
+	// Deadzone is how far, from 0 to 1, an analog stick must be pushed before it counts.
+	// It stops worn sticks that don't quite center from moving the ship.
+	Deadzone float64
+
+	// connected holds the gamepads in the order they were connected
+	connected []ebiten.GamepadID
+}
+
+// DefaultDeadzone is the deadzone used unless set otherwise.
+const DefaultDeadzone = 0.2
+
+// NewGamepads returns gamepads with the default bindings, player 1 on the first gamepad
+// connected and player 2 on the second.
+func NewGamepads() *Gamepads {
+	return &Gamepads{
+		Bindings: DefaultGamepadBindings(),
+		Slots:    [2]int{0, 1},
+		Deadzone: DefaultDeadzone,
+	}
+}
+
+// Update keeps track of gamepads being plugged in and unplugged. It is called once per frame.
+func (g *Gamepads) Update() {
+	g.connected = reconcileGamepads(g.connected, ebiten.AppendGamepadIDs(nil))
+}
+
+// reconcileGamepads updates the gamepads being tracked to the ones now connected. Gamepads
+// keep their place when others are unplugged, and new gamepads go at the end.
+func reconcileGamepads(tracked, connected []ebiten.GamepadID) []ebiten.GamepadID {
+	kept := slices.DeleteFunc(slices.Clone(tracked), func(id ebiten.GamepadID) bool {
+		return !slices.Contains(connected, id)
+	})
+	for _, id := range connected {
+		if !slices.Contains(kept, id) {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// Connected returns the connected gamepads in the order they were connected.
+func (g *Gamepads) Connected() []ebiten.GamepadID {
+	return g.connected
+}
+
+// Pressed reports whether an action is triggered on any gamepad it is read from.
+func (g *Gamepads) Pressed(action Action) bool {
+	for _, id := range g.gamepadsFor(action) {
+		for _, button := range g.Bindings[action] {
+			if buttonPressed(id, button) {
+				return true
+			}
+		}
+
+		// The stick always steers, whatever buttons are bound
+		switch action {
+		case P1Left, P2Left:
+			if stickDirection(stickValue(id), g.Deadzone) < 0 {
+				return true
+			}
+		case P1Right, P2Right:
+			if stickDirection(stickValue(id), g.Deadzone) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// gamepadsFor returns the gamepads an action is read from. A player's controls and start
+// button are on their own gamepad. The coin slot and tilt work from either.
+func (g *Gamepads) gamepadsFor(action Action) []ebiten.GamepadID {
+	var players []int
+	switch action {
+	case Start1P, P1Fire, P1Left, P1Right:
+		players = []int{0}
+	case Start2P, P2Fire, P2Left, P2Right:
+		players = []int{1}
+	default:
+		players = []int{0, 1}
+	}
+
+	var ids []ebiten.GamepadID
+	for _, player := range players {
+		slot := g.Slots[player]
+		if slot >= 0 && slot < len(g.connected) && !slices.Contains(ids, g.connected[slot]) {
+			ids = append(ids, g.connected[slot])
+		}
+	}
+	return ids
+}
+
+// buttonPressed reports whether a button is held on a gamepad. Standard buttons only work on
+// gamepads with the standard layout, and raw buttons only on those without, so the
+// defaults can hold both without one gamepad button triggering two actions.
+func buttonPressed(id ebiten.GamepadID, button GamepadButton) bool {
+	if ebiten.IsStandardGamepadLayoutAvailable(id) {
+		return button.Standard && ebiten.IsStandardGamepadButtonPressed(id, ebiten.StandardGamepadButton(button.Button))
+	}
+	return !button.Standard && ebiten.IsGamepadButtonPressed(id, ebiten.GamepadButton(button.Button))
+}
+
+// JustPressedButton returns a button that was pressed this frame on any connected gamepad,
+// for binding it to an action.
+func (g *Gamepads) JustPressedButton() (GamepadButton, bool) {
+	for _, id := range g.connected {
+		if ebiten.IsStandardGamepadLayoutAvailable(id) {
+			if buttons := inpututil.AppendJustPressedStandardGamepadButtons(id, nil); len(buttons) > 0 {
+				return standardButton(buttons[0]), true
+			}
+		} else if buttons := inpututil.AppendJustPressedGamepadButtons(id, nil); len(buttons) > 0 {
+			return rawButton(int(buttons[0])), true
+		}
+	}
+	return GamepadButton{}, false
+}
+
+// stickValue returns the horizontal position of a gamepad's left stick, from -1 to 1.
+// Gamepads without the standard layout use their first axis.
+func stickValue(id ebiten.GamepadID) float64 {
+	if ebiten.IsStandardGamepadLayoutAvailable(id) {
+		return ebiten.StandardGamepadAxisValue(id, ebiten.StandardGamepadAxisLeftStickHorizontal)
+	}
+	if ebiten.GamepadAxisCount(id) > 0 {
+		return ebiten.GamepadAxisValue(id, 0)
+	}
+	return 0
+}
+
+// stickDirection returns -1 when the stick is pushed left past the deadzone, 1 when pushed
+// right past it, and 0 otherwise.
+func stickDirection(value, deadzone float64) int {
+	switch {
+	case value <= -deadzone && value < 0:
+		return -1
+	case value >= deadzone && value > 0:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,131 @@
+package input
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+func TestDefaultGamepadBindings(t *testing.T) {
+	bindings := DefaultGamepadBindings()
+	for _, action := range []Action{Coin, Start1P, Start2P, P1Fire, P1Left, P1Right, P2Fire, P2Left, P2Right} {
+		if len(bindings[action]) == 0 {
+			t.Errorf("expected %s to have a default button", ActionNames[action])
+		}
+	}
+}
+
+func TestFormatAndParseButtons(t *testing.T) {
+	buttons := []GamepadButton{
+		standardButton(ebiten.StandardGamepadButtonRightBottom),
+		standardButton(ebiten.StandardGamepadButtonLeftLeft),
+		rawButton(3),
+	}
+	text := FormatButtons(buttons)
+	if text != "A, Left, Button 3" {
+		t.Errorf("unexpected format %q", text)
+	}
+
+	parsed, err := ParseButtons(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(parsed, buttons) {
+		t.Errorf("expected %v, got %v", buttons, parsed)
+	}
+
+	if parsed, err := ParseButtons(""); err != nil || len(parsed) != 0 {
+		t.Errorf("expected no buttons, got %v, %v", parsed, err)
+	}
+	for _, text := range []string{"A, Turbo", "Button -1", "Button 99"} {
+		if _, err := ParseButtons(text); err == nil {
+			t.Errorf("expected an error for %q", text)
+		}
+	}
+}
+
+func TestToggleButton(t *testing.T) {
+	a, b := rawButton(0), rawButton(1)
+	if got := ToggleButton([]GamepadButton{a}, b); !slices.Equal(got, []GamepadButton{a, b}) {
+		t.Errorf("expected the button to be added, got %v", got)
+	}
+	if got := ToggleButton([]GamepadButton{a, b}, a); !slices.Equal(got, []GamepadButton{b}) {
+		t.Errorf("expected the button to be removed, got %v", got)
+	}
+}
+
+func TestReconcileGamepads(t *testing.T) {
+	tests := []struct {
+		name      string
+		tracked   []ebiten.GamepadID
+		connected []ebiten.GamepadID
+		expected  []ebiten.GamepadID
+	}{
+		{name: "First connected", tracked: nil, connected: []ebiten.GamepadID{3}, expected: []ebiten.GamepadID{3}},
+		{name: "New gamepads go last", tracked: []ebiten.GamepadID{5}, connected: []ebiten.GamepadID{2, 5}, expected: []ebiten.GamepadID{5, 2}},
+		{name: "Unplugged", tracked: []ebiten.GamepadID{5, 2}, connected: []ebiten.GamepadID{2}, expected: []ebiten.GamepadID{2}},
+		{name: "All unplugged", tracked: []ebiten.GamepadID{5, 2}, connected: nil, expected: []ebiten.GamepadID{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := reconcileGamepads(tt.tracked, tt.connected); !slices.Equal(got, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGamepadsFor(t *testing.T) {
+	g := NewGamepads()
+	g.connected = []ebiten.GamepadID{7, 9}
+
+	if got := g.gamepadsFor(P1Fire); !slices.Equal(got, []ebiten.GamepadID{7}) {
+		t.Errorf("expected player 1 to use the first gamepad, got %v", got)
+	}
+	if got := g.gamepadsFor(P2Left); !slices.Equal(got, []ebiten.GamepadID{9}) {
+		t.Errorf("expected player 2 to use the second gamepad, got %v", got)
+	}
+	if got := g.gamepadsFor(Coin); !slices.Equal(got, []ebiten.GamepadID{7, 9}) {
+		t.Errorf("expected the coin slot to work from either gamepad, got %v", got)
+	}
+
+	g.Slots = [2]int{0, NoGamepad}
+	if got := g.gamepadsFor(P2Fire); len(got) != 0 {
+		t.Errorf("expected player 2 to have no gamepad, got %v", got)
+	}
+	g.Slots = [2]int{1, 3}
+	if got := g.gamepadsFor(P2Fire); len(got) != 0 {
+		t.Errorf("expected no gamepad when the slot isn't connected, got %v", got)
+	}
+}
+
+func TestStickDirection(t *testing.T) {
+	tests := []struct {
+		value, deadzone float64
+		expected        int
+	}{
+		{value: 0, deadzone: 0.2, expected: 0},
+		{value: -0.1, deadzone: 0.2, expected: 0},
+		{value: 0.19, deadzone: 0.2, expected: 0},
+		{value: -0.5, deadzone: 0.2, expected: -1},
+		{value: 1, deadzone: 0.2, expected: 1},
+		{value: 0, deadzone: 0, expected: 0},
+	}
+
+	for _, tt := range tests {
+		if got := stickDirection(tt.value, tt.deadzone); got != tt.expected {
+			t.Errorf("stick at %v with deadzone %v: expected %d, got %d", tt.value, tt.deadzone, tt.expected, got)
+		}
+	}
+}
+
+func TestGamepadSlot(t *testing.T) {
+	if GamepadSlot(0) != 0 || GamepadSlot(3) != 3 {
+		t.Error("expected the numbered choices to pick that gamepad")
+	}
+	if GamepadSlot(len(GamepadSlotNames)-1) != NoGamepad {
+		t.Error("expected the last choice to be no gamepad")
+	}
+}
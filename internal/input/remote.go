@@ -0,0 +1,64 @@
+package input
+
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+// Remote is a source controlled over a connection, such as a TCP socket.
+//
+// The other end sends the state of the controls whenever it changes, as a 16-bit little
+// endian bitmask with bit n set while the action n is held, in the order of ActionIDs.
+// Polling returns the last state received, so the controls stay held between messages.
+type Remote struct {
+	mu    sync.Mutex
+	state State
+	err   error
+}
+
+// NewRemote returns a source that reads states from r until it fails or is closed.
+func NewRemote(r io.Reader) *Remote {
+	remote := &Remote{}
+	go remote.receive(r)
+	return remote
+}
+
+func (r *Remote) receive(reader io.Reader) {
+	var message [2]byte
+	for {
+		if _, err := io.ReadFull(reader, message[:]); err != nil {
+			r.mu.Lock()
+			// Let go of everything when the connection drops
+			r.state = 0
+			r.err = err
+			r.mu.Unlock()
+			return
+		}
+		r.mu.Lock()
+		r.state = State(binary.LittleEndian.Uint16(message[:]))
+		r.mu.Unlock()
+	}
+}
+
+// Poll fulfills Source.
+func (r *Remote) Poll() State {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// Err returns why the connection stopped, or nil while it is open.
+func (r *Remote) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// SendState writes a state for a Remote on the other end of w.
+func SendState(w io.Writer, state State) error {
+	var message [2]byte
+	binary.LittleEndian.PutUint16(message[:], uint16(state))
+	_, err := w.Write(message[:])
+	return err
+}
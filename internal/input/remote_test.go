@@ -0,0 +1,37 @@
+package input
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// waitFor polls the remote until it has the expected state, or fails after a second.
+func waitFor(t *testing.T, remote *Remote, expected State) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for remote.Poll() != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q, got %q", expected, remote.Poll())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRemote(t *testing.T) {
+	client, server := net.Pipe()
+	remote := NewRemote(server)
+
+	state := State(0).With(P2Fire, true).With(Start2P, true)
+	if err := SendState(client, state); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, remote, state)
+
+	// The controls are let go when the connection closes
+	client.Close()
+	waitFor(t, remote, 0)
+	if remote.Err() == nil {
+		t.Error("expected an error after the connection closed")
+	}
+}
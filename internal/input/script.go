@@ -0,0 +1,94 @@
+package input
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Script is a source that plays back controls from a file, so a game can be driven
+// without a player.
+//
+// Each line of a script has a frame number followed by the IDs of the actions held from
+// that frame on, until the next line. A line with only a frame number releases everything.
+// Blank lines and lines starting with # are ignored:
+//
+//	# Insert a coin and start a one player game
+//	60 coin
+//	70
+//	120 start1
+//	130
+//	# Walk left while firing
+//	300 p1left p1fire
+//	420
+type Script struct {
+	changes []scriptChange
+	// frame is the frame the next poll is for
+	frame int
+	// next is the index of the next change to apply
+	next  int
+	state State
+}
+
+// scriptChange sets the state of the controls on a frame.
+type scriptChange struct {
+	frame int
+	state State
+}
+
+// LoadScript reads a script from a file.
+func LoadScript(path string) (*Script, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseScript(file)
+}
+
+// ParseScript reads a script.
+func ParseScript(r io.Reader) (*Script, error) {
+	script := &Script{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil || frame < 0 {
+			return nil, fmt.Errorf("line %d: invalid frame %q", line, fields[0])
+		}
+		if len(script.changes) > 0 && frame <= script.changes[len(script.changes)-1].frame {
+			return nil, fmt.Errorf("line %d: frame %d is not after the previous line", line, frame)
+		}
+		state, err := ParseState(fields[1:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		script.changes = append(script.changes, scriptChange{frame: frame, state: state})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// Poll fulfills Source, returning the controls for the next frame.
+func (s *Script) Poll() State {
+	for s.next < len(s.changes) && s.changes[s.next].frame <= s.frame {
+		s.state = s.changes[s.next].state
+		s.next++
+	}
+	s.frame++
+	return s.state
+}
+
+// Done reports whether every line of the script has been played.
+func (s *Script) Done() bool {
+	return s.next >= len(s.changes)
+}
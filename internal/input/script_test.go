@@ -0,0 +1,49 @@
+package input
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	script, err := ParseScript(strings.NewReader(`
+# Insert a coin, then fire
+1 coin
+3
+4 p1fire p1left
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fire := State(0).With(P1Fire, true).With(P1Left, true)
+	expected := []State{0, State(0).With(Coin, true), State(0).With(Coin, true), 0, fire, fire}
+	for frame, state := range expected {
+		if got := script.Poll(); got != state {
+			t.Errorf("frame %d: expected %q, got %q", frame, state, got)
+		}
+	}
+	if !script.Done() {
+		t.Error("expected the script to be done")
+	}
+}
+
+func TestParseScriptErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "Bad frame", script: "soon coin"},
+		{name: "Negative frame", script: "-1 coin"},
+		{name: "Out of order", script: "10 coin\n5"},
+		{name: "Unknown action", script: "10 jump"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseScript(strings.NewReader(tt.script)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State is the state of the cabinet's controls for one frame: which actions are held.
+type State uint16
+
+// Pressed reports whether an action is held.
+func (s State) Pressed(action Action) bool {
+	return s&(1<<action) != 0
+}
+
+// With returns the state with an action held or released.
+func (s State) With(action Action, pressed bool) State {
+	if pressed {
+		return s | 1<<action
+	}
+	return s &^ (1 << action)
+}
+
+// ActionIDs are short names for the actions, used in files and on the command line.
+var ActionIDs = []string{
+	"coin",
+	"start1",
+	"start2",
+	"tilt",
+	"p1fire",
+	"p1left",
+	"p1right",
+	"p2fire",
+	"p2left",
+	"p2right",
+}
+
+// String returns the IDs of the held actions, separated by spaces.
+func (s State) String() string {
+	var ids []string
+	for action := Action(0); action < ActionCount; action++ {
+		if s.Pressed(action) {
+			ids = append(ids, ActionIDs[action])
+		}
+	}
+	return strings.Join(ids, " ")
+}
+
+// ParseState reads the IDs of held actions, as written by State.String.
+func ParseState(ids []string) (State, error) {
+	var state State
+	for _, id := range ids {
+		found := false
+		for action, actionID := range ActionIDs {
+			if strings.EqualFold(id, actionID) {
+				state = state.With(Action(action), true)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("unknown action %q", id)
+		}
+	}
+	return state, nil
+}
+
+// Source produces the state of the controls. The hardware polls it once at the start of each
+// frame, so the game sees the same controls for the whole frame, and a source that gives the
+// same states gives the same game.
+type Source interface {
+	Poll() State
+}
+
+// pressedSource is a source that reads each action on its own.
+type pressedSource interface {
+	Pressed(action Action) bool
+}
+
+// pollActions builds a state by checking each action.
+func pollActions(source pressedSource) State {
+	var state State
+	for action := Action(0); action < ActionCount; action++ {
+		state = state.With(action, source.Pressed(action))
+	}
+	return state
+}
+
+// Keyboard reads the controls from the keyboard.
+type Keyboard struct {
+	Bindings Bindings
+}
+
+// NewKeyboard returns a keyboard with the default bindings.
+func NewKeyboard() *Keyboard {
+	return &Keyboard{Bindings: DefaultBindings()}
+}
+
+// Poll fulfills Source.
+func (k *Keyboard) Poll() State {
+	return pollActions(&k.Bindings)
+}
+
+// Poll fulfills Source.
+func (g *Gamepads) Poll() State {
+	return pollActions(g)
+}
+
+// Combined is a source that holds an action when any of its sources do, so several
+// controllers can play together.
+type Combined []Source
+
+// Poll fulfills Source, polling every source once.
+func (c Combined) Poll() State {
+	var state State
+	for _, source := range c {
+		state |= source.Poll()
+	}
+	return state
+}
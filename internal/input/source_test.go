@@ -0,0 +1,50 @@
+package input
+
+import "testing"
+
+func TestState(t *testing.T) {
+	state := State(0).With(Coin, true).With(P2Right, true)
+	if !state.Pressed(Coin) || !state.Pressed(P2Right) || state.Pressed(P1Fire) {
+		t.Errorf("unexpected state %q", state)
+	}
+	if state.With(Coin, false).Pressed(Coin) {
+		t.Error("expected the coin to be released")
+	}
+	if state.String() != "coin p2right" {
+		t.Errorf("unexpected format %q", state.String())
+	}
+
+	parsed, err := ParseState([]string{"coin", "P2Right"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed != state {
+		t.Errorf("expected %q, got %q", state, parsed)
+	}
+	if _, err := ParseState([]string{"jump"}); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+}
+
+func TestActionIDs(t *testing.T) {
+	if len(ActionIDs) != int(ActionCount) {
+		t.Errorf("expected an ID for each of the %d actions, got %d", ActionCount, len(ActionIDs))
+	}
+}
+
+// fixedSource is a source that always holds the same controls.
+type fixedSource State
+
+func (s fixedSource) Poll() State {
+	return State(s)
+}
+
+func TestCombined(t *testing.T) {
+	combined := Combined{fixedSource(State(0).With(Coin, true)), fixedSource(State(0).With(P1Fire, true))}
+	if got := combined.Poll(); got != State(0).With(Coin, true).With(P1Fire, true) {
+		t.Errorf("expected the sources to be combined, got %q", got)
+	}
+	if got := (Combined{}).Poll(); got != 0 {
+		t.Errorf("expected nothing held without sources, got %q", got)
+	}
+}
@@ -0,0 +1,205 @@
+package invaders
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// backdropLevel is how bright the backdrop artwork is, from 0 to 1. The upright cabinet
+// reflected the monitor in a half silvered mirror in front of the artwork, so the artwork
+// shows through dimly behind the video.
+const backdropLevel = 0.45
+
+// gelLevel is the brightness of clear glass in the gel map, where nothing tints the video.
+const gelLevel = 127
+
+// cabinet composites the video the way the upright cabinet presented it: the monochrome video
+// shines through strips of colored cellophane, and is added over an illuminated moon backdrop.
+type cabinet struct {
+	// gel holds the tint of the cellophane over each pixel, as RGBA from 0 to 255
+	gel []byte
+	// backdrop holds the dimmed artwork behind the video, as RGBA
+	backdrop []byte
+	// bezel is drawn over the whole display, if loaded
+	bezel *ebiten.Image
+}
+
+// newCabinet creates a cabinet with gel strips from the given gel map and the built in moon backdrop.
+// In the gel map, mid grey is clear and any color is a strip of that color.
+func newCabinet(gelMap image.Image) *cabinet {
+	c := &cabinet{
+		gel: make([]byte, videoWidth*videoHeight*4),
+	}
+	for y := 0; y < videoHeight; y++ {
+		for x := 0; x < videoWidth; x++ {
+			index := (y*videoWidth + x) * 4
+			if gelMap == nil {
+				c.gel[index], c.gel[index+1], c.gel[index+2] = 0xFF, 0xFF, 0xFF
+				continue
+			}
+			r, g, b, _ := gelMap.At(gelMap.Bounds().Min.X+x, gelMap.Bounds().Min.Y+y).RGBA()
+			c.gel[index] = gelTint(r >> 8)
+			c.gel[index+1] = gelTint(g >> 8)
+			c.gel[index+2] = gelTint(b >> 8)
+		}
+	}
+	c.setBackdrop(moonBackdrop())
+	return c
+}
+
+// gelTint turns a gel map channel into how much of that channel the gel lets through.
+func gelTint(value uint32) byte {
+	return byte(min(value*0xFF/gelLevel, 0xFF))
+}
+
+// setBackdrop stretches the artwork to the video size and dims it.
+func (c *cabinet) setBackdrop(artwork image.Image) {
+	c.backdrop = make([]byte, videoWidth*videoHeight*4)
+	bounds := artwork.Bounds()
+	for y := 0; y < videoHeight; y++ {
+		for x := 0; x < videoWidth; x++ {
+			// Sample the middle of the artwork pixel under this video pixel
+			sx := bounds.Min.X + (x*2+1)*bounds.Dx()/(videoWidth*2)
+			sy := bounds.Min.Y + (y*2+1)*bounds.Dy()/(videoHeight*2)
+			r, g, b, _ := artwork.At(sx, sy).RGBA()
+
+			index := (y*videoWidth + x) * 4
+			c.backdrop[index] = byte(float64(r>>8) * backdropLevel)
+			c.backdrop[index+1] = byte(float64(g>>8) * backdropLevel)
+			c.backdrop[index+2] = byte(float64(b>>8) * backdropLevel)
+		}
+	}
+}
+
+// lit returns the color of a lit pixel seen through the gel.
+func (c *cabinet) lit(index int) (r, g, b byte) {
+	return c.gel[index], c.gel[index+1], c.gel[index+2]
+}
+
+// composite adds the backdrop to the video pixels, as the mirror combines their light.
+func (c *cabinet) composite(pixels []byte) {
+	for i := 0; i < len(pixels); i += 4 {
+		pixels[i] = byte(min(int(pixels[i])+int(c.backdrop[i]), 0xFF))
+		pixels[i+1] = byte(min(int(pixels[i+1])+int(c.backdrop[i+1]), 0xFF))
+		pixels[i+2] = byte(min(int(pixels[i+2])+int(c.backdrop[i+2]), 0xFF))
+		pixels[i+3] = 0xFF
+	}
+}
+
+// moonBackdrop draws the built in artwork: a starry sky with the moon rising over a lunar horizon.
+func moonBackdrop() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, videoWidth, videoHeight))
+
+	// A fixed seed keeps the stars in the same place every time
+	seed := uint32(0x2400)
+	random := func() uint32 {
+		seed = seed*1664525 + 1013904223
+		return seed >> 8
+	}
+	for i := 0; i < 60; i++ {
+		x, y := int(random()%videoWidth), int(random()%videoHeight)
+		brightness := uint8(100 + random()%100)
+		img.Pix[img.PixOffset(x, y)+0] = brightness
+		img.Pix[img.PixOffset(x, y)+1] = brightness
+		img.Pix[img.PixOffset(x, y)+2] = brightness
+	}
+
+	// The moon, lit from the left, with a few craters
+	moonX, moonY, moonRadius := 140.0, 100.0, 62.0
+	craters := []struct{ x, y, radius float64 }{
+		{120, 80, 12}, {160, 115, 16}, {135, 130, 8}, {170, 75, 7}, {105, 110, 6},
+	}
+	for y := 0; y < videoHeight; y++ {
+		for x := 0; x < videoWidth; x++ {
+			dx, dy := float64(x)-moonX, float64(y)-moonY
+			distance := math.Hypot(dx, dy)
+			if distance > moonRadius {
+				continue
+			}
+			shade := 200 - 60*(dx+moonRadius)/(2*moonRadius) - 40*math.Pow(distance/moonRadius, 4)
+			for _, crater := range craters {
+				if math.Hypot(float64(x)-crater.x, float64(y)-crater.y) < crater.radius {
+					shade *= 0.75
+				}
+			}
+			offset := img.PixOffset(x, y)
+			img.Pix[offset] = uint8(shade)
+			img.Pix[offset+1] = uint8(shade * 0.95)
+			img.Pix[offset+2] = uint8(shade * 0.8)
+		}
+	}
+
+	// A rolling lunar horizon along the bottom
+	for x := 0; x < videoWidth; x++ {
+		horizon := 215 + int(8*math.Sin(float64(x)/17)+4*math.Sin(float64(x)/5))
+		for y := horizon; y < videoHeight; y++ {
+			offset := img.PixOffset(x, y)
+			shade := uint8(90 - min(y-horizon, 40))
+			img.Pix[offset] = shade
+			img.Pix[offset+1] = shade
+			img.Pix[offset+2] = uint8(float64(shade) * 0.9)
+		}
+	}
+
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xFF
+	}
+	return img
+}
+
+// loadImage decodes a PNG or JPEG image from disk.
+func loadImage(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %v", path, err)
+	}
+	return img, nil
+}
+
+// LoadBackdrop replaces the backdrop artwork shown in the Cabinet color scheme with an image from disk.
+// The image is stretched to fit the screen.
+func (si *SpaceInvadersHardware) LoadBackdrop(path string) error {
+	artwork, err := loadImage(path)
+	if err != nil {
+		return err
+	}
+	si.cabinet.setBackdrop(artwork)
+	return nil
+}
+
+// LoadBezel loads an image from disk to draw over the screen in the Cabinet color scheme.
+// The image is stretched to fit the screen, so transparent areas should line up with the video.
+func (si *SpaceInvadersHardware) LoadBezel(path string) error {
+	bezel, err := loadImage(path)
+	if err != nil {
+		return err
+	}
+	si.cabinet.bezel = ebiten.NewImageFromImage(bezel)
+	return nil
+}
+
+// drawBezel draws the bezel over the scaled video, if one is loaded.
+func (c *cabinet) drawBezel(screen *ebiten.Image) {
+	if c.bezel == nil {
+		return
+	}
+	bounds := c.bezel.Bounds()
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Scale(
+		float64(videoWidth*displayScale)/float64(bounds.Dx()),
+		float64(videoHeight*displayScale)/float64(bounds.Dy()),
+	)
+	screen.DrawImage(c.bezel, op)
+}
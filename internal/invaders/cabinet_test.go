@@ -0,0 +1,105 @@
+package invaders
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// filledImage returns an image of the given size filled with one color.
+func filledImage(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+// dimmed returns how a backdrop color channel shows behind the video.
+func dimmed(value float64) byte {
+	return byte(value * backdropLevel)
+}
+
+func TestCabinetGel(t *testing.T) {
+	gelMap := filledImage(videoWidth, videoHeight, color.RGBA{127, 127, 127, 255})
+	// A red strip across the top
+	for y := 32; y < 64; y++ {
+		for x := 0; x < videoWidth; x++ {
+			gelMap.SetRGBA(x, y, color.RGBA{127, 15, 15, 255})
+		}
+	}
+	c := newCabinet(gelMap)
+
+	tests := []struct {
+		name    string
+		x, y    int
+		r, g, b byte
+	}{
+		{name: "Clear glass", x: 10, y: 100, r: 0xFF, g: 0xFF, b: 0xFF},
+		{name: "Red gel", x: 10, y: 40, r: 0xFF, g: 30, b: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, g, b := c.lit((tt.y*videoWidth + tt.x) * 4)
+			if r != tt.r || g != tt.g || b != tt.b {
+				t.Errorf("expected (%d, %d, %d), got (%d, %d, %d)", tt.r, tt.g, tt.b, r, g, b)
+			}
+		})
+	}
+}
+
+func TestCabinetComposite(t *testing.T) {
+	c := newCabinet(nil)
+	c.setBackdrop(filledImage(4, 4, color.RGBA{200, 100, 0, 255}))
+
+	pixels := make([]byte, videoWidth*videoHeight*4)
+	// One lit pixel, the rest dark
+	pixels[0], pixels[1], pixels[2] = 0xFF, 0xFF, 0xFF
+	c.composite(pixels)
+
+	// Lit pixels saturate, dark pixels show the dimmed backdrop
+	if pixels[0] != 0xFF || pixels[1] != 0xFF || pixels[2] != 0xFF {
+		t.Errorf("expected lit pixel to stay white, got %v", pixels[0:3])
+	}
+	dark := pixels[4:8]
+	if dark[0] != dimmed(200) || dark[1] != dimmed(100) || dark[2] != 0 || dark[3] != 0xFF {
+		t.Errorf("expected the dimmed backdrop, got %v", dark)
+	}
+}
+
+func TestLoadBackdrop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backdrop.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Top half blue, bottom half black
+	artwork := filledImage(2, 2, color.RGBA{0, 0, 0, 255})
+	artwork.SetRGBA(0, 0, color.RGBA{0, 0, 255, 255})
+	artwork.SetRGBA(1, 0, color.RGBA{0, 0, 255, 255})
+	png.Encode(file, artwork)
+	file.Close()
+
+	si := &SpaceInvadersHardware{cabinet: newCabinet(nil)}
+	if err := si.LoadBackdrop(path); err != nil {
+		t.Fatal(err)
+	}
+
+	top := (10*videoWidth + 10) * 4
+	bottom := ((videoHeight-10)*videoWidth + 10) * 4
+	if si.cabinet.backdrop[top+2] != dimmed(255) {
+		t.Errorf("expected the artwork stretched over the top, got %d", si.cabinet.backdrop[top+2])
+	}
+	if si.cabinet.backdrop[bottom+2] != 0 {
+		t.Errorf("expected the artwork stretched over the bottom, got %d", si.cabinet.backdrop[bottom+2])
+	}
+
+	if err := si.LoadBackdrop(filepath.Join(t.TempDir(), "missing.png")); err == nil {
+		t.Error("expected an error loading a missing file")
+	}
+}
@@ -0,0 +1,79 @@
+package invaders
+
+import (
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// The coin mech closes the coin switch for a set time for each coin, and takes a while before
+// it's ready for the next. A coin is one press of Coin however long it's held, and presses
+// while the mech is busy are turned away, so a held or bouncing button can't add extra credits.
+const (
+	// CoinPulseFrames is how many frames the coin switch is closed for each coin
+	CoinPulseFrames = 3
+	// CoinGapFrames is how many frames after the switch opens before another coin is taken
+	CoinGapFrames = 6
+)
+
+// MaxCredits is the most credits the game counts up to.
+const MaxCredits = 99
+
+// freePlayCredits is how many credits free play keeps, enough to start a two player game.
+const freePlayCredits = 2
+
+// coinMech is the state of the coin mech.
+type coinMech struct {
+	// held is whether Coin was held in the frame before, so holding it is one coin
+	held bool
+	// busy counts down the frames the mech is busy with a coin, the switch being closed and
+	// then the gap after it
+	busy int
+}
+
+// CoinLockout reports whether coins are being turned away, because the credits are full or
+// the game is on free play.
+func (si *SpaceInvadersHardware) CoinLockout() bool {
+	return si.FreePlay || gamestate.Decode(si.ram).Credits >= MaxCredits
+}
+
+// TakeCoins returns the number of coins taken since it was last called. Coins turned away by
+// the lockout, and the credits free play adds, aren't counted.
+func (si *SpaceInvadersHardware) TakeCoins() int {
+	coins := si.coins
+	si.coins = 0
+	return coins
+}
+
+// updateCoinMech moves the coin mech on a frame, once the controls are polled. Frames emulated
+// again while Silent were seen the first time around, so their coins aren't counted twice.
+func (si *SpaceInvadersHardware) updateCoinMech() {
+	held := si.pressed(input.Coin)
+	inserted := held && !si.coin.held
+	si.coin.held = held
+
+	if si.coin.busy > 0 {
+		si.coin.busy--
+	}
+	if si.coin.busy > 0 || !inserted && !si.FreePlay {
+		return
+	}
+	if si.FreePlay {
+		// Free play tops the credits up in place of coins
+		if gamestate.Decode(si.ram).Credits < freePlayCredits {
+			si.coin.busy = CoinPulseFrames + CoinGapFrames
+		}
+		return
+	}
+	if si.CoinLockout() {
+		return
+	}
+	si.coin.busy = CoinPulseFrames + CoinGapFrames
+	if !si.Silent {
+		si.coins++
+	}
+}
+
+// coinSwitch reports whether the coin switch is closed in this frame.
+func (si *SpaceInvadersHardware) coinSwitch() bool {
+	return si.coin.busy > CoinGapFrames
+}
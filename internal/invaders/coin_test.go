@@ -0,0 +1,73 @@
+package invaders
+
+import (
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+func TestCoinMech(t *testing.T) {
+	// Each character is a frame: x for Coin held, or the coin switch closed
+	tests := []struct {
+		name     string
+		held     string
+		credits  byte
+		freePlay bool
+		closed   string
+		coins    int
+	}{
+		{name: "One press", held: "x", closed: "xxx", coins: 1},
+		{name: "Held is one coin", held: "xxxxxxxxxxxxxxxxxxxx", closed: "xxx", coins: 1},
+		{name: "Bouncing is one coin", held: "x.x.x.x", closed: "xxx", coins: 1},
+		{name: "Coin after the gap", held: "x........x", closed: "xxx......xxx", coins: 2},
+		{name: "Coin too soon", held: "x.......x", closed: "xxx", coins: 1},
+		{name: "Full credits lock coins out", held: "x", credits: 0x99, closed: "", coins: 0},
+		{name: "Free play tops up", credits: 0x00, freePlay: true, closed: "xxx......xxx"},
+		{name: "Free play locks coins out", held: "x", credits: 0x02, freePlay: true, closed: "", coins: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			si := newTestHardware()
+			si.ram = make([]byte, gamestate.RAMSize)
+			si.ram[gamestate.Credits] = tt.credits
+			si.FreePlay = tt.freePlay
+			source := &countingSource{}
+			si.Input = source
+
+			closed := ""
+			for frame := 0; frame < 30; frame++ {
+				source.state = input.State(0).With(input.Coin, frame < len(tt.held) && tt.held[frame] == 'x')
+				si.StartFrame()
+				port1, err := si.In(0x01)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if port1&0x01 != 0 {
+					closed += "x"
+				} else {
+					closed += "."
+				}
+				// The game counts the credit as the switch closes
+				if closed[frame] == 'x' && (frame == 0 || closed[frame-1] == '.') {
+					si.ram[gamestate.Credits]++
+				}
+			}
+
+			expected := tt.closed
+			for len(expected) < len(closed) {
+				expected += "."
+			}
+			if closed != expected {
+				t.Errorf("expected the switch closed %s, got %s", expected, closed)
+			}
+			if coins := si.TakeCoins(); coins != tt.coins {
+				t.Errorf("expected %d coins, got %d", tt.coins, coins)
+			}
+			if si.TakeCoins() != 0 {
+				t.Error("expected the coins to be taken once")
+			}
+		})
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"embed"
 	"fmt"
 	"image"
+	"io"
 	"time"
 
+	"github.com/braheezy/space-invaders/internal/crt"
 	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/patch"
 	"github.com/hajimehoshi/ebiten/v2"
 )
 
@@ -19,20 +23,100 @@ var romFile embed.FS
 //go:embed assets/SpaceInvadersArcColorUseCV.png
 var cvColorOverlay embed.FS
 
+//go:embed assets/SpaceInvadersArcColorUseTV.png
+var tvColorOverlay embed.FS
+
 type ColorScheme int
 
 const (
 	BlackAndWhite ColorScheme = iota
 	TV
 	CV
+	// Cabinet blends the video with backdrop artwork and coloured gel strips, like the upright cabinet.
+	Cabinet
 )
 
 var ColorSchemeNames = []string{
 	"BW",
 	"TV",
 	"CV",
+	"Cabinet",
+}
+
+// SoundMode selects how sound effects are produced.
+type SoundMode int
+
+const (
+	// SampledSound plays pre-recorded samples of each effect.
+	SampledSound SoundMode = iota
+	// SynthesizedSound models the analog sound circuits of the arcade board.
+	SynthesizedSound
+)
+
+var SoundModeNames = []string{
+	"Samples",
+	"Synth",
+}
+
+// CabinetType is the style of arcade cabinet being emulated.
+type CabinetType int
+
+const (
+	// Upright has one set of controls shared by both players.
+	Upright CabinetType = iota
+	// Cocktail is a table with the players sitting on opposite sides. Each player has their
+	// own controls, and the screen flips on player 2's turn.
+	Cocktail
+)
+
+var CabinetTypeNames = []string{
+	"Upright",
+	"Cocktail",
+}
+
+// SoundEffect identifies one of the game's sound effects, however it is produced.
+type SoundEffect int
+
+const (
+	UFOSound SoundEffect = iota
+	ShotSound
+	PlayerDieSound
+	InvaderDieSound
+	ExtraShipSound
+	FleetSound
+	UFOHitSound
+	soundEffectCount
+)
+
+var SoundEffectNames = []string{
+	"UFO",
+	"Shot",
+	"Player death",
+	"Invader death",
+	"Extra ship",
+	"Fleet",
+	"UFO hit",
 }
 
+// effectFiles lists the sample files that make up each sound effect.
+var effectFiles = map[SoundEffect][]string{
+	UFOSound:        {"assets/sounds/ufo_repeat_low.qoa"},
+	ShotSound:       {"assets/sounds/shoot.qoa"},
+	PlayerDieSound:  {"assets/sounds/player_die.qoa"},
+	InvaderDieSound: {"assets/sounds/invader_die.qoa"},
+	ExtraShipSound:  {"assets/sounds/extra_play.qoa"},
+	FleetSound: {
+		"assets/sounds/fleet_move_1.qoa",
+		"assets/sounds/fleet_move_2.qoa",
+		"assets/sounds/fleet_move_3.qoa",
+		"assets/sounds/fleet_move_4.qoa",
+	},
+	UFOHitSound: {"assets/sounds/ufo_hit.qoa"},
+}
+
+// synthStreamName is the name the synthesizer's audio stream is registered under.
+const synthStreamName = "synth"
+
 // SpaceInvadersHardware represents the hardware-specific implementation for the Space Invaders game.
 type SpaceInvadersHardware struct {
 	// watchdogTimer is used to simulate the watchdog timer functionality, which resets the game
@@ -43,6 +127,15 @@ type SpaceInvadersHardware struct {
 	// This value helps in synchronizing the CPU execution with the display refresh rate.
 	cyclesPerFrame int
 
+	// memory is the machine's whole address space
+	memory *[65536]byte
+	// ram is the machine's work and video RAM, from 0x2000 to 0x3FFF
+	ram []byte
+	// playing is whether a game was being played at the end of the last frame
+	playing bool
+	// gameOver is the game that ended, until it is taken
+	gameOver *GameOver
+
 	// videoRAM holds the video memory where the graphical data for the display is stored.
 	// This memory is updated by the CPU to reflect changes in the game graphics.
 	videoRAM []byte
@@ -51,8 +144,10 @@ type SpaceInvadersHardware struct {
 	video  *ebiten.Image
 	pixels []byte
 
-	// soundManager manages the playback of sound effects for the game.
+	// soundManager manages the playback of sound effects for the game. Headless hardware has none.
 	soundManager *emulator.SoundManager
+	// headless is set for hardware without sound or video
+	headless bool
 
 	// soundMapPort3 maps the bits in port 3 to their corresponding sound file names.
 	// This mapping is used to determine which sound to play when a bit in port 3 is set.
@@ -82,9 +177,51 @@ type SpaceInvadersHardware struct {
 	// This value is used to detect changes in the sound control bits and play the corresponding sounds.
 	lastSound2 byte
 
+	// SoundMode selects between playing samples and synthesizing the sound circuits.
+	SoundMode SoundMode
+	// Silent stops the hardware making sound while it keeps track of the sound bits. It is set
+	// while frames that were already heard are emulated again, like after a netplay rollback.
+	Silent bool
+
+	// synth models the analog sound board. It is fed port writes regardless of SoundMode
+	// so it always knows the state of the sound bits.
+	synth *analogSynth
+
+	// clock reports the total CPU cycles executed, used to timestamp sound port writes.
+	clock func() int
+
 	ColorScheme    ColorScheme
 	cvColorOverlay image.Image
 
+	// Input is where the controls are read from. Without one, nothing is ever pressed.
+	Input input.Source
+	// controls is the state of the controls, polled from Input once per frame
+	controls input.State
+	// coin is the coin mech between the Coin control and the coin switch, and coins counts
+	// the coins it has taken until they're taken with TakeCoins
+	coin  coinMech
+	coins int
+	// FreePlay keeps the game topped up with credits, and turns coins away
+	FreePlay bool
+	// Practice sets games up to start on a later wave, and practice is how far the game
+	// being played has got with that
+	Practice Practice
+	practice practiceState
+
+	// CabinetType decides whether the screen flip output is used, and whether player 2 has
+	// their own controls.
+	CabinetType CabinetType
+	// flipScreen is the flip output on port 5, set by the game on player 2's turn
+	flipScreen bool
+	// cabinet holds the artwork for the Cabinet color scheme
+	cabinet *cabinet
+
+	// CRT selects the CRT effects applied to the picture
+	CRT crt.Settings
+	// crt applies the CRT effects, drawing them to crtVideo
+	crt      *crt.Processor
+	crtVideo *ebiten.Image
+
 	// DIP switch settings
 	// 3, 4, 5, or 6 ships
 	ShipsSetting int
@@ -99,14 +236,42 @@ const (
 	videoHeight  = 256
 	displayScale = 3
 	startAddress = 0x0
+	// maxROMSize is the room for ROM, below the RAM at 0x2000
+	maxROMSize = 0x2000
+)
+
+// The format of the audio the hardware produces.
+const (
+	AudioSampleRate = 44100
+	AudioChannels   = 1
 )
 
+// FramesPerSecond is how many frames the hardware draws each second.
+const FramesPerSecond = 60
+
 func NewSpaceInvadersHardware() *SpaceInvadersHardware {
-	soundManager, err := emulator.NewSoundManager(44100, 1, soundFiles)
+	soundManager, err := emulator.NewSoundManager(AudioSampleRate, soundFiles)
 	if err != nil {
 		panic(err)
 	}
 
+	si := newHardware()
+	si.soundManager = soundManager
+	soundManager.AddStream(synthStreamName, si.synth)
+	soundManager.Play(synthStreamName)
+	return si
+}
+
+// NewHeadlessHardware creates hardware without sound or a window, for running the game as fast
+// as possible, like when checking a movie. The game plays exactly the same, and Frame still works.
+func NewHeadlessHardware() *SpaceInvadersHardware {
+	si := newHardware()
+	si.headless = true
+	return si
+}
+
+// newHardware creates the hardware without connecting it to an audio device.
+func newHardware() *SpaceInvadersHardware {
 	soundMapPort3 := map[byte]string{
 		0: "assets/sounds/ufo_repeat_low.qoa",
 		1: "assets/sounds/shoot.qoa",
@@ -128,17 +293,35 @@ func NewSpaceInvadersHardware() *SpaceInvadersHardware {
 	cvColorImageFile, _ := cvColorOverlay.Open("assets/SpaceInvadersArcColorUseCV.png")
 	img, _, _ := image.Decode(cvColorImageFile)
 
+	tvColorImageFile, _ := tvColorOverlay.Open("assets/SpaceInvadersArcColorUseTV.png")
+	gelMap, _, _ := image.Decode(tvColorImageFile)
+
+	cyclesPerFrame := 33334
+	// The CPU executes a frame's worth of cycles 60 times a second
+	synth := newAnalogSynth(AudioSampleRate, cyclesPerFrame*FramesPerSecond)
+
 	return &SpaceInvadersHardware{
-		cyclesPerFrame: 33334,
-		soundManager:   soundManager,
+		cyclesPerFrame: cyclesPerFrame,
+		synth:          synth,
 		soundMapPort3:  soundMapPort3,
 		soundMapPort5:  soundMapPort5,
 		rom:            romData,
 		ColorScheme:    BlackAndWhite,
 		cvColorOverlay: img,
+		cabinet:        newCabinet(gelMap),
 	}
 }
 
+// Controls returns the controls polled for the frame being emulated, or the last one.
+func (si *SpaceInvadersHardware) Controls() input.State {
+	return si.controls
+}
+
+// pressed reports whether an action is held in this frame's controls.
+func (si *SpaceInvadersHardware) pressed(action input.Action) bool {
+	return si.controls.Pressed(action)
+}
+
 func (si *SpaceInvadersHardware) In(addr byte) (byte, error) {
 	var result byte
 
@@ -155,28 +338,28 @@ func (si *SpaceInvadersHardware) In(addr byte) (byte, error) {
 			 bit 6 = 1P right (1 if pressed)
 			 bit 7 = Not connected
 		*/
-		// Credit button aka insert coin
-		if ebiten.IsKeyPressed(ebiten.KeyC) {
+		// Credit button aka insert coin, closed by the coin mech
+		if si.coinSwitch() {
 			result |= 0x01
 		}
 		// Player 2 start
-		if ebiten.IsKeyPressed(ebiten.Key2) {
+		if si.pressed(input.Start2P) {
 			result |= 0x02
 		}
 		// Player 1 start
-		if ebiten.IsKeyPressed(ebiten.Key1) {
+		if si.pressed(input.Start1P) {
 			result |= 0x04
 		}
 		// Player 1 shoot
-		if ebiten.IsKeyPressed(ebiten.KeySpace) {
+		if si.pressed(input.P1Fire) {
 			result |= 0x10
 		}
 		// Player 1 left
-		if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
+		if si.pressed(input.P1Left) {
 			result |= 0x20
 		}
 		// Player 1 right
-		if ebiten.IsKeyPressed(ebiten.KeyArrowRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
+		if si.pressed(input.P1Right) {
 			result |= 0x40
 		}
 	case 0x02:
@@ -215,19 +398,22 @@ func (si *SpaceInvadersHardware) In(addr byte) (byte, error) {
 		}
 
 		// Tilt
-		if ebiten.IsKeyPressed(ebiten.KeyT) {
+		if si.pressed(input.Tilt) {
 			result |= 0x04
 		}
+		// Player 2 has their own controls. On an upright cabinet, the players share
+		// one set of controls, so player 1's controls work too.
+		shared := si.CabinetType == Upright
 		// Player 2 shoot
-		if ebiten.IsKeyPressed(ebiten.KeySpace) {
+		if si.pressed(input.P2Fire) || (shared && si.pressed(input.P1Fire)) {
 			result |= 0x10
 		}
 		// Player 2 left
-		if ebiten.IsKeyPressed(ebiten.KeyArrowLeft) || ebiten.IsKeyPressed(ebiten.KeyA) {
+		if si.pressed(input.P2Left) || (shared && si.pressed(input.P1Left)) {
 			result |= 0x20
 		}
 		// Player 2 right
-		if ebiten.IsKeyPressed(ebiten.KeyArrowRight) || ebiten.IsKeyPressed(ebiten.KeyD) {
+		if si.pressed(input.P2Right) || (shared && si.pressed(input.P1Right)) {
 			result |= 0x40
 		}
 	case 0x03:
@@ -250,9 +436,21 @@ func (si *SpaceInvadersHardware) Out(addr byte, value byte) error {
 		// Write to the shift register
 		si.shiftRegister = (uint16(value) << 8) | (si.shiftRegister >> 8)
 	case 0x03:
-		si.handleSoundBits(value, si.soundMapPort3, &si.lastSound1)
+		si.synth.write(addr, value, si.cycles())
+		if si.SoundMode == SampledSound {
+			si.handleSoundBits(value, si.soundMapPort3, &si.lastSound1)
+		} else {
+			si.lastSound1 = value
+		}
 	case 0x05:
-		si.handleSoundBits(value, si.soundMapPort5, &si.lastSound2)
+		// Bit 5 flips the screen for player 2 on cocktail cabinets
+		si.flipScreen = value&0x20 != 0
+		si.synth.write(addr, value, si.cycles())
+		if si.SoundMode == SampledSound {
+			si.handleSoundBits(value, si.soundMapPort5, &si.lastSound2)
+		} else {
+			si.lastSound2 = value
+		}
 	case 0x06:
 		si.watchdogTimer = value
 	default:
@@ -318,15 +516,147 @@ func (si *SpaceInvadersHardware) CyclesPerFrame() int {
 	return si.cyclesPerFrame
 }
 
+// SetClock fulfills emulator.ClockedHardware so sound port writes can be placed in time.
+func (si *SpaceInvadersHardware) SetClock(clock func() int) {
+	si.clock = clock
+}
+
+// StartFrame fulfills emulator.FrameObserver, polling the controls for the frame and moving the
+// coin mech on.
+func (si *SpaceInvadersHardware) StartFrame() {
+	si.synth.setPaused(si.Silent)
+	si.controls = 0
+	if si.Input != nil {
+		si.controls = si.Input.Poll()
+	}
+	si.updateCoinMech()
+}
+
+// EndFrame fulfills emulator.FrameObserver, rendering the rest of the frame's synthesized audio,
+// setting practice games up, watching for the game ending and fading the CRT phosphor.
+func (si *SpaceInvadersHardware) EndFrame() {
+	si.updatePractice()
+	si.watchForGameOver()
+	si.synth.setEnabled(si.SoundMode == SynthesizedSound)
+	si.synth.flush(si.cycles())
+	if si.crt != nil && si.CRT.Enabled() {
+		si.decodeVideo()
+		si.decayCRT()
+	}
+}
+
+// decayCRT lights the CRT phosphor with the decoded frame. The phosphor fades once per
+// emulated frame, however often the screen is drawn.
+func (si *SpaceInvadersHardware) decayCRT() {
+	// Start without trails when the effects are turned on
+	if !si.crt.Settings.Enabled() {
+		si.crt.Reset()
+	}
+	si.crt.Settings = si.CRT
+	si.crt.Decay(si.pixels)
+}
+
+// cycles returns the total CPU cycles executed so far, or 0 if no clock is attached.
+func (si *SpaceInvadersHardware) cycles() int {
+	if si.clock == nil {
+		return 0
+	}
+	return si.clock()
+}
+
 func (si *SpaceInvadersHardware) Init(memory *[65536]byte) {
 	// memory location 0x2400 to 0x3FFF contain the graphic data
 	si.videoRAM = memory[0x2400:0x4000]
+	si.memory = memory
+	si.ram = memory[0x2000:0x4000]
+	si.pixels = make([]byte, videoWidth*videoHeight*4)
+	if si.headless {
+		return
+	}
 
 	si.video = ebiten.NewImage(videoWidth, videoHeight)
-	si.pixels = make([]byte, videoWidth*videoHeight*4)
+
+	si.crt = crt.NewProcessor(videoWidth, videoHeight, displayScale)
+	si.crtVideo = ebiten.NewImage(si.crt.Size())
+}
+
+// RAM returns the machine's RAM, from 0x2000 to 0x3FFF. The game's state is all in here.
+func (si *SpaceInvadersHardware) RAM() []byte {
+	return si.ram
 }
 
 func (si *SpaceInvadersHardware) Draw(screen *ebiten.Image) {
+	// Headless hardware has nothing to draw with
+	if si.crt == nil {
+		return
+	}
+	si.decodeVideo()
+
+	if si.CRT.Enabled() {
+		// Light the phosphor straight away if the effects were turned on since the last
+		// frame, such as while paused
+		if !si.crt.Settings.Enabled() {
+			si.decayCRT()
+		}
+		si.crt.Settings = si.CRT
+
+		// The CRT effects scale the image themselves
+		si.crtVideo.WritePixels(si.crt.Render())
+		screen.DrawImage(si.crtVideo, nil)
+	} else {
+		si.crt.Settings = si.CRT
+
+		// Write the pixel data to the image
+		si.video.WritePixels(si.pixels)
+
+		// Scale and draw the offscreen image to the main screen
+		op := &ebiten.DrawImageOptions{}
+		op.GeoM.Scale(float64(displayScale), float64(displayScale))
+		screen.DrawImage(si.video, op)
+	}
+
+	if si.ColorScheme == Cabinet {
+		si.cabinet.drawBezel(screen)
+	}
+}
+
+// Frame returns a copy of the current frame at native resolution, with the color scheme applied.
+func (si *SpaceInvadersHardware) Frame() *image.RGBA {
+	si.decodeVideo()
+
+	frame := image.NewRGBA(image.Rect(0, 0, videoWidth, videoHeight))
+	copy(frame.Pix, si.pixels)
+	return frame
+}
+
+// Grayscale returns the current frame at native resolution with a byte for each pixel: 0xFF
+// where it's lit and 0 where it isn't. The color scheme isn't applied.
+func (si *SpaceInvadersHardware) Grayscale() []byte {
+	pixels := make([]byte, videoWidth*videoHeight)
+	for i, byteValue := range si.videoRAM {
+		for bit := 0; bit < 8; bit++ {
+			if byteValue&(1<<bit) == 0 {
+				continue
+			}
+			x := i / 32
+			y := videoHeight - 1 - ((i%32)*8 + bit)
+			if si.flipped() {
+				x = videoWidth - 1 - x
+				y = videoHeight - 1 - y
+			}
+			pixels[y*videoWidth+x] = 0xFF
+		}
+	}
+	return pixels
+}
+
+// flipped reports whether the picture is turned around for player 2 on a cocktail cabinet.
+func (si *SpaceInvadersHardware) flipped() bool {
+	return si.CabinetType == Cocktail && si.flipScreen
+}
+
+// decodeVideo turns the video RAM into RGBA pixels, rotated upright and colored by the color scheme.
+func (si *SpaceInvadersHardware) decodeVideo() {
 	// Iterate through each byte in the video RAM
 	for i, byteValue := range si.videoRAM {
 		originalX := (i % 32) * 8
@@ -339,6 +669,11 @@ func (si *SpaceInvadersHardware) Draw(screen *ebiten.Image) {
 
 			rotatedX := y
 			rotatedY := videoHeight - 1 - x
+			if si.flipped() {
+				// Turn the picture around. Color overlays are fixed to the glass, so they don't turn with it.
+				rotatedX = videoWidth - 1 - rotatedX
+				rotatedY = videoHeight - 1 - rotatedY
+			}
 
 			// Calculate the pixel's index in the array
 			index := (rotatedY*videoWidth + rotatedX) * 4
@@ -403,6 +738,13 @@ func (si *SpaceInvadersHardware) Draw(screen *ebiten.Image) {
 							si.pixels[index+3] = 0xFF // A
 						}
 					}
+				case Cabinet:
+					// Light from the video through the gel over this pixel
+					r, g, b := si.cabinet.lit(index)
+					si.pixels[index] = r      // R
+					si.pixels[index+1] = g    // G
+					si.pixels[index+2] = b    // B
+					si.pixels[index+3] = 0xFF // A
 				}
 
 			} else {
@@ -414,13 +756,9 @@ func (si *SpaceInvadersHardware) Draw(screen *ebiten.Image) {
 		}
 	}
 
-	// Write the pixel data to the image
-	si.video.WritePixels(si.pixels)
-
-	// Scale and draw the offscreen image to the main screen
-	op := &ebiten.DrawImageOptions{}
-	op.GeoM.Scale(float64(displayScale), float64(displayScale))
-	screen.DrawImage(si.video, op)
+	if si.ColorScheme == Cabinet {
+		si.cabinet.composite(si.pixels)
+	}
 }
 
 // handleSoundBits handles the playing of sound effects based on changes in the sound control bits.
@@ -440,7 +778,7 @@ func (si *SpaceInvadersHardware) handleSoundBits(value byte, soundMap map[byte]s
 		// Check if the current bit was set in the previous value
 		lastBitSet := *lastValue & byte(bitMask)
 		// If the bit transitioned from 0 to 1...
-		if currentBitSet != 0 && lastBitSet == 0 {
+		if currentBitSet != 0 && lastBitSet == 0 && si.soundManager != nil && !si.Silent {
 			// Play the corresponding sound
 			si.soundManager.Play(soundFile)
 		}
@@ -466,11 +804,87 @@ func (si *SpaceInvadersHardware) HandleSystemCall(*emulator.CPU8080) {
 func (si *SpaceInvadersHardware) ROM() []byte {
 	return si.rom
 }
+
+// LoadPatch applies an IPS or BPS patch from disk to the ROM. Patches must be applied before
+// the emulator is created, which copies the ROM into memory.
+func (si *SpaceInvadersHardware) LoadPatch(path string) error {
+	rom, err := patch.Load(si.rom, path)
+	if err != nil {
+		return err
+	}
+	if len(rom) > maxROMSize {
+		return fmt.Errorf("patched ROM is %d bytes, more than the %d there's room for", len(rom), maxROMSize)
+	}
+	si.rom = rom
+	return nil
+}
 func (si *SpaceInvadersHardware) FrameDuration() time.Duration {
 	// 60 FPS -> 1000ms / 60 = 16.67ms per frame, approximate to 17ms
 	return 17 * time.Millisecond
 }
+
+// SetMasterVolume sets the overall sound volume, from 0 to 1.
+func (si *SpaceInvadersHardware) SetMasterVolume(volume float64) {
+	if si.soundManager == nil {
+		return
+	}
+	si.soundManager.SetMasterVolume(volume)
+}
+
+// SetEffectVolume sets the volume of a single sound effect, from 0 to 1.
+// It applies to both the sampled and synthesized sound.
+func (si *SpaceInvadersHardware) SetEffectVolume(effect SoundEffect, volume float64) {
+	si.synth.setGain(effect, volume)
+	if si.soundManager == nil {
+		return
+	}
+	for _, file := range effectFiles[effect] {
+		si.soundManager.SetVolume(file, volume)
+	}
+}
+
+// duckingGain is how loud other sounds play while a ducking sound plays.
+const duckingGain = 0.4
+
+// SetDucking turns on or off lowering the other sounds while the player's explosion plays,
+// so the most important sound in the game is never drowned out. It applies to both the
+// sampled and synthesized sound.
+func (si *SpaceInvadersHardware) SetDucking(enabled bool) {
+	gain := 1.0
+	if enabled {
+		gain = duckingGain
+	}
+	si.synth.setDucking(gain)
+	if si.soundManager == nil {
+		return
+	}
+	for _, file := range effectFiles[PlayerDieSound] {
+		si.soundManager.SetDucking(file, gain)
+	}
+}
+
+// ToggleMute silences or restores all sound, returning true if sound is now muted. Headless
+// hardware has no sound to mute.
+func (si *SpaceInvadersHardware) ToggleMute() bool {
+	if si.soundManager == nil {
+		return false
+	}
+	return si.soundManager.ToggleMute()
+}
+
+// SetAudioTee sends a copy of the mixed audio to w, or stops copying if w is nil.
+// The audio is signed 16-bit little endian samples in the AudioSampleRate and AudioChannels format.
+func (si *SpaceInvadersHardware) SetAudioTee(w io.Writer) {
+	if si.soundManager == nil {
+		return
+	}
+	si.soundManager.SetTee(w)
+}
+
 func (si *SpaceInvadersHardware) Cleanup() {
+	if si.soundManager == nil {
+		return
+	}
 	si.soundManager.Cleanup()
 }
 
@@ -0,0 +1,259 @@
+package invaders
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// newTestHardware creates hardware that can decode video and take port writes, without audio or a window.
+func newTestHardware() *SpaceInvadersHardware {
+	return &SpaceInvadersHardware{
+		SoundMode: SynthesizedSound,
+		synth:     newAnalogSynth(44100, 33334*60),
+		videoRAM:  make([]byte, 0x1C00),
+		pixels:    make([]byte, videoWidth*videoHeight*4),
+	}
+}
+
+// lit reports whether the pixel at x, y of the decoded picture is on.
+func lit(si *SpaceInvadersHardware, x, y int) bool {
+	return si.pixels[(y*videoWidth+x)*4] != 0
+}
+
+func TestCocktailFlip(t *testing.T) {
+	tests := []struct {
+		name        string
+		cabinetType CabinetType
+		port5       byte
+		flipped     bool
+	}{
+		{name: "Upright ignores flip", cabinetType: Upright, port5: 0x20, flipped: false},
+		{name: "Cocktail player 1", cabinetType: Cocktail, port5: 0x00, flipped: false},
+		{name: "Cocktail player 2", cabinetType: Cocktail, port5: 0x20, flipped: true},
+		{name: "Flip with sound bits", cabinetType: Cocktail, port5: 0x21, flipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			si := newTestHardware()
+			si.CabinetType = tt.cabinetType
+			if err := si.Out(0x05, tt.port5); err != nil {
+				t.Fatal(err)
+			}
+
+			// The first bit of video RAM is the bottom left corner of the upright picture
+			si.videoRAM[0] = 0x01
+			si.decodeVideo()
+
+			if lit(si, 0, videoHeight-1) == tt.flipped {
+				t.Errorf("expected bottom left lit: %v", !tt.flipped)
+			}
+			if lit(si, videoWidth-1, 0) != tt.flipped {
+				t.Errorf("expected top right lit: %v", tt.flipped)
+			}
+
+			gray := si.Grayscale()
+			if (gray[(videoHeight-1)*videoWidth] == 0xFF) == tt.flipped {
+				t.Errorf("expected bottom left lit in grayscale: %v", !tt.flipped)
+			}
+			if (gray[videoWidth-1] == 0xFF) != tt.flipped {
+				t.Errorf("expected top right lit in grayscale: %v", tt.flipped)
+			}
+		})
+	}
+}
+
+// countingSource is a source that holds the same controls every frame and counts its polls.
+type countingSource struct {
+	state input.State
+	polls int
+}
+
+func (s *countingSource) Poll() input.State {
+	s.polls++
+	return s.state
+}
+
+func TestControlsPolledOncePerFrame(t *testing.T) {
+	source := &countingSource{state: input.State(0).With(input.P1Fire, true).With(input.P1Left, true)}
+	si := newTestHardware()
+	si.Input = source
+
+	si.StartFrame()
+	for i := 0; i < 3; i++ {
+		port1, err := si.In(0x01)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if port1 != 0x30 {
+			t.Errorf("expected fire and left on port 1, got %02X", port1)
+		}
+	}
+	if source.polls != 1 {
+		t.Errorf("expected the controls to be polled once per frame, got %d", source.polls)
+	}
+
+	// A change in the middle of a frame waits for the next frame
+	source.state = 0
+	if port1, _ := si.In(0x01); port1 != 0x30 {
+		t.Errorf("expected the controls to hold for the frame, got %02X", port1)
+	}
+	si.StartFrame()
+	if port1, _ := si.In(0x01); port1 != 0 {
+		t.Errorf("expected the controls to be released, got %02X", port1)
+	}
+}
+
+func TestPlayer2Controls(t *testing.T) {
+	tests := []struct {
+		name        string
+		cabinetType CabinetType
+		action      input.Action
+		expected    byte
+	}{
+		{name: "Upright shares player 1 fire", cabinetType: Upright, action: input.P1Fire, expected: 0x10},
+		{name: "Cocktail keeps player 1 fire", cabinetType: Cocktail, action: input.P1Fire, expected: 0},
+		{name: "Cocktail player 2 right", cabinetType: Cocktail, action: input.P2Right, expected: 0x40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			si := newTestHardware()
+			si.CabinetType = tt.cabinetType
+			si.ShipsSetting = 3
+			si.Input = &countingSource{state: input.State(0).With(tt.action, true)}
+			si.StartFrame()
+
+			port2, err := si.In(0x02)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := port2 & 0x70; got != tt.expected {
+				t.Errorf("expected %02X, got %02X", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	si := newTestHardware()
+	memory := &[65536]byte{}
+	si.memory = memory
+	si.ram = memory[0x2000:0x4000]
+
+	// Nothing ends while the demo plays
+	si.EndFrame()
+	if _, ok := si.TakeGameOver(); ok {
+		t.Error("expected no game over in the demo")
+	}
+
+	si.ram[gamestate.GameMode] = 1
+	si.EndFrame()
+	gamestate.PutScore(si.ram, gamestate.Player1Score, 1250)
+	gamestate.PutScore(si.ram, gamestate.HighScore, 1250)
+	si.ram[gamestate.GameMode] = 0
+	si.EndFrame()
+
+	gameOver, ok := si.TakeGameOver()
+	if !ok {
+		t.Fatal("expected a game over")
+	}
+	if gameOver.Scores != [2]int{1250, 0} || gameOver.HighScore != 1250 {
+		t.Errorf("expected 1250 points, got %+v", gameOver)
+	}
+	if _, ok := si.TakeGameOver(); ok {
+		t.Error("expected the game over to be taken once")
+	}
+
+	// Frames emulated again after a rollback were seen already
+	si.ram[gamestate.GameMode] = 1
+	si.EndFrame()
+	si.Silent = true
+	si.ram[gamestate.GameMode] = 0
+	si.EndFrame()
+	if _, ok := si.TakeGameOver(); ok {
+		t.Error("expected no game over while silent")
+	}
+}
+
+func TestBootHighScore(t *testing.T) {
+	si := NewHeadlessHardware()
+	si.ShipsSetting = 3
+	vm := emulator.NewEmulator(si)
+	vm.ScheduleInterrupts()
+	si.SetBootHighScore(1230)
+
+	// The high score survives the boot code setting up RAM
+	for i := 0; i < 120; i++ {
+		vm.Update()
+	}
+	if got := si.HighScore(); got != 1230 {
+		t.Errorf("expected a high score of 1230, got %d", got)
+	}
+}
+
+func TestHeadlessWithoutSoundOrVideo(t *testing.T) {
+	si := NewHeadlessHardware()
+	vm := emulator.NewEmulator(si)
+	vm.Update()
+
+	// None of these have anything to work on, and shouldn't panic
+	si.SetMasterVolume(0.5)
+	si.SetEffectVolume(PlayerDieSound, 0.5)
+	si.SetDucking(true)
+	if si.ToggleMute() {
+		t.Error("expected headless hardware never to be muted")
+	}
+	si.SetAudioTee(nil)
+	si.Draw(nil)
+	si.Cleanup()
+}
+
+func TestLoadPatch(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		patch string
+		err   bool
+	}{
+		// Writes 0x00 over the 3 bytes at 0x060F
+		{name: "Patched", patch: "PATCH\x00\x06\x0F\x00\x00\x00\x03\x00EOF"},
+		// Writes a byte at 0x2000, where the RAM is
+		{name: "Too big", patch: "PATCH\x00\x20\x00\x00\x01\xFFEOF", err: true},
+		{name: "Not a patch", patch: "hello", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "patch.ips")
+			if err := os.WriteFile(path, []byte(tt.patch), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			si := NewHeadlessHardware()
+			original := append([]byte(nil), si.ROM()...)
+			err := si.LoadPatch(path)
+			if tt.err {
+				if err == nil {
+					t.Error("expected an error")
+				}
+				if !bytes.Equal(si.ROM(), original) {
+					t.Error("expected the ROM left as it was")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			rom := si.ROM()
+			if len(rom) != len(original) || !bytes.Equal(rom[0x060F:0x0612], []byte{0, 0, 0}) || !bytes.Equal(original[0x060F:0x0612], []byte{0x32, 0x15, 0x20}) {
+				t.Errorf("expected 0x060F patched, got % x", rom[0x060F:0x0612])
+			}
+		})
+	}
+}
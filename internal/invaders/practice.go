@@ -0,0 +1,139 @@
+package invaders
+
+import "github.com/braheezy/space-invaders/internal/gamestate"
+
+// Practice sets games up to practise a later wave, by patching RAM once the game has set
+// itself up. Practice games don't set the high score.
+type Practice struct {
+	// Wave is the wave games start on, from 1 to gamestate.MaxWave. 0 turns practice off.
+	Wave int
+	// Lives is how many ships each player starts with, counting the one being played
+	Lives int
+	// Score is the score each player starts with, up to gamestate.MaxScore
+	Score int
+}
+
+// MaxPracticeLives is the most ships a practice game can start with, as many as the DIP
+// switches allow. The game only has room to draw one more at the bottom of the screen, for
+// the extra ship.
+const MaxPracticeLives = 6
+
+// The game draws text and the ships left at the bottom of the screen by copying sprites from
+// ROM into video RAM a byte at a time, each a row of the screen apart. The ships left are a
+// digit counting the one being played, and a ship for each of the others.
+const (
+	characters      = 0x1E00
+	digitZero       = 0x1A
+	characterWidth  = 8
+	shipsDigit      = 0x2501
+	shipIcons       = 0x2701
+	shipIconsEnd    = 0x3501
+	shipIconSpacing = 0x200
+	shipSprite      = 0x1C60
+	shipSpriteWidth = 16
+	videoRowBytes   = 32
+)
+
+// practiceState is how far a practice game has got.
+type practiceState struct {
+	// pending is set from the start of a game until it's set up for practice
+	pending bool
+	// active is set once the game is set up for practice, until the next game starts
+	active bool
+	// highScore is the high score before the game, put back if the game beats it
+	highScore int
+}
+
+// Practicing reports whether the game being played is a practice game, or the last one played
+// was if none is, so the frame a game ends on counts as part of it.
+func (si *SpaceInvadersHardware) Practicing() bool {
+	return si.practice.pending || si.practice.active
+}
+
+// updatePractice sets a game up for practice, at the end of each frame. A game sets up each
+// player's wave and fleet a few frames after it starts, so player 1's fleet height is cleared
+// as it starts, and the game is patched once it's been set again.
+func (si *SpaceInvadersHardware) updatePractice() {
+	playing := gamestate.Playing(si.ram)
+	switch {
+	case playing && !si.playing:
+		si.practice = practiceState{}
+		if si.Practice.Wave > 0 {
+			si.practice.pending = true
+			si.practice.highScore = si.HighScore()
+			si.ram[gamestate.Player1FleetY] = 0
+		}
+	case si.practice.pending && si.ram[gamestate.Player1FleetY] != 0:
+		si.practice.pending = false
+		si.practice.active = true
+		si.patchPractice()
+	case si.practice.active && si.HighScore() != si.practice.highScore:
+		// The game has just drawn the new high score, so the old one is drawn back
+		gamestate.PutScore(si.ram, gamestate.HighScore, si.practice.highScore)
+		si.drawScore(gamestate.HighScore)
+	}
+}
+
+// patchPractice puts both players on the practice wave, with the practice lives and score.
+// Player 1's turn has started, so one of their ships is already being played, and the ships
+// left and the scores have been drawn, so they're drawn again.
+func (si *SpaceInvadersHardware) patchPractice() {
+	wave := min(max(si.Practice.Wave, 1), gamestate.MaxWave)
+	lives := min(max(si.Practice.Lives, 1), MaxPracticeLives)
+	score := min(max(si.Practice.Score, 0), gamestate.MaxScore)
+	fleetY := byte(gamestate.FirstFleetY)
+	if wave > 1 {
+		// The table is in ROM, so it follows any changes to the ROM
+		fleetY = si.memory[gamestate.FleetStartY+wave-1]
+	}
+	for player, page := range []int{0, 0x100} {
+		si.ram[gamestate.Player1Rack+page] = byte(wave - 1)
+		si.ram[gamestate.Player1FleetY+page] = fleetY
+		si.ram[gamestate.Player1Ships+page] = byte(lives - 1 + player)
+	}
+	si.drawShipsLeft(lives)
+	for _, address := range []int{gamestate.Player1Score, gamestate.Player2Score} {
+		gamestate.PutScore(si.ram, address, score)
+		si.drawScore(address)
+	}
+}
+
+// drawShipsLeft draws the ships left at the bottom of the screen, the way the game does.
+func (si *SpaceInvadersHardware) drawShipsLeft(lives int) {
+	si.drawDigit(shipsDigit, lives)
+	ship := si.memory[shipSprite : shipSprite+shipSpriteWidth]
+	blank := make([]byte, shipSpriteWidth)
+	for i, address := 0, shipIcons; address < shipIconsEnd; i, address = i+1, address+shipIconSpacing {
+		if i < lives-1 {
+			si.drawSprite(address, ship)
+		} else {
+			si.drawSprite(address, blank)
+		}
+	}
+}
+
+// drawScore draws a score the way the game does. The two bytes after a score in RAM are
+// where in video RAM it's drawn.
+func (si *SpaceInvadersHardware) drawScore(score int) {
+	address := int(si.ram[score+2]) | int(si.ram[score+3])<<8
+	digits := []int{
+		int(si.ram[score+1] >> 4), int(si.ram[score+1] & 0x0F),
+		int(si.ram[score] >> 4), int(si.ram[score] & 0x0F),
+	}
+	for i, digit := range digits {
+		si.drawDigit(address+i*characterWidth*videoRowBytes, digit)
+	}
+}
+
+// drawDigit draws a digit from the game's characters.
+func (si *SpaceInvadersHardware) drawDigit(address, digit int) {
+	character := characters + (digitZero+digit)*characterWidth
+	si.drawSprite(address, si.memory[character:character+characterWidth])
+}
+
+// drawSprite copies a sprite into video RAM.
+func (si *SpaceInvadersHardware) drawSprite(address int, sprite []byte) {
+	for column, b := range sprite {
+		si.memory[address+column*videoRowBytes] = b
+	}
+}
@@ -0,0 +1,106 @@
+package invaders
+
+import (
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+)
+
+// newPracticeHardware creates hardware with memory, and the fleet heights in ROM.
+func newPracticeHardware(practice Practice) *SpaceInvadersHardware {
+	si := newTestHardware()
+	si.memory = &[65536]byte{}
+	si.ram = si.memory[0x2000:0x4000]
+	copy(si.memory[gamestate.FleetStartY+1:], []byte{0x60, 0x50, 0x48, 0x48, 0x48, 0x40, 0x40, 0x40})
+	si.Practice = practice
+	return si
+}
+
+// startGame plays the first frames of a game: it starts, and then the players are set up.
+func startGame(si *SpaceInvadersHardware, ships int) {
+	si.ram[gamestate.GameMode] = 1
+	si.EndFrame()
+	// Player 1's first ship is already being played
+	for player, page := range []int{0, 0x100} {
+		si.ram[gamestate.Player1Rack+page] = 0
+		si.ram[gamestate.Player1FleetY+page] = gamestate.FirstFleetY
+		si.ram[gamestate.Player1Ships+page] = byte(ships - 1 + player)
+	}
+	si.EndFrame()
+}
+
+func TestPractice(t *testing.T) {
+	tests := []struct {
+		name     string
+		practice Practice
+		rack     byte
+		fleetY   byte
+		ships    [2]byte
+		score    int
+	}{
+		{name: "Off", practice: Practice{}, rack: 0, fleetY: 0x78, ships: [2]byte{2, 3}},
+		{name: "First wave", practice: Practice{Wave: 1, Lives: 5}, rack: 0, fleetY: 0x78, ships: [2]byte{4, 5}},
+		{name: "Second wave", practice: Practice{Wave: 2, Lives: 3}, rack: 1, fleetY: 0x60, ships: [2]byte{2, 3}},
+		{name: "Score", practice: Practice{Wave: 2, Lives: 3, Score: 1500}, rack: 1, fleetY: 0x60, ships: [2]byte{2, 3}, score: 1500},
+		{name: "Last wave", practice: Practice{Wave: 9, Lives: 1}, rack: 8, fleetY: 0x40, ships: [2]byte{0, 1}},
+		{name: "Past the last wave", practice: Practice{Wave: 12, Lives: 9, Score: 12000}, rack: 8, fleetY: 0x40, ships: [2]byte{5, 6}, score: 9999},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			si := newPracticeHardware(tt.practice)
+			startGame(si, 3)
+
+			for player, page := range []int{0, 0x100} {
+				if rack := si.ram[gamestate.Player1Rack+page]; rack != tt.rack {
+					t.Errorf("expected player %d on rack %d, got %d", player+1, tt.rack, rack)
+				}
+				if fleetY := si.ram[gamestate.Player1FleetY+page]; fleetY != tt.fleetY {
+					t.Errorf("expected player %d's fleet at %#x, got %#x", player+1, tt.fleetY, fleetY)
+				}
+				if ships := si.ram[gamestate.Player1Ships+page]; ships != tt.ships[player] {
+					t.Errorf("expected player %d to have %d ships left, got %d", player+1, tt.ships[player], ships)
+				}
+				if score := gamestate.Scores(si.ram)[player]; score != tt.score {
+					t.Errorf("expected player %d to start with %d points, got %d", player+1, tt.score, score)
+				}
+			}
+			if practicing := tt.practice.Wave > 0; si.Practicing() != practicing {
+				t.Errorf("expected practicing %v", practicing)
+			}
+		})
+	}
+}
+
+func TestPracticeHighScore(t *testing.T) {
+	si := newPracticeHardware(Practice{Wave: 3, Lives: 3})
+	gamestate.PutScore(si.ram, gamestate.HighScore, 1200)
+	// The high score is drawn at the top of the screen
+	si.ram[gamestate.HighScore+2], si.ram[gamestate.HighScore+3] = 0x11, 0x3C
+	startGame(si, 3)
+
+	// The game beats the high score, and it's put back
+	gamestate.PutScore(si.ram, gamestate.Player1Score, 1500)
+	gamestate.PutScore(si.ram, gamestate.HighScore, 1500)
+	si.EndFrame()
+	if got := si.HighScore(); got != 1200 {
+		t.Errorf("expected the high score to stay 1200, got %d", got)
+	}
+	si.ram[gamestate.GameMode] = 0
+	si.EndFrame()
+
+	gameOver, ok := si.TakeGameOver()
+	if !ok || !gameOver.Practice || gameOver.Scores[0] != 1500 {
+		t.Errorf("expected a practice game over with 1500 points, got %+v", gameOver)
+	}
+	if !si.Practicing() {
+		t.Error("expected the game that ended to be practice")
+	}
+
+	// Games aren't practice once it's turned off
+	si.Practice = Practice{}
+	startGame(si, 3)
+	if si.Practicing() {
+		t.Error("expected a normal game")
+	}
+}
@@ -0,0 +1,50 @@
+package invaders
+
+import "github.com/braheezy/space-invaders/internal/gamestate"
+
+// GameOver is the end of a game, with the scores it ended on.
+type GameOver struct {
+	// Scores are the players' final scores. Player 2's is 0 after a one player game.
+	Scores    [2]int
+	HighScore int
+	// Practice is set for practice games, which don't count as high scores
+	Practice bool
+}
+
+// Scores returns the players' scores, as shown at the top of the screen.
+func (si *SpaceInvadersHardware) Scores() [2]int {
+	return gamestate.Scores(si.ram)
+}
+
+// HighScore returns the high score, as shown at the top of the screen.
+func (si *SpaceInvadersHardware) HighScore() int {
+	return gamestate.Score(si.ram, gamestate.HighScore)
+}
+
+// SetBootHighScore makes the game start with a high score, so the HI-SCORE shows the best
+// score of earlier runs. The boot code copies the high score into RAM from ROM, so it is
+// written into the copy of the ROM in memory, and lands in RAM before it is first drawn. It
+// must be called before the first frame.
+func (si *SpaceInvadersHardware) SetBootHighScore(score int) {
+	gamestate.PutScore(si.memory[:], gamestate.BootHighScore, score)
+}
+
+// TakeGameOver returns the game that ended since it was last called, if one did.
+func (si *SpaceInvadersHardware) TakeGameOver() (GameOver, bool) {
+	if si.gameOver == nil {
+		return GameOver{}, false
+	}
+	gameOver := *si.gameOver
+	si.gameOver = nil
+	return gameOver, true
+}
+
+// watchForGameOver notices a game ending, at the end of each frame. Frames emulated again
+// while Silent were seen the first time around, so they aren't counted twice.
+func (si *SpaceInvadersHardware) watchForGameOver() {
+	playing := gamestate.Playing(si.ram)
+	if si.playing && !playing && !si.Silent {
+		si.gameOver = &GameOver{Scores: si.Scores(), HighScore: si.HighScore(), Practice: si.practice.active}
+	}
+	si.playing = playing
+}
@@ -0,0 +1,62 @@
+package invaders
+
+import "github.com/braheezy/space-invaders/internal/input"
+
+// HardwareState is a snapshot of the board's registers and latches, for save states. Along with
+// the CPU's state it is all it takes to carry on from a point.
+type HardwareState struct {
+	ShiftAmount   byte
+	ShiftRegister uint16
+	WatchdogTimer byte
+	LastSound1    byte
+	LastSound2    byte
+	FlipScreen    bool
+	Controls      input.State
+	CoinHeld      bool
+	CoinBusy      int
+	// Playing is whether a game was being played at the end of the last frame, which tells
+	// when one starts and ends
+	Playing bool
+	// The practice game's progress
+	PracticePending   bool
+	PracticeActive    bool
+	PracticeHighScore int
+}
+
+// SaveState returns a snapshot of the hardware.
+func (si *SpaceInvadersHardware) SaveState() HardwareState {
+	return HardwareState{
+		ShiftAmount:       si.shiftAmount,
+		ShiftRegister:     si.shiftRegister,
+		WatchdogTimer:     si.watchdogTimer,
+		LastSound1:        si.lastSound1,
+		LastSound2:        si.lastSound2,
+		FlipScreen:        si.flipScreen,
+		Controls:          si.controls,
+		CoinHeld:          si.coin.held,
+		CoinBusy:          si.coin.busy,
+		Playing:           si.playing,
+		PracticePending:   si.practice.pending,
+		PracticeActive:    si.practice.active,
+		PracticeHighScore: si.practice.highScore,
+	}
+}
+
+// LoadState puts the hardware back the way it was when the snapshot was taken. The synthesizer
+// picks up the sound bits from the next port writes.
+func (si *SpaceInvadersHardware) LoadState(state HardwareState) {
+	si.shiftAmount = state.ShiftAmount
+	si.shiftRegister = state.ShiftRegister
+	si.watchdogTimer = state.WatchdogTimer
+	si.lastSound1 = state.LastSound1
+	si.lastSound2 = state.LastSound2
+	si.flipScreen = state.FlipScreen
+	si.controls = state.Controls
+	si.coin = coinMech{held: state.CoinHeld, busy: state.CoinBusy}
+	si.playing = state.Playing
+	si.practice = practiceState{
+		pending:   state.PracticePending,
+		active:    state.PracticeActive,
+		highScore: state.PracticeHighScore,
+	}
+}
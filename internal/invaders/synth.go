@@ -0,0 +1,431 @@
+package invaders
+
+import (
+	"math"
+	"sync"
+)
+
+// The Space Invaders board has no sound chip. Each effect is its own little analog circuit:
+// an SN76477 complex sound generator for the UFO and the noise based effects, 555 timers for
+// the fleet march and the extra ship chime, and RC networks shaping the envelope of each one.
+// analogSynth models those circuits well enough to be recognizable and is driven directly by
+// the bits the CPU writes to ports 3 and 5.
+//
+// Audio is rendered on the emulation side. Every port write first renders audio up to the
+// CPU cycle it happened on, then applies the new bits, so effects start and stop exactly
+// where the program asked for them, independent of the frame rate. The rendered samples are
+// buffered until the audio device pulls them through Read.
+
+const (
+	// synthMaxBuffered caps how much audio can pile up, in seconds, when the emulator
+	// runs faster than real time. Older samples are dropped first.
+	synthMaxBuffered = 0.25
+
+	// Fleet march notes. The four 555 settings produce a descending bass "thump".
+	fleetNote1Hz = 61.8
+	fleetNote2Hz = 55.0
+	fleetNote3Hz = 49.0
+	fleetNote4Hz = 46.2
+)
+
+// rcEnvelope models a capacitor charging towards 1 through one RC constant while its gate
+// is on, and discharging towards 0 through another while it is off.
+type rcEnvelope struct {
+	level   float64
+	attack  float64
+	release float64
+}
+
+// newRCEnvelope creates an envelope from attack and release time constants in seconds.
+func newRCEnvelope(attack, release, sampleRate float64) rcEnvelope {
+	return rcEnvelope{
+		attack:  rcCoefficient(attack, sampleRate),
+		release: rcCoefficient(release, sampleRate),
+	}
+}
+
+func (e *rcEnvelope) step(on bool) float64 {
+	if on {
+		e.level += (1 - e.level) * e.attack
+	} else {
+		e.level -= e.level * e.release
+	}
+	return e.level
+}
+
+// rcCoefficient returns the per-sample step of an RC circuit with time constant tau.
+func rcCoefficient(tau, sampleRate float64) float64 {
+	return 1 - math.Exp(-1/(tau*sampleRate))
+}
+
+// lowPass is a single pole RC low pass filter.
+type lowPass struct {
+	y     float64
+	alpha float64
+}
+
+func newLowPass(cutoff, sampleRate float64) lowPass {
+	return lowPass{alpha: 1 - math.Exp(-2*math.Pi*cutoff/sampleRate)}
+}
+
+func (f *lowPass) step(x float64) float64 {
+	f.y += f.alpha * (x - f.y)
+	return f.y
+}
+
+// oscillator is a phase accumulator that can be read as a square or triangle wave.
+type oscillator struct {
+	phase float64
+}
+
+// step advances the oscillator and returns the new phase in [0, 1).
+func (o *oscillator) step(freq, sampleRate float64) float64 {
+	o.phase += freq / sampleRate
+	o.phase -= math.Floor(o.phase)
+	return o.phase
+}
+
+func (o *oscillator) square(freq, sampleRate float64) float64 {
+	if o.step(freq, sampleRate) < 0.5 {
+		return 1
+	}
+	return -1
+}
+
+func (o *oscillator) triangle(freq, sampleRate float64) float64 {
+	p := o.step(freq, sampleRate)
+	if p < 0.5 {
+		return 4*p - 1
+	}
+	return 3 - 4*p
+}
+
+// noiseSource models the SN76477 noise generator, a shift register clocked by its own
+// oscillator. Its output is a random square wave.
+type noiseSource struct {
+	lfsr  uint32
+	clock oscillator
+	last  float64
+	rate  float64
+}
+
+func (n *noiseSource) step(sampleRate float64) float64 {
+	before := n.clock.phase
+	if n.clock.step(n.rate, sampleRate) < before {
+		// 17 bit maximal length sequence, taps at bits 17 and 14
+		bit := ((n.lfsr >> 16) ^ (n.lfsr >> 13)) & 1
+		n.lfsr = ((n.lfsr << 1) | bit) & 0x1FFFF
+		if n.lfsr&1 != 0 {
+			n.last = 1
+		} else {
+			n.last = -1
+		}
+	}
+	return n.last
+}
+
+// analogSynth renders the Space Invaders sound board.
+type analogSynth struct {
+	mu sync.Mutex
+
+	sampleRate float64
+	// cyclesPerSample is how many CPU cycles pass for each rendered sample
+	cyclesPerSample float64
+	// renderedCycle is the CPU cycle up to which audio has been rendered
+	renderedCycle int
+	// pendingSamples carries the fractional sample left over between renders
+	pendingSamples float64
+	// enabled controls whether audio is produced. When disabled, the synth still tracks
+	// the port bits so it can pick up where the program is if enabled later.
+	enabled bool
+	// paused stops audio being rendered without dropping what is buffered, for CPU time that
+	// has been heard already
+	paused bool
+
+	// port3 and port5 are the last values written to the sound ports
+	port3 byte
+	port5 byte
+
+	// buffer holds rendered samples waiting to be played
+	buffer []int16
+
+	// gains holds the volume of each effect's circuit
+	gains [soundEffectCount]float64
+	// ducking is the gain the other circuits are lowered to while the player explosion
+	// sounds. At 1 they aren't lowered.
+	ducking float64
+
+	noise noiseSource
+
+	// UFO: SN76477 VCO swept by its slow triangle oscillator
+	ufoSLF oscillator
+	ufoVCO oscillator
+	ufoEnv rcEnvelope
+	ufoLP  lowPass
+
+	// Player shot: a one-shot burst of filtered noise with a falling tone
+	shotEnv    rcEnvelope
+	shotTone   oscillator
+	shotFilter lowPass
+	shotHP     lowPass
+	shotFired  bool
+
+	// Player explosion: low rumbling noise held for as long as the bit is on
+	playerDieEnv rcEnvelope
+	playerDieLP  lowPass
+
+	// Invader explosion: short one-shot crunch
+	invaderDieEnv   rcEnvelope
+	invaderDieLP    lowPass
+	invaderDieFired bool
+
+	// Extra ship chime: a 555 tone gated by the bit
+	extraEnv  rcEnvelope
+	extraTone oscillator
+
+	// Fleet march: one 555 with four timing resistors, one per bit
+	fleetOsc oscillator
+	fleetEnv rcEnvelope
+	fleetLP  lowPass
+	fleetHz  float64
+
+	// UFO hit: SN76477 VCO warbling downwards, mixed with noise
+	ufoHitSLF  oscillator
+	ufoHitVCO  oscillator
+	ufoHitEnv  rcEnvelope
+	ufoHitTime float64
+
+	// Final output stage, taking the DC out like the board's coupling capacitor
+	outputHP lowPass
+}
+
+// newAnalogSynth creates a synth producing samples at sampleRate for a CPU running at
+// cpuClock cycles per second.
+func newAnalogSynth(sampleRate, cpuClock int) *analogSynth {
+	sr := float64(sampleRate)
+	s := &analogSynth{
+		sampleRate:      sr,
+		cyclesPerSample: float64(cpuClock) / sr,
+		enabled:         true,
+
+		noise: noiseSource{lfsr: 1, rate: 12000},
+
+		ufoEnv: newRCEnvelope(0.005, 0.03, sr),
+		ufoLP:  newLowPass(2500, sr),
+
+		shotEnv:    newRCEnvelope(0.001, 0.12, sr),
+		shotFilter: newLowPass(4000, sr),
+		shotHP:     newLowPass(800, sr),
+
+		playerDieEnv: newRCEnvelope(0.002, 0.35, sr),
+		playerDieLP:  newLowPass(600, sr),
+
+		invaderDieEnv: newRCEnvelope(0.001, 0.08, sr),
+		invaderDieLP:  newLowPass(3000, sr),
+
+		extraEnv: newRCEnvelope(0.002, 0.04, sr),
+
+		fleetEnv: newRCEnvelope(0.001, 0.06, sr),
+		fleetLP:  newLowPass(150, sr),
+		fleetHz:  fleetNote1Hz,
+
+		ufoHitEnv: newRCEnvelope(0.005, 0.2, sr),
+
+		outputHP: newLowPass(20, sr),
+
+		ducking: 1,
+	}
+	for i := range s.gains {
+		s.gains[i] = 1
+	}
+	return s
+}
+
+// write renders audio up to the given CPU cycle and then applies a new value for a sound port.
+func (s *analogSynth) write(port, value byte, cycle int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.renderUntil(cycle)
+
+	switch port {
+	case 0x03:
+		rising := value &^ s.port3
+		if rising&0x02 != 0 {
+			s.shotFired = true
+		}
+		if rising&0x08 != 0 {
+			s.invaderDieFired = true
+		}
+		s.port3 = value
+	case 0x05:
+		if value&0x10 != 0 && s.port5&0x10 == 0 {
+			s.ufoHitTime = 0
+		}
+		// The fleet oscillator's pitch is selected by whichever note bit is on
+		switch {
+		case value&0x01 != 0:
+			s.fleetHz = fleetNote1Hz
+		case value&0x02 != 0:
+			s.fleetHz = fleetNote2Hz
+		case value&0x04 != 0:
+			s.fleetHz = fleetNote3Hz
+		case value&0x08 != 0:
+			s.fleetHz = fleetNote4Hz
+		}
+		s.port5 = value
+	}
+}
+
+// flush renders audio up to the given CPU cycle.
+func (s *analogSynth) flush(cycle int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.renderUntil(cycle)
+}
+
+// setEnabled turns audio generation on or off.
+func (s *analogSynth) setEnabled(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !enabled {
+		s.buffer = s.buffer[:0]
+	}
+	s.enabled = enabled
+}
+
+// setPaused stops or restarts rendering, keeping the buffered audio.
+func (s *analogSynth) setPaused(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = paused
+}
+
+// setGain sets the volume of one effect's circuit.
+func (s *analogSynth) setGain(effect SoundEffect, gain float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gains[effect] = gain
+}
+
+// setDucking sets the gain the other circuits are lowered to while the player explosion sounds.
+func (s *analogSynth) setDucking(gain float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ducking = gain
+}
+
+// Read fulfills io.Reader for the audio player, producing signed 16-bit little endian mono
+// samples. If the emulator hasn't produced enough audio yet, the rest is filled with silence
+// so the audio device never stalls.
+func (s *analogSynth) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := len(p) / 2
+	available := min(samples, len(s.buffer))
+	for i := 0; i < available; i++ {
+		p[i*2] = byte(s.buffer[i])
+		p[i*2+1] = byte(s.buffer[i] >> 8)
+	}
+	for i := available * 2; i < samples*2; i++ {
+		p[i] = 0
+	}
+	s.buffer = s.buffer[:copy(s.buffer, s.buffer[available:])]
+
+	return samples * 2, nil
+}
+
+// renderUntil generates samples covering the CPU time since the last render.
+// The caller must hold the lock.
+func (s *analogSynth) renderUntil(cycle int) {
+	elapsed := cycle - s.renderedCycle
+	s.renderedCycle = cycle
+	if elapsed <= 0 || !s.enabled || s.paused {
+		return
+	}
+
+	s.pendingSamples += float64(elapsed) / s.cyclesPerSample
+	count := int(s.pendingSamples)
+	s.pendingSamples -= float64(count)
+
+	for i := 0; i < count; i++ {
+		s.buffer = append(s.buffer, s.nextSample())
+	}
+
+	if limit := int(s.sampleRate * synthMaxBuffered); len(s.buffer) > limit {
+		s.buffer = s.buffer[:copy(s.buffer, s.buffer[len(s.buffer)-limit:])]
+	}
+}
+
+// nextSample steps every circuit by one sample and mixes their outputs.
+func (s *analogSynth) nextSample() int16 {
+	sr := s.sampleRate
+	noise := s.noise.step(sr)
+	var mix float64
+
+	// Player explosion, mixed in last so ducking lowers everything else while it sounds
+	playerDieLevel := s.playerDieEnv.step(s.port3&0x04 != 0)
+	playerDie := s.gains[PlayerDieSound] * 0.9 * playerDieLevel * s.playerDieLP.step(noise)
+
+	// UFO: the slow oscillator sweeps the VCO between roughly 400 and 1000 Hz
+	ufoLevel := s.ufoEnv.step(s.port3&0x01 != 0)
+	slf := s.ufoSLF.triangle(6.5, sr)
+	ufo := s.ufoVCO.square(700+300*slf, sr)
+	mix += s.gains[UFOSound] * 0.22 * ufoLevel * s.ufoLP.step(ufo)
+
+	// Player shot: triggered by the rising edge, it runs its course even if the bit drops
+	if s.shotFired {
+		s.shotEnv.level = 1
+		s.shotFired = false
+	}
+	shotLevel := s.shotEnv.step(false)
+	shotTone := s.shotTone.square(300+1200*shotLevel, sr)
+	shotNoise := s.shotFilter.step(noise)
+	shotNoise -= s.shotHP.step(shotNoise)
+	mix += s.gains[ShotSound] * 0.3 * shotLevel * (0.6*shotNoise + 0.4*shotTone)
+
+	// Invader explosion
+	if s.invaderDieFired {
+		s.invaderDieEnv.level = 1
+		s.invaderDieFired = false
+	}
+	invaderDieLevel := s.invaderDieEnv.step(false)
+	mix += s.gains[InvaderDieSound] * 0.5 * invaderDieLevel * s.invaderDieLP.step(noise)
+
+	// Extra ship chime
+	extraLevel := s.extraEnv.step(s.port3&0x10 != 0)
+	mix += s.gains[ExtraShipSound] * 0.2 * extraLevel * s.extraTone.triangle(1100, sr)
+
+	// Fleet march
+	fleetLevel := s.fleetEnv.step(s.port5&0x0F != 0)
+	fleet := s.fleetLP.step(s.fleetOsc.square(s.fleetHz, sr))
+	mix += s.gains[FleetSound] * 1.2 * fleetLevel * fleet
+
+	// UFO hit: pitch falls over time while warbling
+	ufoHitLevel := s.ufoHitEnv.step(s.port5&0x10 != 0)
+	if ufoHitLevel > 0.001 {
+		s.ufoHitTime += 1 / sr
+		warble := s.ufoHitSLF.triangle(12, sr)
+		freq := 400 + 800*math.Exp(-s.ufoHitTime*2) + 150*warble
+		mix += s.gains[UFOHitSound] * 0.25 * ufoHitLevel * (0.8*s.ufoHitVCO.square(freq, sr) + 0.2*noise)
+	}
+
+	// The ducking follows the explosion's envelope, so it fades in and out without clicks
+	mix *= 1 - (1-s.ducking)*playerDieLevel
+	mix += playerDie
+
+	// Bit 5 of port 3 enables the amplifier. The game turns it off outside of play.
+	if s.port3&0x20 == 0 {
+		mix = 0
+	}
+
+	// Remove DC offset and softly clip like an overdriven amplifier would
+	mix -= s.outputHP.step(mix)
+	return int16(math.Tanh(mix) * 0.8 * math.MaxInt16)
+}
@@ -0,0 +1,154 @@
+package invaders
+
+import "testing"
+
+const testCyclesPerFrame = 33334
+
+func newTestSynth() *analogSynth {
+	return newAnalogSynth(44100, testCyclesPerFrame*60)
+}
+
+func TestSynthRendersOneFrameOfSamples(t *testing.T) {
+	s := newTestSynth()
+	s.flush(testCyclesPerFrame)
+
+	// 44100 samples per second at 60 frames per second
+	if len(s.buffer) != 735 {
+		t.Errorf("expected 735 samples for one frame, got %d", len(s.buffer))
+	}
+}
+
+func TestSynthPortWritesAreTimestamped(t *testing.T) {
+	s := newTestSynth()
+	// Turn the UFO on halfway through the frame
+	s.write(0x03, 0x21, testCyclesPerFrame/2)
+	s.flush(testCyclesPerFrame)
+
+	for i, sample := range s.buffer[:360] {
+		if sample != 0 {
+			t.Fatalf("expected silence before the port write, sample %d is %d", i, sample)
+		}
+	}
+	if peak(s.buffer[368:]) == 0 {
+		t.Error("expected sound after the port write")
+	}
+}
+
+func TestSynthAmplifierEnable(t *testing.T) {
+	tests := []struct {
+		name      string
+		port3     byte
+		wantSound bool
+	}{
+		{name: "UFO with amplifier off", port3: 0x01, wantSound: false},
+		{name: "UFO with amplifier on", port3: 0x21, wantSound: true},
+		{name: "Player death with amplifier on", port3: 0x24, wantSound: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSynth()
+			s.write(0x03, tt.port3, 0)
+			s.flush(testCyclesPerFrame)
+
+			if got := peak(s.buffer) != 0; got != tt.wantSound {
+				t.Errorf("expected sound %t, got %t", tt.wantSound, got)
+			}
+		})
+	}
+}
+
+func TestSynthFleetNotes(t *testing.T) {
+	s := newTestSynth()
+	s.write(0x03, 0x20, 0)
+	for i, want := range []float64{fleetNote1Hz, fleetNote2Hz, fleetNote3Hz, fleetNote4Hz} {
+		s.write(0x05, 1<<i, 0)
+		if s.fleetHz != want {
+			t.Errorf("bit %d: expected fleet note %.1f Hz, got %.1f Hz", i, want, s.fleetHz)
+		}
+	}
+}
+
+func TestSynthDucking(t *testing.T) {
+	tests := []struct {
+		name    string
+		ducking float64
+		ducked  bool
+	}{
+		{name: "Off", ducking: 1},
+		{name: "On", ducking: duckingGain, ducked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Play the fleet march alone, then with the player explosion, keeping every
+			// circuit but the explosion's in step
+			alone, exploding := newTestSynth(), newTestSynth()
+			exploding.setDucking(tt.ducking)
+			exploding.setGain(PlayerDieSound, 0)
+			alone.write(0x03, 0x20, 0)
+			exploding.write(0x03, 0x24, 0)
+			for _, s := range []*analogSynth{alone, exploding} {
+				s.write(0x05, 0x01, 0)
+				s.flush(testCyclesPerFrame * 10)
+			}
+
+			ratio := float64(peak(exploding.buffer[4410:])) / float64(peak(alone.buffer[4410:]))
+			if ducked := ratio < 0.6; ducked != tt.ducked {
+				t.Errorf("expected ducked %t, the fleet played at %.2f of its volume", tt.ducked, ratio)
+			}
+		})
+	}
+}
+
+func TestSynthDisabledProducesNothing(t *testing.T) {
+	s := newTestSynth()
+	s.setEnabled(false)
+	s.write(0x03, 0x21, 0)
+	s.flush(testCyclesPerFrame)
+
+	if len(s.buffer) != 0 {
+		t.Errorf("expected no samples while disabled, got %d", len(s.buffer))
+	}
+}
+
+func TestSynthReadPadsWithSilence(t *testing.T) {
+	s := newTestSynth()
+	s.buffer = append(s.buffer, 0x1234)
+
+	p := make([]byte, 8)
+	for i := range p {
+		p[i] = 0xFF
+	}
+	n, err := s.Read(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(p) {
+		t.Errorf("expected to read %d bytes, got %d", len(p), n)
+	}
+	want := []byte{0x34, 0x12, 0, 0, 0, 0, 0, 0}
+	for i := range want {
+		if p[i] != want[i] {
+			t.Errorf("byte %d: expected %02X, got %02X", i, want[i], p[i])
+		}
+	}
+	if len(s.buffer) != 0 {
+		t.Errorf("expected buffer to be drained, %d samples left", len(s.buffer))
+	}
+}
+
+// peak returns the largest absolute sample value.
+func peak(samples []int16) int {
+	var max int
+	for _, sample := range samples {
+		v := int(sample)
+		if v < 0 {
+			v = -v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
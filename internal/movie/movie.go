@@ -0,0 +1,176 @@
+// Package movie records the controls of a game frame by frame, so it can be played back exactly.
+//
+// A movie starts from power on. Along with the controls it keeps the settings and ROM the game
+// was played with, and a checksum of the RAM every so often, so playback can tell when it no
+// longer matches the recording.
+package movie
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// Version is the version of the movie format written.
+const Version = 1
+
+// DefaultChecksumInterval is how many frames apart the RAM checksums are, a second of play.
+const DefaultChecksumInterval = 60
+
+// Settings are the machine settings a movie was recorded with. They change how the game plays,
+// so playback has to use the same ones.
+type Settings struct {
+	// Ships is the number of ships per game, from the DIP switches
+	Ships int `json:"ships"`
+	// ExtraShipAt1000 gives the extra ship at 1000 points instead of 1500
+	ExtraShipAt1000 bool `json:"extra_ship_at_1000"`
+	// HideCoinInfo is the DIP switch that hides the coin info on the demo screen
+	HideCoinInfo bool `json:"hide_coin_info"`
+	// Cabinet is the cabinet type, which decides whether player 2 has their own controls
+	Cabinet string `json:"cabinet"`
+	// FreePlay is whether the game was on free play, which starts games without coins
+	FreePlay bool `json:"free_play"`
+	// PracticeWave, PracticeLives and PracticeScore are the wave, ships and score practice
+	// games started with. PracticeWave is 0 unless they were practice games.
+	PracticeWave  int `json:"practice_wave,omitempty"`
+	PracticeLives int `json:"practice_lives,omitempty"`
+	PracticeScore int `json:"practice_score,omitempty"`
+	// LimitTPS is whether the game was limited to 60 frames a second
+	LimitTPS bool `json:"limit_tps"`
+}
+
+// Header describes a movie. It is the first line of a movie file, as JSON.
+type Header struct {
+	Version int `json:"version"`
+	// ROMHash identifies the ROM the movie was recorded with. See HashROM.
+	ROMHash  string   `json:"rom_sha1"`
+	Settings Settings `json:"settings"`
+	// ChecksumInterval is how many frames apart the RAM checksums are
+	ChecksumInterval int `json:"checksum_interval"`
+}
+
+// HashROM returns the SHA-1 of a ROM as hex, the way ROM sets are usually identified.
+func HashROM(rom []byte) string {
+	sum := sha1.Sum(rom)
+	return hex.EncodeToString(sum[:])
+}
+
+// Checksum returns the checksum of the RAM stored in movies.
+func Checksum(ram []byte) uint32 {
+	return crc32.ChecksumIEEE(ram)
+}
+
+// Movie is a recorded game.
+//
+// After the header, each line of a movie file is one of:
+//
+//	input <frame> <actions>     the controls held from that frame on, as in an input script
+//	checksum <frame> <crc32>    the RAM checksum after that many frames were emulated
+//	end <frame>                 the number of frames in the movie
+type Movie struct {
+	Header Header
+	// Inputs are the controls for each frame
+	Inputs []input.State
+	// Checksums are the RAM checksums, by the number of frames emulated before them
+	Checksums map[int]uint32
+}
+
+// Frames returns the number of frames in the movie.
+func (m *Movie) Frames() int {
+	return len(m.Inputs)
+}
+
+// Load reads a movie file.
+func Load(path string) (*Movie, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file)
+}
+
+// Read reads a movie.
+func Read(r io.Reader) (*Movie, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty movie")
+	}
+	m := &Movie{Checksums: map[int]uint32{}}
+	if err := json.Unmarshal(scanner.Bytes(), &m.Header); err != nil {
+		return nil, fmt.Errorf("invalid movie header: %w", err)
+	}
+	if m.Header.Version != Version {
+		return nil, fmt.Errorf("unsupported movie version %d", m.Header.Version)
+	}
+
+	// The controls last from each input line to the next
+	var state input.State
+	end := -1
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: missing frame", line)
+		}
+		frame, err := strconv.Atoi(fields[1])
+		if err != nil || frame < len(m.Inputs) {
+			return nil, fmt.Errorf("line %d: invalid frame %q", line, fields[1])
+		}
+
+		switch fields[0] {
+		case "input":
+			m.fill(frame, state)
+			if state, err = input.ParseState(fields[2:]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		case "checksum":
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("line %d: missing checksum", line)
+			}
+			checksum, err := strconv.ParseUint(fields[2], 16, 32)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid checksum %q", line, fields[2])
+			}
+			m.Checksums[frame] = uint32(checksum)
+		case "end":
+			end = frame
+		default:
+			return nil, fmt.Errorf("line %d: unknown entry %q", line, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// A movie that wasn't closed properly ends at its last line
+	if end < 0 {
+		end = len(m.Inputs)
+		for frame := range m.Checksums {
+			end = max(end, frame)
+		}
+	}
+	m.fill(end, state)
+	return m, nil
+}
+
+// fill holds the controls until the frame.
+func (m *Movie) fill(frame int, state input.State) {
+	for len(m.Inputs) < frame {
+		m.Inputs = append(m.Inputs, state)
+	}
+}
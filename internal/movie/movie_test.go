@@ -0,0 +1,157 @@
+package movie
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// sequence is a source that plays a list of states, one per frame.
+type sequence struct {
+	states []input.State
+	frame  int
+}
+
+func (s *sequence) Poll() input.State {
+	state := s.states[s.frame%len(s.states)]
+	s.frame++
+	return state
+}
+
+// record records the states into a movie, with a fake RAM that changes with the controls.
+func record(t *testing.T, states []input.State, interval int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	header := Header{ROMHash: "abc", Settings: Settings{Ships: 3, Cabinet: "Upright"}, ChecksumInterval: interval}
+	recorder, err := NewRecorder(&buf, header, &sequence{states: states})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := recorder.Header(); got.Version != Version || got.Settings != header.Settings {
+		t.Errorf("expected the recorder to keep the header, got %+v", got)
+	}
+	ram := make([]byte, 16)
+	for range states {
+		state := recorder.Poll()
+		ram[0] += byte(state)
+		if err := recorder.EndFrame(ram); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRecordAndPlay(t *testing.T) {
+	coin := input.State(0).With(input.Coin, true)
+	fire := input.State(0).With(input.P1Fire, true)
+	states := []input.State{0, 0, coin, coin, 0, fire, fire, fire, 0, 0}
+	data := record(t, states, 3)
+
+	m, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Header.Version != Version || m.Header.ROMHash != "abc" || m.Header.Settings.Ships != 3 {
+		t.Errorf("unexpected header %+v", m.Header)
+	}
+	if m.Frames() != len(states) {
+		t.Fatalf("expected %d frames, got %d", len(states), m.Frames())
+	}
+	if len(m.Checksums) != 3 {
+		t.Errorf("expected a checksum every 3 frames, got %v", m.Checksums)
+	}
+
+	// Playing back gives the same controls and RAM
+	player := NewPlayer(m)
+	ram := make([]byte, 16)
+	for frame, expected := range states {
+		state := player.Poll()
+		if state != expected {
+			t.Errorf("frame %d: expected %q, got %q", frame, expected, state)
+		}
+		ram[0] += byte(state)
+		if err := player.EndFrame(ram); err != nil {
+			t.Errorf("frame %d: %v", frame, err)
+		}
+	}
+	if !player.Done() {
+		t.Error("expected the movie to be done")
+	}
+	if state := player.Poll(); state != 0 {
+		t.Errorf("expected nothing held after the movie, got %q", state)
+	}
+}
+
+func TestDesync(t *testing.T) {
+	states := []input.State{input.State(0).With(input.Coin, true), 0}
+	m, err := Read(bytes.NewReader(record(t, states, 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	player := NewPlayer(m)
+	ram := make([]byte, 16)
+	player.Poll()
+	player.EndFrame(ram)
+	player.Poll()
+	// The RAM never saw the coin, so it doesn't match
+	err = player.EndFrame(ram)
+	var desync *DesyncError
+	if !errors.As(err, &desync) {
+		t.Fatalf("expected a desync, got %v", err)
+	}
+	if desync.Frame != 2 {
+		t.Errorf("expected the desync after 2 frames, got %d", desync.Frame)
+	}
+}
+
+func TestReadUnclosedMovie(t *testing.T) {
+	data := record(t, []input.State{0, 0, 0, 0}, 2)
+	// Cut off the end line, as if the game crashed
+	unclosed := data[:bytes.LastIndex(data, []byte("end"))]
+
+	m, err := Read(bytes.NewReader(unclosed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Frames() != 4 {
+		t.Errorf("expected the movie to end at the last checksum, got %d frames", m.Frames())
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	header := `{"version":1,"rom_sha1":"abc"}` + "\n"
+	tests := []struct {
+		name  string
+		movie string
+	}{
+		{name: "Empty", movie: ""},
+		{name: "Bad header", movie: "not json\n"},
+		{name: "Wrong version", movie: `{"version":99}` + "\n"},
+		{name: "Unknown entry", movie: header + "jump 1\n"},
+		{name: "Unknown action", movie: header + "input 1 jump\n"},
+		{name: "Out of order", movie: header + "input 5 coin\ninput 2\n"},
+		{name: "Bad checksum", movie: header + "checksum 60 nothex\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(strings.NewReader(tt.movie)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestHashROM(t *testing.T) {
+	// The SHA-1 of "abc"
+	if got := HashROM([]byte("abc")); got != "a9993e364706816aba3e25717850c26c9cd0d89d" {
+		t.Errorf("unexpected hash %s", got)
+	}
+}
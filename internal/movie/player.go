@@ -0,0 +1,68 @@
+package movie
+
+import (
+	"fmt"
+
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// DesyncError is returned when the game being played back no longer matches the recording.
+type DesyncError struct {
+	// Frame is the number of frames emulated when the RAM stopped matching
+	Frame            int
+	Expected, Actual uint32
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("desync after %d frames: RAM checksum %08x, expected %08x", e.Frame, e.Actual, e.Expected)
+}
+
+// Player is a source that plays back the controls of a movie.
+type Player struct {
+	movie *Movie
+	// frames is the number of frames polled
+	frames int
+}
+
+// NewPlayer plays back a movie from the start. The game has to start from power on too.
+func NewPlayer(m *Movie) *Player {
+	return &Player{movie: m}
+}
+
+// Movie returns the movie being played.
+func (p *Player) Movie() *Movie {
+	return p.movie
+}
+
+// Poll fulfills input.Source. Once the movie is over, nothing is held.
+func (p *Player) Poll() input.State {
+	var state input.State
+	if p.frames < len(p.movie.Inputs) {
+		state = p.movie.Inputs[p.frames]
+	}
+	p.frames++
+	return state
+}
+
+// EndFrame checks the RAM against the movie's checksum, when it has one for this frame.
+// It is called after each frame is emulated, and returns a *DesyncError if they differ.
+func (p *Player) EndFrame(ram []byte) error {
+	expected, ok := p.movie.Checksums[p.frames]
+	if !ok {
+		return nil
+	}
+	if actual := Checksum(ram); actual != expected {
+		return &DesyncError{Frame: p.frames, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// Frames returns the number of frames played.
+func (p *Player) Frames() int {
+	return p.frames
+}
+
+// Done reports whether every frame of the movie has been played.
+func (p *Player) Done() bool {
+	return p.frames >= len(p.movie.Inputs)
+}
@@ -0,0 +1,109 @@
+package movie
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/braheezy/space-invaders/internal/input"
+)
+
+// Recorder is a source that records the controls of another source into a movie as the game
+// is played. Lines are written as they happen, so a movie survives the game crashing.
+type Recorder struct {
+	header   Header
+	source   input.Source
+	file     io.Closer
+	w        *bufio.Writer
+	interval int
+	// frames is the number of frames polled
+	frames int
+	last   input.State
+}
+
+// Create starts recording a movie to a file. Recording has to start at power on.
+func Create(path string, header Header, source input.Source) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	recorder, err := NewRecorder(file, header, source)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	recorder.file = file
+	return recorder, nil
+}
+
+// NewRecorder starts recording a movie to w.
+func NewRecorder(w io.Writer, header Header, source input.Source) (*Recorder, error) {
+	header.Version = Version
+	if header.ChecksumInterval <= 0 {
+		header.ChecksumInterval = DefaultChecksumInterval
+	}
+	r := &Recorder{
+		header:   header,
+		source:   source,
+		w:        bufio.NewWriter(w),
+		interval: header.ChecksumInterval,
+	}
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(r.w, "%s\n", encoded); err != nil {
+		return nil, err
+	}
+	// Nothing is held before the first line
+	if _, err := fmt.Fprintln(r.w, "input 0"); err != nil {
+		return nil, err
+	}
+	return r, r.w.Flush()
+}
+
+// Header returns the header the movie was recorded with.
+func (r *Recorder) Header() Header {
+	return r.header
+}
+
+// Poll fulfills input.Source, recording the controls whenever they change.
+func (r *Recorder) Poll() input.State {
+	state := r.source.Poll()
+	if state != r.last {
+		fmt.Fprintf(r.w, "input %d %s\n", r.frames, state)
+		r.last = state
+	}
+	r.frames++
+	return state
+}
+
+// EndFrame records the checksum of the RAM when one is due. It is called after each frame is emulated.
+func (r *Recorder) EndFrame(ram []byte) error {
+	if r.frames%r.interval != 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(r.w, "checksum %d %08x\n", r.frames, Checksum(ram)); err != nil {
+		return err
+	}
+	return r.w.Flush()
+}
+
+// Frames returns the number of frames recorded.
+func (r *Recorder) Frames() int {
+	return r.frames
+}
+
+// Close ends the movie.
+func (r *Recorder) Close() error {
+	fmt.Fprintf(r.w, "end %d\n", r.frames)
+	err := r.w.Flush()
+	if r.file != nil {
+		if closeErr := r.file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
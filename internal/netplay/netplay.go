@@ -0,0 +1,439 @@
+// Package netplay lets two players on different machines play each other over TCP.
+//
+// Both machines emulate the whole game from power on, and only the controls are sent between
+// them, one message per frame. Waiting for the other player's controls every frame would make
+// the game as slow as the connection, so instead each side guesses that the other player is
+// still holding what they held last, and carries on. When the real controls arrive and the
+// guess was wrong, the machine is put back to a save state from before that frame and the
+// frames since are emulated again with the right controls. This is called rollback.
+//
+// Every so often both sides send a checksum of the RAM at a frame they both have the real
+// controls for, so a game that has stopped matching is caught.
+package netplay
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/braheezy/space-invaders/internal/savestate"
+)
+
+// Version is the version of the netplay protocol.
+const Version = 1
+
+const (
+	// DefaultInputDelay is how many frames the local controls are held back by default. A
+	// little delay gives the peer's controls time to arrive, so rollbacks are rarer.
+	DefaultInputDelay = 2
+	// MaxRollback is how many frames the game can run ahead of the peer's controls. Past
+	// that it waits for them.
+	MaxRollback = 8
+	// SyncInterval is how many frames apart the RAM checksums are compared, a second of play.
+	SyncInterval = 60
+	// HandshakeTimeout is how long to wait for the peer to say hello.
+	HandshakeTimeout = 10 * time.Second
+)
+
+// currentPlayer is the address, within RAM, of the player whose turn it is: 0x21 for player 1
+// and 0x22 for player 2.
+const currentPlayer = 0x0067
+
+// Config sets up a session.
+type Config struct {
+	// InputDelay is how many frames after being polled the local controls take effect
+	InputDelay int
+	// Settings are the settings to play with. The host's are used by both sides.
+	Settings movie.Settings
+}
+
+// Session is one side of a netplay game.
+type Session struct {
+	conn     net.Conn
+	vm       *emulator.CPU8080
+	hardware *invaders.SpaceInvadersHardware
+	local    input.Source
+	// player is 0 for the host, who plays player 1, and 1 for the guest
+	player   int
+	settings movie.Settings
+
+	// frame is the number of frames emulated
+	frame int
+	// localInputs are this side's controls by frame, starting with the input delay
+	localInputs []input.State
+	// remoteInputs are the peer's controls received so far, by frame
+	remoteInputs []input.State
+	// predicted are the peer's controls that were guessed for frames not received yet
+	predicted map[int]input.State
+	// snapshots are the save states from the start of each frame that might be rolled back to
+	snapshots map[int]*savestate.State
+	// frameControls are the controls of the frame being emulated, for the hardware to poll
+	frameControls input.State
+
+	// nextSync is the frame count of the next RAM checksum to send
+	nextSync int
+	// checksums and peerChecksums are the RAM checksums by frame count, until they're compared
+	checksums     map[int]uint32
+	peerChecksums map[int]uint32
+
+	incoming chan message
+	err      error
+
+	// Rollbacks counts the times frames were emulated again because of a wrong guess
+	Rollbacks int
+	// Stalls counts the frames the game waited for the peer
+	Stalls int
+}
+
+// Host waits for a player to connect to the address, then starts a session as player 1.
+func Host(address string, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware, local input.Source, config Config) (*Session, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewSession(conn, 0, vm, hardware, local, config)
+}
+
+// Join connects to a host at the address and starts a session as player 2.
+func Join(address string, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware, local input.Source, config Config) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", address, HandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewSession(conn, 1, vm, hardware, local, config)
+}
+
+// NewSession starts a session over a connection to the peer, as the host when player is 0 or
+// the guest when player is 1. The machine has to be at power on with interrupts scheduled by
+// the CPU. Its controls are taken over by the session, which reads the local player's from
+// local. The session owns the connection, and closes it if it can't start.
+func NewSession(conn net.Conn, player int, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware, local input.Source, config Config) (*Session, error) {
+	s, err := newSession(conn, player, vm, hardware, local, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func newSession(conn net.Conn, player int, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware, local input.Source, config Config) (*Session, error) {
+	if vm.FrameCount() != 0 {
+		return nil, errors.New("netplay has to start from power on")
+	}
+	if config.InputDelay < 0 || config.InputDelay >= MaxRollback {
+		return nil, fmt.Errorf("input delay must be from 0 to %d frames", MaxRollback-1)
+	}
+
+	s := &Session{
+		conn:          conn,
+		vm:            vm,
+		hardware:      hardware,
+		local:         local,
+		player:        player,
+		predicted:     map[int]input.State{},
+		snapshots:     map[int]*savestate.State{},
+		nextSync:      SyncInterval,
+		checksums:     map[int]uint32{},
+		peerChecksums: map[int]uint32{},
+		incoming:      make(chan message, 1024),
+	}
+	go s.receive()
+
+	mine := hello{
+		Version:    Version,
+		ROMHash:    movie.HashROM(hardware.ROM()),
+		Player:     player,
+		Settings:   config.Settings,
+		InputDelay: config.InputDelay,
+	}
+	if err := writeHello(conn, mine); err != nil {
+		return nil, err
+	}
+	theirs, err := s.awaitHello()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case theirs.Version != Version:
+		return nil, fmt.Errorf("peer speaks netplay version %d, not %d", theirs.Version, Version)
+	case theirs.ROMHash != mine.ROMHash:
+		return nil, fmt.Errorf("peer has a different ROM (%s)", theirs.ROMHash)
+	case theirs.Player == player:
+		return nil, fmt.Errorf("both sides are player %d", player+1)
+	case theirs.InputDelay < 0 || theirs.InputDelay >= MaxRollback:
+		return nil, fmt.Errorf("peer has an invalid input delay of %d", theirs.InputDelay)
+	}
+
+	// Both sides play with the host's settings, at whatever speed the frames are asked for
+	s.settings = config.Settings
+	if player != 0 {
+		s.settings = theirs.Settings
+	}
+	s.settings.LimitTPS = false
+	if err := replay.ApplySettings(vm, hardware, s.settings); err != nil {
+		return nil, err
+	}
+
+	// Nothing is pressed until each side's input delay has passed
+	s.localInputs = make([]input.State, config.InputDelay)
+	s.remoteInputs = make([]input.State, theirs.InputDelay)
+	hardware.Input = s
+	return s, nil
+}
+
+// awaitHello waits for the peer's hello.
+func (s *Session) awaitHello() (hello, error) {
+	select {
+	case m := <-s.incoming:
+		if m.err != nil {
+			return hello{}, m.err
+		}
+		if m.kind != helloMessage {
+			return hello{}, errors.New("peer didn't say hello")
+		}
+		return m.hello, nil
+	case <-time.After(HandshakeTimeout):
+		return hello{}, errors.New("timed out waiting for the peer")
+	}
+}
+
+// receive reads messages from the peer until the connection stops.
+func (s *Session) receive() {
+	for {
+		m, err := readMessage(s.conn)
+		if err != nil {
+			s.incoming <- message{err: err}
+			close(s.incoming)
+			return
+		}
+		s.incoming <- m
+	}
+}
+
+// Settings returns the settings both sides are playing with.
+func (s *Session) Settings() movie.Settings {
+	return s.settings
+}
+
+// Player returns 0 for the host, who plays player 1, and 1 for the guest.
+func (s *Session) Player() int {
+	return s.player
+}
+
+// Frame returns the number of frames emulated.
+func (s *Session) Frame() int {
+	return s.frame
+}
+
+// Confirmed returns the number of frames emulated with the peer's real controls, which won't
+// be rolled back.
+func (s *Session) Confirmed() int {
+	return min(s.frame, len(s.remoteInputs))
+}
+
+// Poll fulfills input.Source for the hardware, with the controls of the frame being emulated.
+func (s *Session) Poll() input.State {
+	return s.frameControls
+}
+
+// Update runs the game for one frame. It handles the peer's messages, rolling back if a
+// guess was wrong, then emulates the next frame unless the game is too far ahead of the peer.
+// It returns an error once the peer disconnects or the games stop matching.
+func (s *Session) Update() error {
+	return s.step(true)
+}
+
+// step handles the peer's messages and emulates the next frame if advance is set.
+func (s *Session) step(advance bool) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.err = s.handleMessages(); s.err != nil {
+		return s.err
+	}
+
+	if advance {
+		if s.frame-len(s.remoteInputs) < MaxRollback {
+			if s.err = s.advance(); s.err != nil {
+				return s.err
+			}
+		} else {
+			s.Stalls++
+		}
+	}
+
+	s.err = s.confirm()
+	return s.err
+}
+
+// handleMessages takes in the messages that have arrived, rolling back to the first frame
+// whose controls were guessed wrong.
+func (s *Session) handleMessages() error {
+	rollbackTo := -1
+	for {
+		var m message
+		select {
+		case m = <-s.incoming:
+		default:
+			if rollbackTo >= 0 {
+				s.rollback(rollbackTo)
+			}
+			return nil
+		}
+
+		switch {
+		case m.err != nil:
+			return fmt.Errorf("netplay: connection lost: %w", m.err)
+		case m.kind == inputMessage:
+			if m.frame != len(s.remoteInputs) {
+				return fmt.Errorf("netplay: expected controls for frame %d, got %d", len(s.remoteInputs), m.frame)
+			}
+			s.remoteInputs = append(s.remoteInputs, m.state)
+			if guess, ok := s.predicted[m.frame]; ok {
+				delete(s.predicted, m.frame)
+				if guess != m.state && rollbackTo < 0 {
+					rollbackTo = m.frame
+				}
+			}
+		case m.kind == syncMessage:
+			s.peerChecksums[m.frame] = m.checksum
+		default:
+			return fmt.Errorf("netplay: unexpected message type %d", m.kind)
+		}
+	}
+}
+
+// advance emulates the next frame, sending the peer the controls polled now.
+func (s *Session) advance() error {
+	// The controls polled now take effect after the input delay
+	state := playerControls(s.local.Poll(), s.player)
+	if err := writeInput(s.conn, len(s.localInputs), state); err != nil {
+		return fmt.Errorf("netplay: connection lost: %w", err)
+	}
+	s.localInputs = append(s.localInputs, state)
+
+	s.snapshots[s.frame] = savestate.Save(s.vm, s.hardware)
+	s.emulate(s.frame)
+	s.frame++
+	return nil
+}
+
+// rollback puts the machine back to the start of a frame and emulates the frames since again,
+// quietly, with the controls now known.
+func (s *Session) rollback(frame int) {
+	s.Rollbacks++
+	s.snapshots[frame].Load(s.vm, s.hardware)
+
+	s.hardware.Silent = true
+	defer func() { s.hardware.Silent = false }()
+	for f := frame; f < s.frame; f++ {
+		if f > frame {
+			s.snapshots[f] = savestate.Save(s.vm, s.hardware)
+		}
+		s.emulate(f)
+	}
+}
+
+// emulate runs one frame with the best controls known for it.
+func (s *Session) emulate(frame int) {
+	var remote input.State
+	switch {
+	case frame < len(s.remoteInputs):
+		remote = s.remoteInputs[frame]
+	case len(s.remoteInputs) > 0:
+		// Guess the peer is still holding what they held last
+		remote = s.remoteInputs[len(s.remoteInputs)-1]
+		s.predicted[frame] = remote
+	default:
+		s.predicted[frame] = remote
+	}
+
+	controls := [2]input.State{s.localInputs[frame], remote}
+	if s.player != 0 {
+		controls[0], controls[1] = controls[1], controls[0]
+	}
+	// On an upright cabinet player 1's controls steer player 2's ship too, so they only
+	// count on player 1's turn
+	if s.hardware.CabinetType == invaders.Upright && s.hardware.RAM()[currentPlayer] == 0x22 {
+		controls[0] = playerControls(controls[0], -1)
+	}
+	s.frameControls = controls[0] | controls[1]
+	s.vm.Update()
+}
+
+// confirm checks the RAM against the peer's at the frames both sides have the real controls
+// for, and forgets the save states that can no longer be rolled back to.
+func (s *Session) confirm() error {
+	confirmed := s.Confirmed()
+	for s.nextSync <= confirmed {
+		ram := s.hardware.RAM()
+		if s.nextSync < s.frame {
+			ram = s.snapshots[s.nextSync].RAM()
+		}
+		checksum := movie.Checksum(ram)
+		if err := writeSync(s.conn, s.nextSync, checksum); err != nil {
+			return fmt.Errorf("netplay: connection lost: %w", err)
+		}
+		s.checksums[s.nextSync] = checksum
+		s.nextSync += SyncInterval
+	}
+
+	for frame, checksum := range s.checksums {
+		peerChecksum, ok := s.peerChecksums[frame]
+		if !ok {
+			continue
+		}
+		if checksum != peerChecksum {
+			return fmt.Errorf("netplay: %w", &movie.DesyncError{Frame: frame, Expected: peerChecksum, Actual: checksum})
+		}
+		delete(s.checksums, frame)
+		delete(s.peerChecksums, frame)
+	}
+
+	for frame := range s.snapshots {
+		if frame < confirmed {
+			delete(s.snapshots, frame)
+		}
+	}
+	return nil
+}
+
+// Close ends the session.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// playerControls keeps the shared controls and the player's own. Either set of ship controls
+// on the local machine count as the player's, so both players can use their usual keys. A
+// player of -1 keeps only the shared controls.
+func playerControls(state input.State, player int) input.State {
+	ships := [2][3]input.Action{
+		{input.P1Fire, input.P1Left, input.P1Right},
+		{input.P2Fire, input.P2Left, input.P2Right},
+	}
+
+	var controls input.State
+	for _, action := range []input.Action{input.Coin, input.Start1P, input.Start2P, input.Tilt} {
+		controls = controls.With(action, state.Pressed(action))
+	}
+	if player < 0 {
+		return controls
+	}
+	for i := range ships[player] {
+		pressed := state.Pressed(ships[0][i]) || state.Pressed(ships[1][i])
+		controls = controls.With(ships[player][i], pressed)
+	}
+	return controls
+}
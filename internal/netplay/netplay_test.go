@@ -0,0 +1,251 @@
+package netplay
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+// sequence is a source that gives a list of controls, one per poll, then holds the last.
+type sequence struct {
+	states []input.State
+	polls  int
+}
+
+func (s *sequence) Poll() input.State {
+	state := s.states[min(s.polls, len(s.states)-1)]
+	s.polls++
+	return state
+}
+
+// scriptStates runs an input script for a number of frames.
+func scriptStates(t *testing.T, script string, frames int) []input.State {
+	t.Helper()
+	source, err := input.ParseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := make([]input.State, frames)
+	for i := range states {
+		states[i] = source.Poll()
+	}
+	return states
+}
+
+// testSettings play on a cocktail cabinet, so each player only steers their own ship.
+var testSettings = movie.Settings{Ships: 3, Cabinet: "Cocktail"}
+
+type machine struct {
+	vm       *emulator.CPU8080
+	hardware *invaders.SpaceInvadersHardware
+	session  *Session
+}
+
+// startSessions connects a host and a guest over a pipe.
+func startSessions(t *testing.T, hostLocal, guestLocal input.Source, hostDelay, guestDelay int) (host, guest machine) {
+	t.Helper()
+	hostConn, guestConn := net.Pipe()
+	host.vm, host.hardware = replay.NewMachine()
+	guest.vm, guest.hardware = replay.NewMachine()
+
+	errs := make(chan error, 1)
+	go func() {
+		var err error
+		guest.session, err = NewSession(guestConn, 1, guest.vm, guest.hardware, guestLocal, Config{InputDelay: guestDelay})
+		errs <- err
+	}()
+	var err error
+	host.session, err = NewSession(hostConn, 0, host.vm, host.hardware, hostLocal, Config{InputDelay: hostDelay, Settings: testSettings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		host.session.Close()
+		guest.session.Close()
+	})
+	return host, guest
+}
+
+// runSessions runs both sides until they have each emulated and confirmed the frames. Each side
+// takes a burst of steps in turn, which plays like a connection with that many frames of lag.
+// The side going first swaps every round, so both get ahead.
+func runSessions(t *testing.T, frames, burst int, machines ...machine) error {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for {
+		done := true
+		slices.Reverse(machines)
+		for _, m := range machines {
+			for i := 0; i < burst; i++ {
+				if err := m.session.step(m.session.Frame() < frames); err != nil {
+					return err
+				}
+			}
+			done = done && m.session.Confirmed() >= frames
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		// Give the messages a chance to get across
+		time.Sleep(time.Millisecond)
+	}
+}
+
+const (
+	// hostScript inserts two coins, starts a two player game, and plays player 1's turn
+	hostScript = `
+30 coin
+35
+60 coin
+65
+100 start2
+105
+200 p1fire
+203
+230 p1right
+260 p1right p1fire
+263
+`
+	// guestScript is played with the guest's player 1 keys, which steer player 2's ship
+	guestScript = `
+150 p1left
+180
+210 p1fire
+213
+`
+)
+
+func TestSessionsMatch(t *testing.T) {
+	const frames = 300
+	hostStates := scriptStates(t, hostScript, frames)
+	guestStates := scriptStates(t, guestScript, frames)
+
+	// With a few frames of lag and no more than that of input delay, both sides have to
+	// guess each other's controls
+	const hostDelay, guestDelay = 0, 2
+	host, guest := startSessions(t, &sequence{states: hostStates}, &sequence{states: guestStates}, hostDelay, guestDelay)
+	if err := runSessions(t, frames, 4, host, guest); err != nil {
+		t.Fatal(err)
+	}
+	if host.session.Frame() != frames || guest.session.Frame() != frames {
+		t.Fatalf("expected %d frames, got %d and %d", frames, host.session.Frame(), guest.session.Frame())
+	}
+	if host.session.Rollbacks == 0 || guest.session.Rollbacks == 0 {
+		t.Errorf("expected both sides to roll back, got %d and %d", host.session.Rollbacks, guest.session.Rollbacks)
+	}
+
+	// Both sides end up where one machine does with everyone's controls on time
+	vm, hardware := replay.NewMachine()
+	if err := replay.ApplySettings(vm, hardware, testSettings); err != nil {
+		t.Fatal(err)
+	}
+	controls := make([]input.State, frames)
+	for f := range controls {
+		if f >= hostDelay {
+			controls[f] |= playerControls(hostStates[f-hostDelay], 0)
+		}
+		if f >= guestDelay {
+			controls[f] |= playerControls(guestStates[f-guestDelay], 1)
+		}
+	}
+	hardware.Input = &sequence{states: controls}
+	for f := 0; f < frames; f++ {
+		vm.Update()
+	}
+
+	if !bytes.Equal(host.hardware.RAM(), hardware.RAM()) {
+		t.Error("expected the host's RAM to match")
+	}
+	if !bytes.Equal(guest.hardware.RAM(), hardware.RAM()) {
+		t.Error("expected the guest's RAM to match")
+	}
+	if guest.hardware.CabinetType != invaders.Cocktail {
+		t.Error("expected the guest to play with the host's settings")
+	}
+}
+
+func TestSessionsDesync(t *testing.T) {
+	idle := []input.State{0}
+	host, guest := startSessions(t, &sequence{states: idle}, &sequence{states: idle}, 1, 1)
+	if err := runSessions(t, 30, 1, host, guest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Something only one side sees: a stray pixel in the corner of the screen
+	guest.hardware.RAM()[0x1FFF] ^= 0xFF
+	err := runSessions(t, 2*SyncInterval, 1, host, guest)
+	var desync *movie.DesyncError
+	if !errors.As(err, &desync) {
+		t.Fatalf("expected a desync, got %v", err)
+	}
+	if desync.Frame != SyncInterval {
+		t.Errorf("expected the desync at frame %d, got %d", SyncInterval, desync.Frame)
+	}
+}
+
+func TestSessionNeedsPowerOn(t *testing.T) {
+	conn, _ := net.Pipe()
+	vm, hardware := replay.NewMachine()
+	vm.Update()
+	if _, err := NewSession(conn, 0, vm, hardware, &sequence{states: []input.State{0}}, Config{Settings: testSettings}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestSessionsNeedDifferentPlayers(t *testing.T) {
+	hostConn, guestConn := net.Pipe()
+	idle := &sequence{states: []input.State{0}}
+	errs := make(chan error, 1)
+	go func() {
+		vm, hardware := replay.NewMachine()
+		_, err := NewSession(guestConn, 0, vm, hardware, idle, Config{Settings: testSettings})
+		errs <- err
+	}()
+	vm, hardware := replay.NewMachine()
+	if _, err := NewSession(hostConn, 0, vm, hardware, idle, Config{Settings: testSettings}); err == nil {
+		t.Error("expected an error")
+	}
+	if err := <-errs; err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestPlayerControls(t *testing.T) {
+	fire := input.State(0).With(input.P1Fire, true)
+	tests := []struct {
+		name     string
+		state    input.State
+		player   int
+		expected input.State
+	}{
+		{name: "Player 1 keeps their controls", state: fire, player: 0, expected: fire},
+		{name: "Player 2 uses player 1 keys", state: fire, player: 1, expected: input.State(0).With(input.P2Fire, true)},
+		{name: "Player 1 uses player 2 keys", state: input.State(0).With(input.P2Left, true), player: 0, expected: input.State(0).With(input.P1Left, true)},
+		{name: "Shared controls kept", state: input.State(0).With(input.Coin, true).With(input.Start2P, true), player: 1, expected: input.State(0).With(input.Coin, true).With(input.Start2P, true)},
+		{name: "Only shared controls", state: fire.With(input.Coin, true), player: -1, expected: input.State(0).With(input.Coin, true)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := playerControls(tt.state, tt.player); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package netplay
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/movie"
+)
+
+// Each message starts with a byte saying what it is, followed by its fields in little endian.
+const (
+	// helloMessage is a 16-bit length and a JSON hello, the first message each side sends
+	helloMessage byte = iota + 1
+	// inputMessage is a 32-bit frame and the sender's 16-bit controls for that frame
+	inputMessage
+	// syncMessage is a 32-bit frame count and the 32-bit checksum of the RAM after it
+	syncMessage
+)
+
+// hello introduces each side of a session to the other.
+type hello struct {
+	Version int    `json:"version"`
+	ROMHash string `json:"rom_sha1"`
+	// Player is 0 for the host, who plays player 1, and 1 for the guest
+	Player int `json:"player"`
+	// Settings are the settings the host plays with. The guest's are ignored.
+	Settings   movie.Settings `json:"settings"`
+	InputDelay int            `json:"input_delay"`
+}
+
+// message is a message received from the peer. Only the fields of its kind are set.
+type message struct {
+	kind     byte
+	hello    hello
+	frame    int
+	state    input.State
+	checksum uint32
+	// err is why the connection stopped, for the last message
+	err error
+}
+
+func writeHello(w io.Writer, h hello) error {
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 3, 3+len(data))
+	buf[0] = helloMessage
+	binary.LittleEndian.PutUint16(buf[1:], uint16(len(data)))
+	_, err = w.Write(append(buf, data...))
+	return err
+}
+
+func writeInput(w io.Writer, frame int, state input.State) error {
+	var buf [7]byte
+	buf[0] = inputMessage
+	binary.LittleEndian.PutUint32(buf[1:], uint32(frame))
+	binary.LittleEndian.PutUint16(buf[5:], uint16(state))
+	_, err := w.Write(buf[:])
+	return err
+}
+
+func writeSync(w io.Writer, frame int, checksum uint32) error {
+	var buf [9]byte
+	buf[0] = syncMessage
+	binary.LittleEndian.PutUint32(buf[1:], uint32(frame))
+	binary.LittleEndian.PutUint32(buf[5:], checksum)
+	_, err := w.Write(buf[:])
+	return err
+}
+
+// readMessage reads the next message from the peer.
+func readMessage(r io.Reader) (message, error) {
+	var kind [1]byte
+	if _, err := io.ReadFull(r, kind[:]); err != nil {
+		return message{}, err
+	}
+
+	m := message{kind: kind[0]}
+	switch m.kind {
+	case helloMessage:
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return m, err
+		}
+		data := make([]byte, binary.LittleEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return m, err
+		}
+		if err := json.Unmarshal(data, &m.hello); err != nil {
+			return m, fmt.Errorf("invalid hello: %w", err)
+		}
+	case inputMessage:
+		var buf [6]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return m, err
+		}
+		m.frame = int(binary.LittleEndian.Uint32(buf[:]))
+		m.state = input.State(binary.LittleEndian.Uint16(buf[4:]))
+	case syncMessage:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return m, err
+		}
+		m.frame = int(binary.LittleEndian.Uint32(buf[:]))
+		m.checksum = binary.LittleEndian.Uint32(buf[4:])
+	default:
+		return m, fmt.Errorf("unknown message type %d", m.kind)
+	}
+	return m, nil
+}
@@ -0,0 +1,198 @@
+package patch
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// A BPS patch is "BPS1", then the sizes of the ROM it's for and the ROM it makes, and some
+// metadata, then actions that each make the next part of the new ROM. It ends with the CRC32s
+// of the ROM it's for, the ROM it makes and the rest of the patch, little endian. Numbers are
+// variable length, 7 bits a byte, with the top bit set on the last.
+const bpsHeader = "BPS1"
+
+// bpsFooterSize is the size of the checksums at the end of a BPS patch.
+const bpsFooterSize = 12
+
+// maxTargetSize is the biggest ROM a BPS patch may make. The size is read before anything
+// else, so a damaged patch could otherwise ask for gigabytes. IPS offsets are 3 bytes, so
+// IPS patches can't make ROMs much bigger than this either.
+const maxTargetSize = 16 << 20
+
+// The actions in a BPS patch. Each is a number, with the action in the bottom two bits and
+// how many bytes it makes, less one, in the rest.
+const (
+	// sourceRead copies from the same place in the ROM being patched
+	sourceRead = iota
+	// targetRead copies bytes from the patch
+	targetRead
+	// sourceCopy copies from somewhere else in the ROM being patched, moving there first
+	sourceCopy
+	// targetCopy copies from earlier in the new ROM, moving there first
+	targetCopy
+)
+
+// ChecksumError is returned when a BPS patch's checksums don't match, because it's for another
+// ROM or it's been damaged.
+type ChecksumError struct {
+	// What is what has the wrong checksum: the source ROM, the target ROM or the patch
+	What     string
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("BPS patch expects the %s to have CRC32 %08x, but it has %08x", e.What, e.Expected, e.Actual)
+}
+
+// errBPSTruncated is returned for a BPS patch that ends partway through.
+var errBPSTruncated = errors.New("BPS patch is truncated")
+
+// bpsReader reads the numbers and bytes of a BPS patch, up to its footer.
+type bpsReader struct {
+	patch []byte
+	p     int
+}
+
+func (r *bpsReader) byte() (byte, error) {
+	if r.p >= len(r.patch)-bpsFooterSize {
+		return 0, errBPSTruncated
+	}
+	b := r.patch[r.p]
+	r.p++
+	return b, nil
+}
+
+func (r *bpsReader) number() (int, error) {
+	n, shift := 0, 1
+	for {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		n += int(b&0x7F) * shift
+		if b&0x80 != 0 {
+			return n, nil
+		}
+		shift <<= 7
+		n += shift
+		// No ROM is anywhere near this big, so the patch is damaged
+		if shift > 1<<28 {
+			return 0, errors.New("BPS patch has a number that's too big")
+		}
+	}
+}
+
+// offset reads a relative offset, with its sign in the bottom bit.
+func (r *bpsReader) offset() (int, error) {
+	n, err := r.number()
+	if n&1 != 0 {
+		return -(n >> 1), err
+	}
+	return n >> 1, err
+}
+
+// ApplyBPS applies a BPS patch to a ROM, checking the ROM is the one it's for, the patch is
+// whole, and the ROM it makes is the one it should.
+func ApplyBPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsHeader)+bpsFooterSize || string(patch[:len(bpsHeader)]) != bpsHeader {
+		return nil, errors.New("not a BPS patch")
+	}
+	footer := patch[len(patch)-bpsFooterSize:]
+	sourceCRC := binary.LittleEndian.Uint32(footer[0:])
+	targetCRC := binary.LittleEndian.Uint32(footer[4:])
+	patchCRC := binary.LittleEndian.Uint32(footer[8:])
+	if actual := crc32.ChecksumIEEE(patch[:len(patch)-4]); actual != patchCRC {
+		return nil, &ChecksumError{What: "patch", Expected: patchCRC, Actual: actual}
+	}
+	if actual := crc32.ChecksumIEEE(rom); actual != sourceCRC {
+		return nil, &ChecksumError{What: "source ROM", Expected: sourceCRC, Actual: actual}
+	}
+
+	r := &bpsReader{patch: patch, p: len(bpsHeader)}
+	sourceSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != len(rom) {
+		return nil, fmt.Errorf("BPS patch is for a ROM of %d bytes, but it's %d", sourceSize, len(rom))
+	}
+	targetSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if targetSize > maxTargetSize {
+		return nil, fmt.Errorf("BPS patch makes a ROM of %d bytes, more than the %d it may", targetSize, maxTargetSize)
+	}
+	metadataSize, err := r.number()
+	if err != nil {
+		return nil, err
+	}
+	if metadataSize > len(patch)-bpsFooterSize-r.p {
+		return nil, errBPSTruncated
+	}
+	r.p += metadataSize
+
+	target := make([]byte, targetSize)
+	out, sourceOffset, targetOffset := 0, 0, 0
+	for r.p < len(patch)-bpsFooterSize {
+		n, err := r.number()
+		if err != nil {
+			return nil, err
+		}
+		action, length := n&3, n>>2+1
+		if out+length > targetSize {
+			return nil, fmt.Errorf("BPS patch writes past the end of the %d byte ROM it makes", targetSize)
+		}
+
+		switch action {
+		case sourceRead:
+			if out+length > len(rom) {
+				return nil, errors.New("BPS patch reads past the end of the ROM")
+			}
+			copy(target[out:], rom[out:out+length])
+		case targetRead:
+			if r.p+length > len(patch)-bpsFooterSize {
+				return nil, errBPSTruncated
+			}
+			copy(target[out:], patch[r.p:r.p+length])
+			r.p += length
+		case sourceCopy:
+			delta, err := r.offset()
+			if err != nil {
+				return nil, err
+			}
+			sourceOffset += delta
+			if sourceOffset < 0 || sourceOffset+length > len(rom) {
+				return nil, errors.New("BPS patch copies from outside the ROM")
+			}
+			copy(target[out:], rom[sourceOffset:sourceOffset+length])
+			sourceOffset += length
+		case targetCopy:
+			delta, err := r.offset()
+			if err != nil {
+				return nil, err
+			}
+			targetOffset += delta
+			if targetOffset < 0 || targetOffset >= out {
+				return nil, errors.New("BPS patch copies from a part of the ROM it hasn't made yet")
+			}
+			// The copy can overlap what it's making, repeating it, so it goes a byte at a time
+			for i := 0; i < length; i++ {
+				target[out+i] = target[targetOffset]
+				targetOffset++
+			}
+		}
+		out += length
+	}
+
+	if out != targetSize {
+		return nil, fmt.Errorf("BPS patch makes %d bytes of the %d byte ROM", out, targetSize)
+	}
+	if actual := crc32.ChecksumIEEE(target); actual != targetCRC {
+		return nil, &ChecksumError{What: "target ROM", Expected: targetCRC, Actual: actual}
+	}
+	return target, nil
+}
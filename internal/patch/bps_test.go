@@ -0,0 +1,117 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// bpsNumber encodes a number the way BPS patches do.
+func bpsNumber(n int) []byte {
+	var encoded []byte
+	for {
+		b := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(encoded, b|0x80)
+		}
+		encoded = append(encoded, b)
+		n--
+	}
+}
+
+// bpsAction encodes an action making length bytes.
+func bpsAction(action, length int) []byte {
+	return bpsNumber((length-1)<<2 | action)
+}
+
+// bps builds a BPS patch from its actions, with the checksums of source and target.
+func bps(source, target []byte, actions ...[]byte) []byte {
+	patch := []byte(bpsHeader)
+	patch = append(patch, bpsNumber(len(source))...)
+	patch = append(patch, bpsNumber(len(target))...)
+	patch = append(patch, bpsNumber(4)...)
+	patch = append(patch, "meta"...)
+	for _, action := range actions {
+		patch = append(patch, action...)
+	}
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+func TestApplyBPS(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+	// Keep the first two bytes, write two, repeat them, and copy the last two from the ROM
+	target := []byte{0, 1, 0xAA, 0xBB, 0xAA, 0xBB, 0xAA, 6, 7}
+	actions := [][]byte{
+		bpsAction(sourceRead, 2),
+		append(bpsAction(targetRead, 2), 0xAA, 0xBB),
+		append(bpsAction(targetCopy, 3), bpsNumber(2<<1)...),
+		append(bpsAction(sourceCopy, 2), bpsNumber(6<<1)...),
+	}
+
+	damaged := bps(rom, target, actions...)
+	damaged[len(bpsHeader)+4] ^= 0xFF
+
+	tests := []struct {
+		name     string
+		rom      []byte
+		patch    []byte
+		expected []byte
+		checksum string
+		err      bool
+	}{
+		{name: "Patched", rom: rom, patch: bps(rom, target, actions...), expected: target},
+		{
+			name:     "Copy backwards",
+			rom:      rom,
+			patch:    bps(rom, []byte{7, 6}, append(bpsAction(sourceCopy, 1), bpsNumber(7<<1)...), append(bpsAction(sourceCopy, 1), bpsNumber(2<<1|1)...)),
+			expected: []byte{7, 6},
+		},
+		{name: "Another ROM", rom: []byte{9, 9, 9, 9, 9, 9, 9, 9}, patch: bps(rom, target, actions...), checksum: "source ROM"},
+		{name: "Damaged", rom: rom, patch: damaged, checksum: "patch"},
+		{name: "Wrong target", rom: rom, patch: bps(rom, []byte{0, 1}, bpsAction(targetRead, 2), []byte{0, 2}), checksum: "target ROM"},
+		{name: "Short", rom: rom, patch: bps(rom, target, actions[:2]...), err: true},
+		{name: "Past the end", rom: rom, patch: bps(rom, []byte{0}, bpsAction(sourceRead, 2)), err: true},
+		{name: "Copy outside the ROM", rom: rom, patch: bps(rom, []byte{0}, append(bpsAction(sourceCopy, 1), bpsNumber(1<<1|1)...)), err: true},
+		{name: "Too big", rom: rom, patch: bps(rom, make([]byte, maxTargetSize+1)), err: true},
+		{name: "Copy ahead", rom: rom, patch: bps(rom, []byte{0}, append(bpsAction(targetCopy, 1), bpsNumber(0)...)), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, err := Apply(tt.rom, tt.patch)
+			if tt.checksum != "" {
+				var checksumErr *ChecksumError
+				if !errors.As(err, &checksumErr) || checksumErr.What != tt.checksum {
+					t.Errorf("expected the %s's checksum to be wrong, got %v", tt.checksum, err)
+				}
+				return
+			}
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got % x", patched)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(patched, tt.expected) {
+				t.Errorf("expected % x, got % x", tt.expected, patched)
+			}
+		})
+	}
+}
+
+func TestBPSNumber(t *testing.T) {
+	for _, n := range []int{0, 1, 127, 128, 129, 16511, 16512, 0x2000, 1 << 20} {
+		r := &bpsReader{patch: append(bpsNumber(n), make([]byte, bpsFooterSize)...)}
+		if got, err := r.number(); err != nil || got != n {
+			t.Errorf("expected %d, got %d (%v)", n, got, err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// An IPS patch is "PATCH", then records that each write some bytes at an offset, then "EOF".
+// A record is a 3-byte offset and a 2-byte size, both big endian, and that many bytes. A size
+// of 0 is a run instead, of a 2-byte length and the byte to repeat. Some patches end with a
+// 3-byte size to cut the ROM to, after "EOF".
+const (
+	ipsHeader = "PATCH"
+	// ipsEOF marks the end of the records. It's where an offset would be, so no record can
+	// write at 0x454F46.
+	ipsEOF = 0x454F46
+)
+
+// errIPSTruncated is returned for an IPS patch that ends partway through.
+var errIPSTruncated = errors.New("IPS patch is truncated")
+
+// ApplyIPS applies an IPS patch to a ROM, growing it if the patch writes past the end.
+func ApplyIPS(rom, patch []byte) ([]byte, error) {
+	if len(patch) < len(ipsHeader) || string(patch[:len(ipsHeader)]) != ipsHeader {
+		return nil, errors.New("not an IPS patch")
+	}
+	target := append([]byte(nil), rom...)
+	// write writes bytes at an offset, growing the ROM to fit
+	write := func(offset int, data []byte) {
+		if end := offset + len(data); end > len(target) {
+			target = append(target, make([]byte, end-len(target))...)
+		}
+		copy(target[offset:], data)
+	}
+
+	p := len(ipsHeader)
+	for {
+		if p+3 > len(patch) {
+			return nil, errIPSTruncated
+		}
+		offset := int(patch[p])<<16 | int(patch[p+1])<<8 | int(patch[p+2])
+		p += 3
+		if offset == ipsEOF {
+			break
+		}
+
+		if p+2 > len(patch) {
+			return nil, errIPSTruncated
+		}
+		size := int(patch[p])<<8 | int(patch[p+1])
+		p += 2
+		if size > 0 {
+			if p+size > len(patch) {
+				return nil, errIPSTruncated
+			}
+			write(offset, patch[p:p+size])
+			p += size
+			continue
+		}
+
+		// A run of one byte
+		if p+3 > len(patch) {
+			return nil, errIPSTruncated
+		}
+		length := int(patch[p])<<8 | int(patch[p+1])
+		run := make([]byte, length)
+		for i := range run {
+			run[i] = patch[p+2]
+		}
+		write(offset, run)
+		p += 3
+	}
+
+	switch len(patch) - p {
+	case 0:
+	case 3:
+		size := int(patch[p])<<16 | int(patch[p+1])<<8 | int(patch[p+2])
+		if size > len(target) {
+			return nil, fmt.Errorf("IPS patch cuts the ROM to %d bytes, but it's only %d", size, len(target))
+		}
+		target = target[:size]
+	default:
+		return nil, fmt.Errorf("IPS patch has %d unexpected bytes after the end", len(patch)-p)
+	}
+	return target, nil
+}
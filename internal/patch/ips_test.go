@@ -0,0 +1,80 @@
+package patch
+
+import (
+	"bytes"
+	"testing"
+)
+
+// ips builds an IPS patch from its records, without the header and "EOF".
+func ips(records ...[]byte) []byte {
+	patch := []byte(ipsHeader)
+	for _, record := range records {
+		patch = append(patch, record...)
+	}
+	return append(patch, "EOF"...)
+}
+
+func TestApplyIPS(t *testing.T) {
+	rom := []byte{0, 1, 2, 3, 4, 5, 6, 7}
+
+	tests := []struct {
+		name     string
+		patch    []byte
+		expected []byte
+		err      bool
+	}{
+		{name: "No records", patch: ips(), expected: rom},
+		{
+			name:     "Record",
+			patch:    ips([]byte{0, 0, 2, 0, 2, 0xAA, 0xBB}),
+			expected: []byte{0, 1, 0xAA, 0xBB, 4, 5, 6, 7},
+		},
+		{
+			name:     "Run",
+			patch:    ips([]byte{0, 0, 5, 0, 0, 0, 3, 0xFF}),
+			expected: []byte{0, 1, 2, 3, 4, 0xFF, 0xFF, 0xFF},
+		},
+		{
+			name:     "Past the end",
+			patch:    ips([]byte{0, 0, 9, 0, 1, 0xCC}),
+			expected: []byte{0, 1, 2, 3, 4, 5, 6, 7, 0, 0xCC},
+		},
+		{
+			name:     "Cut",
+			patch:    append(ips([]byte{0, 0, 0, 0, 1, 0xDD}), 0, 0, 4),
+			expected: []byte{0xDD, 1, 2, 3},
+		},
+		{name: "Truncated record", patch: []byte("PATCH\x00\x00\x02\x00\x04\xAA"), err: true},
+		{name: "No EOF", patch: []byte("PATCH\x00\x00\x02\x00\x01\xAA"), err: true},
+		{name: "Cut past the end", patch: append(ips(), 0, 0, 9), err: true},
+		{name: "Trailing bytes", patch: append(ips(), 0), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched, err := Apply(rom, tt.patch)
+			if tt.err {
+				if err == nil {
+					t.Errorf("expected an error, got % x", patched)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(patched, tt.expected) {
+				t.Errorf("expected % x, got % x", tt.expected, patched)
+			}
+		})
+	}
+
+	if !bytes.Equal(rom, []byte{0, 1, 2, 3, 4, 5, 6, 7}) {
+		t.Errorf("expected the ROM left as it was, got % x", rom)
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := Apply([]byte{0}, []byte("UPS1")); err != ErrUnknownFormat {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+}
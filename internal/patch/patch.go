@@ -0,0 +1,35 @@
+// Package patch applies IPS and BPS patches to ROM images, the formats hacks and fixes for old
+// games are usually shared in. IPS patches are a list of bytes to write, with nothing to check
+// them against, while BPS patches carry checksums of the ROM they're for, the ROM they make and
+// the patch itself, so a patch for another ROM or a damaged one is turned away.
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// ErrUnknownFormat is returned for a patch that's neither IPS nor BPS.
+var ErrUnknownFormat = errors.New("unknown patch format, expected IPS or BPS")
+
+// Apply applies a patch to a ROM, working out its format from its header. The ROM is left as
+// it is, and the patched copy returned.
+func Apply(rom, patch []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(patch, []byte(ipsHeader)):
+		return ApplyIPS(rom, patch)
+	case bytes.HasPrefix(patch, []byte(bpsHeader)):
+		return ApplyBPS(rom, patch)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Load reads a patch file and applies it to a ROM.
+func Load(rom []byte, path string) ([]byte, error) {
+	patch, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Apply(rom, patch)
+}
@@ -0,0 +1,154 @@
+// Package replay plays movies back without a window, as fast as possible, to check them.
+package replay
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+)
+
+// NewMachine creates a headless machine at power on, with interrupts timed by the CPU so it
+// plays the same every time.
+func NewMachine() (*emulator.CPU8080, *invaders.SpaceInvadersHardware) {
+	vm, hardware, _ := NewPatchedMachine(nil)
+	return vm, hardware
+}
+
+// NewPatchedMachine creates a headless machine like NewMachine, with IPS or BPS patches applied
+// to its ROM in order before it's loaded into memory.
+func NewPatchedMachine(patches []string) (*emulator.CPU8080, *invaders.SpaceInvadersHardware, error) {
+	hardware := invaders.NewHeadlessHardware()
+	for _, path := range patches {
+		if err := hardware.LoadPatch(path); err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	vm := emulator.NewEmulator(hardware)
+	vm.ScheduleInterrupts()
+	return vm, hardware, nil
+}
+
+// CurrentSettings returns a machine's settings, as stored in movies.
+func CurrentSettings(vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) movie.Settings {
+	return movie.Settings{
+		Ships:           hardware.ShipsSetting,
+		ExtraShipAt1000: hardware.ExtraShipAt1000,
+		// The hardware field is the raw DIP switch, which hides the coin info when set
+		HideCoinInfo:  hardware.ShowCoinInfoOnDemo,
+		Cabinet:       invaders.CabinetTypeNames[hardware.CabinetType],
+		FreePlay:      hardware.FreePlay,
+		PracticeWave:  hardware.Practice.Wave,
+		PracticeLives: hardware.Practice.Lives,
+		PracticeScore: hardware.Practice.Score,
+		LimitTPS:      vm.Options.LimitTPS,
+	}
+}
+
+// ApplySettings sets a machine up the way a movie was recorded.
+func ApplySettings(vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware, settings movie.Settings) error {
+	cabinet := slices.Index(invaders.CabinetTypeNames, settings.Cabinet)
+	if cabinet < 0 {
+		return fmt.Errorf("unknown cabinet %q", settings.Cabinet)
+	}
+	if settings.Ships < 3 || settings.Ships > 6 {
+		return fmt.Errorf("invalid ship count %d", settings.Ships)
+	}
+	if settings.PracticeWave < 0 || settings.PracticeWave > gamestate.MaxWave {
+		return fmt.Errorf("invalid practice wave %d", settings.PracticeWave)
+	}
+	if settings.PracticeScore < 0 || settings.PracticeScore > gamestate.MaxScore {
+		return fmt.Errorf("invalid practice score %d", settings.PracticeScore)
+	}
+
+	hardware.ShipsSetting = settings.Ships
+	hardware.ExtraShipAt1000 = settings.ExtraShipAt1000
+	hardware.ShowCoinInfoOnDemo = settings.HideCoinInfo
+	hardware.CabinetType = invaders.CabinetType(cabinet)
+	hardware.FreePlay = settings.FreePlay
+	hardware.Practice = invaders.Practice{Wave: settings.PracticeWave, Lives: settings.PracticeLives, Score: settings.PracticeScore}
+	vm.Options.LimitTPS = settings.LimitTPS
+	return nil
+}
+
+// SameGame reports whether two sets of settings play the same game. Limiting the frame rate
+// only changes how fast the game runs, and free play how it's paid for, so they are ignored.
+func SameGame(a, b movie.Settings) bool {
+	a.LimitTPS, b.LimitTPS = false, false
+	a.FreePlay, b.FreePlay = false, false
+	return a == b
+}
+
+// Result is where a movie played to.
+type Result struct {
+	// Frames is the number of frames played
+	Frames int
+	// Scores are the players' scores at the end
+	Scores [2]int
+	// HighScore is the high score at the end
+	HighScore int
+	// WavesCleared is the number of waves of invaders cleared, by both players, in every game
+	WavesCleared int
+}
+
+// BestScore returns the higher of the players' scores.
+func (r Result) BestScore() int {
+	return max(r.Scores[0], r.Scores[1])
+}
+
+// Play plays a movie from power on, with the patches it was recorded with applied to the ROM,
+// stopping at its end or when it desyncs. A desync is returned as a *movie.DesyncError, along
+// with the result up to that point.
+func Play(m *movie.Movie, patches []string) (Result, error) {
+	vm, hardware, err := NewPatchedMachine(patches)
+	if err != nil {
+		return Result{}, err
+	}
+	if romHash := movie.HashROM(hardware.ROM()); m.Header.ROMHash != romHash {
+		return Result{}, fmt.Errorf("movie was recorded with a different ROM (SHA-1 %s, this is %s)", m.Header.ROMHash, romHash)
+	}
+	if err := ApplySettings(vm, hardware, m.Header.Settings); err != nil {
+		return Result{}, err
+	}
+	// Run as fast as possible, whatever the movie was recorded at
+	vm.Options.LimitTPS = false
+
+	player := movie.NewPlayer(m)
+	hardware.Input = player
+	waves := &waveCounter{}
+	for !player.Done() {
+		vm.Update()
+		waves.update(hardware.RAM())
+		if err = player.EndFrame(hardware.RAM()); err != nil {
+			break
+		}
+	}
+
+	ram := hardware.RAM()
+	return Result{
+		Frames:       player.Frames(),
+		Scores:       gamestate.Scores(ram),
+		HighScore:    gamestate.Score(ram, gamestate.HighScore),
+		WavesCleared: waves.cleared,
+	}, err
+}
+
+// waveCounter counts waves cleared by watching each player's rack counter move on.
+type waveCounter struct {
+	racks   [2]byte
+	cleared int
+}
+
+func (w *waveCounter) update(ram []byte) {
+	for player, address := range []int{gamestate.Player1Rack, gamestate.Player2Rack} {
+		rack := ram[address]
+		// The counter moves on by one when a wave is cleared, and back to 0 for a new game
+		if gamestate.Playing(ram) && rack != w.racks[player] && rack == w.racks[player]&0x07+1 {
+			w.cleared++
+		}
+		w.racks[player] = rack
+	}
+}
@@ -0,0 +1,165 @@
+package replay
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/movie"
+)
+
+// recordMovie plays a script on a headless machine with the patches applied, recording it as a
+// movie.
+func recordMovie(t *testing.T, script string, frames int, patches ...string) *movie.Movie {
+	t.Helper()
+	source, err := input.ParseScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vm, hardware, err := NewPatchedMachine(patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hardware.ShipsSetting = 3
+	header := movie.Header{ROMHash: movie.HashROM(hardware.ROM()), Settings: CurrentSettings(vm, hardware)}
+	var buf bytes.Buffer
+	recorder, err := movie.NewRecorder(&buf, header, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hardware.Input = recorder
+	for i := 0; i < frames; i++ {
+		vm.Update()
+		if err := recorder.EndFrame(hardware.RAM()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := movie.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// startGame inserts a coin, starts a one player game and fires a few shots.
+const startGame = `
+60 coin
+65
+120 start1
+125
+200 p1fire
+203
+240 p1left p1fire
+243 p1left
+280
+`
+
+func TestPlay(t *testing.T) {
+	m := recordMovie(t, startGame, 300)
+
+	result, err := Play(m, nil)
+	if err != nil {
+		t.Fatalf("expected the movie to play back in sync, got %v", err)
+	}
+	if result.Frames != 300 {
+		t.Errorf("expected 300 frames, got %d", result.Frames)
+	}
+}
+
+func TestPlayDesync(t *testing.T) {
+	m := recordMovie(t, startGame, 300)
+	// Without the coin, the game never starts
+	for frame := 60; frame < 65; frame++ {
+		m.Inputs[frame] = 0
+	}
+
+	_, err := Play(m, nil)
+	var desync *movie.DesyncError
+	if !errors.As(err, &desync) {
+		t.Fatalf("expected a desync, got %v", err)
+	}
+	if desync.Frame <= 60 {
+		t.Errorf("expected the desync after the missing coin, got frame %d", desync.Frame)
+	}
+}
+
+func TestPlayWrongROM(t *testing.T) {
+	m := &movie.Movie{Header: movie.Header{ROMHash: "0000"}}
+	if _, err := Play(m, nil); err == nil {
+		t.Error("expected an error for a different ROM")
+	}
+}
+
+func TestPlayPatched(t *testing.T) {
+	// Writes 0x00 over the 3 bytes at 0x060F, the invincibility cheat
+	path := filepath.Join(t.TempDir(), "patch.ips")
+	if err := os.WriteFile(path, []byte("PATCH\x00\x06\x0F\x00\x00\x00\x03\x00EOF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := recordMovie(t, startGame, 300, path)
+
+	if _, err := Play(m, []string{path}); err != nil {
+		t.Fatalf("expected the movie to play back with its patch, got %v", err)
+	}
+	if _, err := Play(m, nil); err == nil {
+		t.Error("expected an error for the stock ROM")
+	}
+	if _, err := Play(m, []string{filepath.Join(t.TempDir(), "missing.ips")}); err == nil {
+		t.Error("expected an error for a missing patch")
+	}
+}
+
+func TestWaveCounter(t *testing.T) {
+	ram := make([]byte, 0x2000)
+	w := &waveCounter{}
+	ram[gamestate.GameMode] = 1
+
+	// Player 1 clears two waves, player 2 one
+	for _, racks := range [][2]byte{{0, 0}, {1, 0}, {1, 0}, {2, 0}, {2, 1}} {
+		ram[gamestate.Player1Rack], ram[gamestate.Player2Rack] = racks[0], racks[1]
+		w.update(ram)
+	}
+	// The counter wraps after 8 waves
+	w.racks[0] = 8
+	ram[gamestate.Player1Rack] = 1
+	w.update(ram)
+	// A new game resets the counters without clearing a wave
+	ram[gamestate.Player1Rack], ram[gamestate.Player2Rack] = 0, 0
+	w.update(ram)
+
+	if w.cleared != 4 {
+		t.Errorf("expected 4 waves cleared, got %d", w.cleared)
+	}
+}
+
+func TestSameGame(t *testing.T) {
+	a := movie.Settings{Ships: 3, Cabinet: "Upright"}
+	b := a
+	b.LimitTPS = true
+	if !SameGame(a, b) {
+		t.Error("expected the frame rate limit to be ignored")
+	}
+	b.FreePlay = true
+	if !SameGame(a, b) {
+		t.Error("expected free play to be ignored")
+	}
+	b.Ships = 5
+	if SameGame(a, b) {
+		t.Error("expected a different ship count to be a different game")
+	}
+	b = a
+	b.PracticeWave, b.PracticeLives = 5, 3
+	if SameGame(a, b) {
+		t.Error("expected a practice game to be a different game")
+	}
+}
@@ -0,0 +1,61 @@
+// Package savestate snapshots a whole machine, so it can be put back exactly the way it was.
+package savestate
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/invaders"
+)
+
+// Version is the version of the save state encoding written.
+const Version = 1
+
+// State is a snapshot of the CPU, memory and hardware of a machine.
+type State struct {
+	Version  int
+	CPU      *emulator.State
+	Hardware invaders.HardwareState
+}
+
+// Save returns a snapshot of a machine. It should be taken between frames.
+func Save(vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) *State {
+	return &State{
+		Version:  Version,
+		CPU:      vm.SaveState(),
+		Hardware: hardware.SaveState(),
+	}
+}
+
+// Load puts a machine back the way it was when the snapshot was taken.
+func (s *State) Load(vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) {
+	vm.LoadState(s.CPU)
+	hardware.LoadState(s.Hardware)
+}
+
+// RAM returns the machine's RAM in the snapshot, from 0x2000 to 0x3FFF.
+func (s *State) RAM() []byte {
+	return s.CPU.Memory[0x2000:0x4000]
+}
+
+// Write encodes a snapshot, to send or store it.
+func Write(w io.Writer, s *State) error {
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// Read decodes a snapshot written by Write.
+func Read(r io.Reader) (*State, error) {
+	var s State
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	if s.Version != Version {
+		return nil, fmt.Errorf("unsupported save state version %d", s.Version)
+	}
+	if s.CPU == nil {
+		return nil, fmt.Errorf("save state has no CPU")
+	}
+	return &s, nil
+}
@@ -0,0 +1,133 @@
+package savestate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+// heldSource holds the same controls every frame.
+type heldSource struct {
+	state input.State
+}
+
+func (s *heldSource) Poll() input.State {
+	return s.state
+}
+
+func TestLoadRepeatsTheFrames(t *testing.T) {
+	vm, hardware := replay.NewMachine()
+	hardware.ShipsSetting = 3
+	source := &heldSource{}
+	hardware.Input = source
+	for i := 0; i < 120; i++ {
+		vm.Update()
+	}
+
+	saved := Save(vm, hardware)
+	// Run on through a coin being inserted, so the state has something to get wrong
+	source.state = input.State(0).With(input.Coin, true)
+	for i := 0; i < 30; i++ {
+		vm.Update()
+	}
+	expected := bytes.Clone(hardware.RAM())
+
+	// Encoding the state and reading it back loses nothing
+	var buf bytes.Buffer
+	if err := Write(&buf, saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A different machine picks up from the state and ends up in the same place
+	other, otherHardware := replay.NewMachine()
+	otherHardware.ShipsSetting = 3
+	otherHardware.Input = source
+	loaded.Load(other, otherHardware)
+	if other.FrameCount() != 120 {
+		t.Errorf("expected 120 frames, got %d", other.FrameCount())
+	}
+	for i := 0; i < 30; i++ {
+		other.Update()
+	}
+	if !bytes.Equal(otherHardware.RAM(), expected) {
+		t.Error("expected the same RAM after running on from the state")
+	}
+
+	// So does the original machine, put back
+	saved.Load(vm, hardware)
+	for i := 0; i < 30; i++ {
+		vm.Update()
+	}
+	if !bytes.Equal(hardware.RAM(), expected) {
+		t.Error("expected the same RAM after loading the state")
+	}
+}
+
+func TestReadRejectsOtherVersions(t *testing.T) {
+	vm, hardware := replay.NewMachine()
+	state := Save(vm, hardware)
+	state.Version = Version + 1
+
+	var buf bytes.Buffer
+	if err := Write(&buf, state); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(&buf); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestLoadCarriesOnAPracticeGame(t *testing.T) {
+	practice := invaders.Practice{Wave: 3, Lives: 3}
+	vm, hardware := replay.NewMachine()
+	hardware.ShipsSetting = 3
+	hardware.Practice = practice
+	frame := 0
+	source := &scriptedSource{controls: func() input.State {
+		switch {
+		case frame >= 100 && frame < 104:
+			return input.State(0).With(input.Coin, true)
+		case frame >= 200 && frame < 204:
+			return input.State(0).With(input.Start1P, true)
+		}
+		return 0
+	}}
+	hardware.Input = source
+	for ; frame < 500; frame++ {
+		vm.Update()
+	}
+
+	// A machine that hasn't seen the game start picks it up from the state, without
+	// starting it again
+	saved := Save(vm, hardware)
+	for i := 0; i < 60; i++ {
+		vm.Update()
+	}
+	other, otherHardware := replay.NewMachine()
+	otherHardware.ShipsSetting = 3
+	otherHardware.Practice = practice
+	otherHardware.Input = source
+	saved.Load(other, otherHardware)
+	for i := 0; i < 60; i++ {
+		other.Update()
+	}
+	if !bytes.Equal(otherHardware.RAM(), hardware.RAM()) {
+		t.Error("expected the same RAM after running on from the state")
+	}
+}
+
+// scriptedSource returns controls from a function each frame.
+type scriptedSource struct {
+	controls func() input.State
+}
+
+func (s *scriptedSource) Poll() input.State {
+	return s.controls()
+}
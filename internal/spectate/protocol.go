@@ -0,0 +1,147 @@
+package spectate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/savestate"
+)
+
+// Each message starts with a byte saying what it is, followed by its fields in little endian.
+const (
+	// helloMessage is a 16-bit length and a JSON hello, the first message sent
+	helloMessage byte = iota + 1
+	// stateMessage is a 32-bit length and a save state, sent after the hello
+	stateMessage
+	// settingsMessage is a 16-bit length and the JSON settings, sent when they change
+	settingsMessage
+	// inputMessage is the 16-bit controls of the next frame
+	inputMessage
+	// checksumMessage is a 32-bit frame count and the 32-bit checksum of the RAM after it
+	checksumMessage
+)
+
+// hello introduces the host to a spectator.
+type hello struct {
+	Version int    `json:"version"`
+	ROMHash string `json:"rom_sha1"`
+}
+
+// message is a message from the host. Only the fields of its kind are set.
+type message struct {
+	kind     byte
+	hello    hello
+	state    *savestate.State
+	settings movie.Settings
+	controls input.State
+	frame    int
+	checksum uint32
+}
+
+// encodeJSON returns a message with a 16-bit length and a value as JSON.
+func encodeJSON(kind byte, v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 3, 3+len(data))
+	buf[0] = kind
+	binary.LittleEndian.PutUint16(buf[1:], uint16(len(data)))
+	return append(buf, data...), nil
+}
+
+// encodeState returns a message with a save state.
+func encodeState(state *savestate.State) ([]byte, error) {
+	var data bytes.Buffer
+	if err := savestate.Write(&data, state); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 5, 5+data.Len())
+	buf[0] = stateMessage
+	binary.LittleEndian.PutUint32(buf[1:], uint32(data.Len()))
+	return append(buf, data.Bytes()...), nil
+}
+
+func encodeInput(controls input.State) []byte {
+	return []byte{inputMessage, byte(controls), byte(controls >> 8)}
+}
+
+func encodeChecksum(frame int, checksum uint32) []byte {
+	buf := make([]byte, 9)
+	buf[0] = checksumMessage
+	binary.LittleEndian.PutUint32(buf[1:], uint32(frame))
+	binary.LittleEndian.PutUint32(buf[5:], checksum)
+	return buf
+}
+
+// maxStateSize is the most a save state is allowed to take up, well over the 64KB of memory.
+const maxStateSize = 1 << 20
+
+// readMessage reads the next message from the host.
+func readMessage(r io.Reader) (message, error) {
+	var kind [1]byte
+	if _, err := io.ReadFull(r, kind[:]); err != nil {
+		return message{}, err
+	}
+
+	m := message{kind: kind[0]}
+	switch m.kind {
+	case helloMessage, settingsMessage:
+		var length [2]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return m, err
+		}
+		data := make([]byte, binary.LittleEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(r, data); err != nil {
+			return m, err
+		}
+		var err error
+		if m.kind == helloMessage {
+			err = json.Unmarshal(data, &m.hello)
+		} else {
+			err = json.Unmarshal(data, &m.settings)
+		}
+		if err != nil {
+			return m, fmt.Errorf("invalid message: %w", err)
+		}
+	case stateMessage:
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return m, err
+		}
+		size := binary.LittleEndian.Uint32(length[:])
+		if size > maxStateSize {
+			return m, fmt.Errorf("save state too big: %d bytes", size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return m, err
+		}
+		state, err := savestate.Read(bytes.NewReader(data))
+		if err != nil {
+			return m, err
+		}
+		m.state = state
+	case inputMessage:
+		var buf [2]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return m, err
+		}
+		m.controls = input.State(binary.LittleEndian.Uint16(buf[:]))
+	case checksumMessage:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return m, err
+		}
+		m.frame = int(binary.LittleEndian.Uint32(buf[:]))
+		m.checksum = binary.LittleEndian.Uint32(buf[4:])
+	default:
+		return m, fmt.Errorf("unknown message type %d", m.kind)
+	}
+	return m, nil
+}
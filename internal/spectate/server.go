@@ -0,0 +1,234 @@
+// Package spectate lets other machines watch a game live over TCP.
+//
+// No video is sent. The host sends each spectator a save state of the machine when they join,
+// then the controls of every frame from there on, and the spectator emulates the game itself.
+// The host also sends a checksum of the RAM every second, so a spectator can tell if what it
+// shows stops matching the real game.
+package spectate
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+	"github.com/braheezy/space-invaders/internal/savestate"
+)
+
+// Version is the version of the spectating protocol.
+const Version = 1
+
+// ChecksumInterval is how many frames apart the RAM checksums are, a second of play.
+const ChecksumInterval = 60
+
+// backlog is how many messages a spectator can fall behind before they're dropped, about a
+// minute of play.
+const backlog = 4096
+
+// Server streams the game to spectators.
+type Server struct {
+	// joining are the spectators waiting for the end of a frame to be sent a save state
+	joining chan net.Conn
+	// spectators are the spectators being streamed to
+	spectators []*spectator
+	// settings are the settings last sent
+	settings movie.Settings
+
+	mu       sync.Mutex
+	listener net.Listener
+	closed   bool
+}
+
+// spectator is a spectator being streamed to. Messages are queued for a goroutine to send,
+// so a slow connection doesn't hold up the game.
+type spectator struct {
+	conn net.Conn
+	out  chan []byte
+	// dropped is closed when the connection fails
+	dropped chan struct{}
+	once    sync.Once
+}
+
+// NewServer creates a server with no spectators. Add them with Serve or Add.
+func NewServer() *Server {
+	return &Server{joining: make(chan net.Conn, 16)}
+}
+
+// Listen creates a server that takes spectators connecting to the address.
+func Listen(address string) (*Server, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s := NewServer()
+	go s.Serve(listener)
+	return s, nil
+}
+
+// Serve takes spectators connecting to the listener until the server is closed.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return net.ErrClosed
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.Add(conn)
+	}
+}
+
+// Addr returns the address spectators connect to, or nil if the server isn't listening.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Add adds a spectator on a connection. They start watching from the end of the next frame.
+func (s *Server) Add(conn net.Conn) {
+	s.joining <- conn
+}
+
+// Spectators returns the number of spectators being streamed to.
+func (s *Server) Spectators() int {
+	return len(s.spectators)
+}
+
+// EndFrame sends the frame just emulated to the spectators, and starts the spectators who
+// have joined from here. It is called after every emulated frame.
+func (s *Server) EndFrame(vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) {
+	// Drop the spectators who have gone
+	s.spectators = deleteDropped(s.spectators)
+
+	if len(s.spectators) > 0 {
+		if settings := replay.CurrentSettings(vm, hardware); settings != s.settings {
+			s.settings = settings
+			if data, err := encodeJSON(settingsMessage, settings); err == nil {
+				s.broadcast(data)
+			}
+		}
+		s.broadcast(encodeInput(hardware.Controls()))
+		if frame := vm.FrameCount(); frame%ChecksumInterval == 0 {
+			s.broadcast(encodeChecksum(frame, movie.Checksum(hardware.RAM())))
+		}
+	}
+
+	for {
+		select {
+		case conn := <-s.joining:
+			s.start(conn, vm, hardware)
+		default:
+			return
+		}
+	}
+}
+
+// start sends a new spectator the machine as it is, and adds them to the stream.
+func (s *Server) start(conn net.Conn, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) {
+	settings := replay.CurrentSettings(vm, hardware)
+	welcome, err := encodeJSON(helloMessage, hello{Version: Version, ROMHash: movie.HashROM(hardware.ROM())})
+	if err != nil {
+		conn.Close()
+		return
+	}
+	state, err := encodeState(savestate.Save(vm, hardware))
+	if err != nil {
+		conn.Close()
+		return
+	}
+	current, err := encodeJSON(settingsMessage, settings)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	sp := &spectator{conn: conn, out: make(chan []byte, backlog), dropped: make(chan struct{})}
+	sp.out <- welcome
+	sp.out <- current
+	sp.out <- state
+	go sp.send()
+
+	s.settings = settings
+	s.spectators = append(s.spectators, sp)
+}
+
+// broadcast queues a message for every spectator, dropping those too far behind to keep up.
+func (s *Server) broadcast(data []byte) {
+	for _, sp := range s.spectators {
+		select {
+		case sp.out <- data:
+		default:
+			sp.drop()
+		}
+	}
+}
+
+// send writes the spectator's messages until the connection fails.
+func (sp *spectator) send() {
+	for {
+		select {
+		case data := <-sp.out:
+			if _, err := sp.conn.Write(data); err != nil {
+				sp.drop()
+				return
+			}
+		case <-sp.dropped:
+			return
+		}
+	}
+}
+
+// drop disconnects the spectator.
+func (sp *spectator) drop() {
+	sp.once.Do(func() {
+		close(sp.dropped)
+		sp.conn.Close()
+	})
+}
+
+// deleteDropped returns the spectators still connected.
+func deleteDropped(spectators []*spectator) []*spectator {
+	kept := spectators[:0]
+	for _, sp := range spectators {
+		select {
+		case <-sp.dropped:
+		default:
+			kept = append(kept, sp)
+		}
+	}
+	return kept
+}
+
+// Close stops taking spectators and disconnects the ones watching.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	for _, sp := range s.spectators {
+		sp.drop()
+	}
+	s.spectators = nil
+	return err
+}
@@ -0,0 +1,163 @@
+package spectate
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+// startGame inserts a coin, starts a one player game and moves and fires a little.
+const startGame = `
+30 coin
+35
+80 start1
+85
+150 p1right
+170 p1fire
+173
+`
+
+// join connects a spectator to the server, while the host keeps playing.
+func join(t *testing.T, server *Server, host func()) (*Spectator, *emulator.CPU8080, *invaders.SpaceInvadersHardware) {
+	t.Helper()
+	hostConn, spectatorConn := net.Pipe()
+	server.Add(hostConn)
+
+	vm, hardware := replay.NewMachine()
+	type result struct {
+		spectator *Spectator
+		err       error
+	}
+	joined := make(chan result, 1)
+	go func() {
+		spectator, err := NewSpectator(spectatorConn, vm, hardware)
+		joined <- result{spectator, err}
+	}()
+
+	// The spectator is sent the game at the end of the host's next frame
+	host()
+	r := <-joined
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	t.Cleanup(func() { r.spectator.Close() })
+	return r.spectator, vm, hardware
+}
+
+// catchUp updates a spectator until it has emulated the frames.
+func catchUp(t *testing.T, spectator *Spectator, vm *emulator.CPU8080, frames int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Minute)
+	for vm.FrameCount() < frames {
+		if err := spectator.Update(); err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out at frame %d", vm.FrameCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSpectators(t *testing.T) {
+	script, err := input.ParseScript(strings.NewReader(startGame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostVM, hostHardware := replay.NewMachine()
+	hostHardware.ShipsSetting = 4
+	hostHardware.Input = script
+	server := NewServer()
+	defer server.Close()
+	host := func() {
+		hostVM.Update()
+		server.EndFrame(hostVM, hostHardware)
+	}
+
+	// One spectator watches from the start, another joins in the middle of the game
+	early, earlyVM, earlyHardware := join(t, server, host)
+	for hostVM.FrameCount() < 120 {
+		host()
+	}
+	late, lateVM, lateHardware := join(t, server, host)
+	if lateVM.FrameCount() != 121 {
+		t.Errorf("expected the late spectator to start at frame 121, got %d", lateVM.FrameCount())
+	}
+	for hostVM.FrameCount() < 240 {
+		host()
+	}
+	if server.Spectators() != 2 {
+		t.Errorf("expected 2 spectators, got %d", server.Spectators())
+	}
+
+	catchUp(t, early, earlyVM, 240)
+	catchUp(t, late, lateVM, 240)
+	for _, hardware := range []*invaders.SpaceInvadersHardware{earlyHardware, lateHardware} {
+		if !bytes.Equal(hardware.RAM(), hostHardware.RAM()) {
+			t.Error("expected the spectator's RAM to match the host's")
+		}
+		if hardware.ShipsSetting != 4 {
+			t.Errorf("expected the host's settings, got %d ships", hardware.ShipsSetting)
+		}
+	}
+	if early.Desync() != nil || late.Desync() != nil {
+		t.Errorf("expected no desync, got %v and %v", early.Desync(), late.Desync())
+	}
+}
+
+func TestSpectatorDesync(t *testing.T) {
+	hostVM, hostHardware := replay.NewMachine()
+	hostHardware.ShipsSetting = 3
+	server := NewServer()
+	defer server.Close()
+	host := func() {
+		hostVM.Update()
+		server.EndFrame(hostVM, hostHardware)
+	}
+
+	// Join after the game has cleared the screen at boot
+	for hostVM.FrameCount() < 30 {
+		host()
+	}
+	spectator, vm, hardware := join(t, server, host)
+	// Something only the spectator sees: a stray pixel in the corner of the screen
+	hardware.RAM()[0x1FFF] ^= 0xFF
+	for hostVM.FrameCount() < ChecksumInterval {
+		host()
+	}
+	catchUp(t, spectator, vm, ChecksumInterval)
+	// The checksum follows the frame it was taken after
+	if err := spectator.Update(); err != nil {
+		t.Fatal(err)
+	}
+	if desync := spectator.Desync(); desync == nil || desync.Frame != ChecksumInterval {
+		t.Errorf("expected a desync at frame %d, got %v", ChecksumInterval, desync)
+	}
+}
+
+func TestHostDisconnect(t *testing.T) {
+	hostVM, hostHardware := replay.NewMachine()
+	hostHardware.ShipsSetting = 3
+	server := NewServer()
+	host := func() {
+		hostVM.Update()
+		server.EndFrame(hostVM, hostHardware)
+	}
+
+	spectator, _, _ := join(t, server, host)
+	server.Close()
+	deadline := time.Now().Add(time.Minute)
+	for spectator.Update() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected an error once the host disconnects")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
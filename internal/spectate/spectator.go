@@ -0,0 +1,228 @@
+package spectate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/emulator"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/invaders"
+	"github.com/braheezy/space-invaders/internal/movie"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+// JoinTimeout is how long to wait for the host to send the game.
+const JoinTimeout = 10 * time.Second
+
+// maxCatchUp is the most frames a spectator emulates in one update to catch up with the host.
+const maxCatchUp = 30
+
+// Spectator watches a game streamed by a Server, emulating it locally.
+type Spectator struct {
+	conn     net.Conn
+	vm       *emulator.CPU8080
+	hardware *invaders.SpaceInvadersHardware
+
+	// queue holds the messages received but not acted on yet
+	queue    []message
+	incoming chan message
+	// err is why the connection stopped
+	err error
+	// controls are the controls of the frame being emulated, for the hardware to poll
+	controls input.State
+	// settings are the host's settings
+	settings movie.Settings
+	// desync is the first time the game stopped matching the host's, if it has
+	desync *movie.DesyncError
+}
+
+// Watch connects to a host at the address and waits for the game.
+func Watch(address string, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) (*Spectator, error) {
+	conn, err := net.DialTimeout("tcp", address, JoinTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return NewSpectator(conn, vm, hardware)
+}
+
+// NewSpectator waits for the game on a connection to the host, and loads it into the machine.
+// The machine's interrupts have to be scheduled by the CPU, and its controls are taken over
+// by the spectator. The spectator owns the connection, and closes it if it can't start.
+func NewSpectator(conn net.Conn, vm *emulator.CPU8080, hardware *invaders.SpaceInvadersHardware) (*Spectator, error) {
+	s := &Spectator{
+		conn:     conn,
+		vm:       vm,
+		hardware: hardware,
+		incoming: make(chan message, backlog),
+	}
+	go s.receive()
+
+	if err := s.join(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	hardware.Input = s
+	return s, nil
+}
+
+// join waits for the hello, settings and save state that start the stream.
+func (s *Spectator) join() error {
+	timeout := time.After(JoinTimeout)
+	for {
+		var m message
+		var ok bool
+		select {
+		case m, ok = <-s.incoming:
+		case <-timeout:
+			return errors.New("timed out waiting for the host")
+		}
+		if !ok {
+			return fmt.Errorf("host disconnected: %w", s.err)
+		}
+
+		switch m.kind {
+		case helloMessage:
+			if m.hello.Version != Version {
+				return fmt.Errorf("host speaks spectating version %d, not %d", m.hello.Version, Version)
+			}
+			if romHash := movie.HashROM(s.hardware.ROM()); m.hello.ROMHash != romHash {
+				return fmt.Errorf("host has a different ROM (%s)", m.hello.ROMHash)
+			}
+		case settingsMessage:
+			if err := s.applySettings(m.settings); err != nil {
+				return err
+			}
+		case stateMessage:
+			m.state.Load(s.vm, s.hardware)
+			return nil
+		default:
+			return fmt.Errorf("unexpected message type %d before the game", m.kind)
+		}
+	}
+}
+
+// receive reads messages from the host until the connection stops.
+func (s *Spectator) receive() {
+	defer close(s.incoming)
+	for {
+		m, err := readMessage(s.conn)
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.incoming <- m
+	}
+}
+
+// applySettings plays with the host's settings, at whatever speed the frames arrive.
+func (s *Spectator) applySettings(settings movie.Settings) error {
+	settings.LimitTPS = false
+	if err := replay.ApplySettings(s.vm, s.hardware, settings); err != nil {
+		return err
+	}
+	s.settings = settings
+	return nil
+}
+
+// Settings returns the settings the host is playing with.
+func (s *Spectator) Settings() movie.Settings {
+	return s.settings
+}
+
+// Desync returns the first time the game stopped matching the host's, or nil if it hasn't.
+func (s *Spectator) Desync() *movie.DesyncError {
+	return s.desync
+}
+
+// Poll fulfills input.Source for the hardware, with the controls of the frame being emulated.
+func (s *Spectator) Poll() input.State {
+	return s.controls
+}
+
+// Update emulates the frames that have arrived from the host. Normally that's one, but a
+// spectator that has fallen behind emulates a few more to catch up. It returns an error once
+// the host disconnects and every frame sent has been shown.
+func (s *Spectator) Update() error {
+	closed := s.drain()
+
+	frames := s.framesQueued()
+	if frames > 1 {
+		// Keep one frame in hand to smooth over messages arriving unevenly
+		frames = min(frames-1, maxCatchUp)
+	}
+	for len(s.queue) > 0 && (frames > 0 || s.queue[0].kind != inputMessage) {
+		m := s.queue[0]
+		s.queue = s.queue[1:]
+		switch m.kind {
+		case settingsMessage:
+			if err := s.applySettings(m.settings); err != nil {
+				return err
+			}
+		case checksumMessage:
+			s.check(m.frame, m.checksum)
+		case inputMessage:
+			s.emulate(m.controls)
+			frames--
+		default:
+			return fmt.Errorf("unexpected message type %d", m.kind)
+		}
+	}
+
+	if closed && s.framesQueued() == 0 {
+		if s.err == nil {
+			return errors.New("host disconnected")
+		}
+		return fmt.Errorf("host disconnected: %w", s.err)
+	}
+	return nil
+}
+
+// drain moves the messages that have arrived to the queue, reporting whether the connection
+// has stopped.
+func (s *Spectator) drain() bool {
+	for {
+		select {
+		case m, ok := <-s.incoming:
+			if !ok {
+				return true
+			}
+			s.queue = append(s.queue, m)
+		default:
+			return false
+		}
+	}
+}
+
+// framesQueued returns how many frames have arrived but not been emulated.
+func (s *Spectator) framesQueued() int {
+	frames := 0
+	for _, m := range s.queue {
+		if m.kind == inputMessage {
+			frames++
+		}
+	}
+	return frames
+}
+
+// emulate runs a frame with the host's controls.
+func (s *Spectator) emulate(controls input.State) {
+	s.controls = controls
+	s.vm.Update()
+}
+
+// check compares the RAM with the host's checksum, which follows the frame it was taken after.
+func (s *Spectator) check(frame int, expected uint32) {
+	if frame != s.vm.FrameCount() || s.desync != nil {
+		return
+	}
+	if actual := movie.Checksum(s.hardware.RAM()); actual != expected {
+		s.desync = &movie.DesyncError{Frame: frame, Expected: expected, Actual: actual}
+	}
+}
+
+// Close stops watching.
+func (s *Spectator) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+)
+
+// Report is totals along with the figures worked out from them, for exporting.
+type Report struct {
+	// Session is when the session started, or empty for the lifetime totals
+	Session *time.Time `json:"session,omitempty"`
+	Totals
+	Hits            int     `json:"hits"`
+	Accuracy        float64 `json:"accuracy"`
+	AverageWave     float64 `json:"average_wave"`
+	PlayTimeSeconds float64 `json:"play_time_seconds"`
+}
+
+// NewReport returns the report for some totals.
+func NewReport(totals Totals) Report {
+	return Report{
+		Totals:          totals,
+		Hits:            totals.Hits(),
+		Accuracy:        totals.Accuracy(),
+		AverageWave:     totals.AverageWave(),
+		PlayTimeSeconds: totals.PlayTime().Seconds(),
+	}
+}
+
+// WriteJSON writes the lifetime totals and those of each session as JSON.
+func (r *Record) WriteJSON(w io.Writer) error {
+	export := struct {
+		Lifetime Report   `json:"lifetime"`
+		Sessions []Report `json:"sessions"`
+	}{Lifetime: NewReport(r.Lifetime), Sessions: []Report{}}
+	for _, session := range r.Sessions {
+		report := NewReport(session.Totals)
+		report.Session = &session.Start
+		export.Sessions = append(export.Sessions, report)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(export)
+}
+
+// WriteCSV writes the totals of each session as CSV, a row each, with the lifetime totals in
+// a last row named "lifetime". There's a column for each value the UFO can be worth, and for
+// each cause of death.
+func (r *Record) WriteCSV(w io.Writer) error {
+	ufoValues := slices.Clone(gamestate.UFOPoints[:])
+	slices.Sort(ufoValues)
+	ufoValues = slices.Compact(ufoValues)
+	header := []string{"session", "games", "shots_fired", "hits", "accuracy", "invaders_killed", "ufos_destroyed"}
+	for _, points := range ufoValues {
+		header = append(header, fmt.Sprintf("ufos_%d", points))
+	}
+	for _, cause := range Causes {
+		header = append(header, "deaths_"+strings.ReplaceAll(cause, " ", "_"))
+	}
+	header = append(header, "average_wave", "play_time_seconds")
+
+	row := func(name string, totals Totals) []string {
+		fields := []string{
+			name,
+			strconv.Itoa(totals.Games),
+			strconv.Itoa(totals.ShotsFired),
+			strconv.Itoa(totals.Hits()),
+			strconv.FormatFloat(totals.Accuracy(), 'f', 3, 64),
+			strconv.Itoa(totals.InvadersKilled),
+			strconv.Itoa(totals.UFOsDestroyed),
+		}
+		for _, points := range ufoValues {
+			fields = append(fields, strconv.Itoa(totals.UFOPoints[points]))
+		}
+		for _, cause := range Causes {
+			fields = append(fields, strconv.Itoa(totals.Deaths[cause]))
+		}
+		return append(fields,
+			strconv.FormatFloat(totals.AverageWave(), 'f', 2, 64),
+			strconv.FormatFloat(totals.PlayTime().Seconds(), 'f', 0, 64),
+		)
+	}
+
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+	for _, session := range r.Sessions {
+		writer.Write(row(session.Start.Format(time.RFC3339), session.Totals))
+	}
+	writer.Write(row("lifetime", r.Lifetime))
+	writer.Flush()
+	return writer.Error()
+}
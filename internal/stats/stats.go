@@ -0,0 +1,268 @@
+// Package stats keeps statistics about the games played: shots, hits, kills, UFOs, deaths
+// and play time. They're worked out from the state of the game in RAM each frame, and saved
+// to disk for each session and over all of them.
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+)
+
+// The causes of death.
+const (
+	RollingShot  = "rolling shot"
+	PlungerShot  = "plunger shot"
+	SquigglyShot = "squiggly shot"
+	// Invasion is the fleet landing, which ends the player's game
+	Invasion = "invasion"
+	// Unknown is a death that couldn't be put down to anything
+	Unknown = "unknown"
+)
+
+// Causes are the causes of death, in the order they're shown.
+var Causes = []string{RollingShot, PlungerShot, SquigglyShot, Invasion, Unknown}
+
+// bombCauses are the causes of death for each of the invaders' bombs, in gamestate order.
+var bombCauses = [3]string{RollingShot, PlungerShot, SquigglyShot}
+
+// FramesPerSecond is how many frames the machine plays each second.
+const FramesPerSecond = 60
+
+// Totals are the statistics over some games.
+type Totals struct {
+	Games          int `json:"games"`
+	ShotsFired     int `json:"shots_fired"`
+	InvadersKilled int `json:"invaders_killed"`
+	UFOsDestroyed  int `json:"ufos_destroyed"`
+	// UFOPoints counts the UFOs destroyed by what they were worth
+	UFOPoints map[int]int `json:"ufo_points"`
+	// Deaths counts the ships lost by cause. See Causes.
+	Deaths map[string]int `json:"deaths"`
+	// WavesReached adds up the furthest wave reached in each game
+	WavesReached int `json:"waves_reached"`
+	// Frames is the number of frames played, while a game was on
+	Frames int `json:"frames"`
+}
+
+// Hits returns the number of shots that hit an invader or the UFO.
+func (t Totals) Hits() int {
+	return t.InvadersKilled + t.UFOsDestroyed
+}
+
+// Accuracy returns the share of shots that hit, from 0 to 1.
+func (t Totals) Accuracy() float64 {
+	if t.ShotsFired == 0 {
+		return 0
+	}
+	return float64(t.Hits()) / float64(t.ShotsFired)
+}
+
+// AverageWave returns the average of the furthest wave reached in each game.
+func (t Totals) AverageWave() float64 {
+	if t.Games == 0 {
+		return 0
+	}
+	return float64(t.WavesReached) / float64(t.Games)
+}
+
+// PlayTime returns how long games were played for.
+func (t Totals) PlayTime() time.Duration {
+	return time.Duration(t.Frames) * time.Second / FramesPerSecond
+}
+
+// TotalDeaths returns the number of ships lost to anything.
+func (t Totals) TotalDeaths() int {
+	total := 0
+	for _, count := range t.Deaths {
+		total += count
+	}
+	return total
+}
+
+// Add adds other totals to these.
+func (t *Totals) Add(other Totals) {
+	t.Games += other.Games
+	t.ShotsFired += other.ShotsFired
+	t.InvadersKilled += other.InvadersKilled
+	t.UFOsDestroyed += other.UFOsDestroyed
+	for points, count := range other.UFOPoints {
+		t.addUFO(points, count)
+	}
+	for cause, count := range other.Deaths {
+		t.addDeath(cause, count)
+	}
+	t.WavesReached += other.WavesReached
+	t.Frames += other.Frames
+}
+
+func (t *Totals) addUFO(points, count int) {
+	if t.UFOPoints == nil {
+		t.UFOPoints = map[int]int{}
+	}
+	t.UFOPoints[points] += count
+}
+
+func (t *Totals) addDeath(cause string, count int) {
+	if t.Deaths == nil {
+		t.Deaths = map[string]int{}
+	}
+	t.Deaths[cause] += count
+}
+
+// Session is the statistics of one run of the game.
+type Session struct {
+	Start time.Time `json:"start"`
+	Totals
+}
+
+// Record is the statistics kept on disk: those of each session, and over all of them.
+type Record struct {
+	Lifetime Totals    `json:"lifetime"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Load reads a record from a file. A missing file is an empty record.
+func Load(path string) (*Record, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Record{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var r Record
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Save writes the record to a file.
+func (r *Record) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// With returns the record with a session added to it, leaving this one as it is.
+func (r *Record) With(session Session) *Record {
+	with := &Record{Sessions: append(slices.Clone(r.Sessions), session)}
+	with.Lifetime.Add(r.Lifetime)
+	with.Lifetime.Add(session.Totals)
+	return with
+}
+
+// Tracker works out the statistics of the games played on a machine, frame by frame.
+type Tracker struct {
+	// Session is the statistics since the tracker was made
+	Session Session
+	// Paused stops the tracker counting, while it keeps following the game
+	Paused bool
+
+	ram  []byte
+	last gamestate.State
+	// cleared are the waves each player has cleared in the game being played. The wave
+	// counter in RAM goes round, so they're counted as the last invader of each is killed.
+	cleared [2]int
+}
+
+// NewTracker returns a tracker for the game in RAM, starting a session now.
+func NewTracker(ram []byte) *Tracker {
+	return &Tracker{ram: ram, Session: Session{Start: time.Now()}}
+}
+
+// Update looks at the frame just played, and adds what happened to the session. It reports
+// whether a game just ended.
+func (t *Tracker) Update() (gameOver bool) {
+	state := gamestate.Decode(t.ram)
+	last := t.last
+	t.last = state
+	totals := &t.Session.Totals
+	if t.Paused {
+		totals = &Totals{}
+	}
+
+	if !state.Playing {
+		if last.Playing {
+			totals.WavesReached += max(t.cleared[0], t.cleared[1]) + 1
+			return true
+		}
+		return false
+	}
+	totals.Frames++
+	if !last.Playing {
+		totals.Games++
+		t.cleared = [2]int{}
+		return false
+	}
+
+	if state.Player != last.Player {
+		// The turn passed to the other player
+		return false
+	}
+
+	if state.Shot.Active && !last.Shot.Active {
+		totals.ShotsFired++
+	}
+	if state.InvadersLeft < last.InvadersLeft {
+		totals.InvadersKilled += last.InvadersLeft - state.InvadersLeft
+		if state.InvadersLeft == 0 {
+			t.cleared[state.Player]++
+		}
+	}
+
+	// The UFO is the only thing worth 50 or more, and its points go on the score all at once.
+	// The score going back to 0 as a game starts is far more than a UFO is worth.
+	scored := state.Scores[state.Player] - last.Scores[state.Player]
+	if scored < 0 {
+		// The score rolls over past 9999
+		scored += gamestate.MaxScore + 1
+	}
+	if scored >= minUFOPoints && scored <= maxUFOPoints {
+		totals.UFOsDestroyed++
+		totals.addUFO(scored, 1)
+	}
+
+	if last.PlayerAlive && !state.PlayerAlive {
+		totals.addDeath(deathCause(state), 1)
+	}
+	return false
+}
+
+// deathCause works out what killed the ship: the fleet landing, or the bomb blowing up on it.
+func deathCause(state gamestate.State) string {
+	if state.Invaded {
+		return Invasion
+	}
+	for i, bomb := range state.Bombs {
+		if !bomb.Active && !bomb.Exploding {
+			continue
+		}
+		if bomb.Position.Y <= shipTop && bomb.Position.X+bombWidth >= state.PlayerX && bomb.Position.X <= state.PlayerX+shipWidth {
+			return bombCauses[i]
+		}
+	}
+	return Unknown
+}
+
+// The least and most the UFO is worth
+var (
+	minUFOPoints = slices.Min(gamestate.UFOPoints[:])
+	maxUFOPoints = slices.Max(gamestate.UFOPoints[:])
+)
+
+// The size of the ship and bombs, in pixels
+const (
+	shipWidth = 16
+	shipTop   = 0x28
+	bombWidth = 3
+)
@@ -0,0 +1,239 @@
+package stats
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/braheezy/space-invaders/internal/gamestate"
+	"github.com/braheezy/space-invaders/internal/input"
+	"github.com/braheezy/space-invaders/internal/replay"
+)
+
+func TestTracker(t *testing.T) {
+	ram := make([]byte, gamestate.RAMSize)
+	tracker := NewTracker(ram)
+	// frame changes RAM and tracks the frame
+	frame := func(change func()) bool {
+		change()
+		return tracker.Update()
+	}
+
+	// The demo doesn't count
+	frame(func() { ram[gamestate.PlayerAlive] = 0xFF })
+	// A game with two invaders left starts
+	frame(func() {
+		ram[gamestate.GameMode] = 1
+		ram[gamestate.CurrentPlayer] = 0x21
+		ram[gamestate.Player1Invaders] = 1
+		ram[gamestate.Player1Invaders+1] = 1
+	})
+	// Two shots miss
+	for range 2 {
+		frame(func() { ram[gamestate.PlayerShotStatus] = 1 })
+		frame(func() { ram[gamestate.PlayerShotStatus] = 0 })
+	}
+	// One kills an invader, and the next the UFO
+	frame(func() { ram[gamestate.PlayerShotStatus] = 1 })
+	frame(func() {
+		ram[gamestate.PlayerShotStatus] = 3
+		ram[gamestate.Player1Invaders] = 0
+		gamestate.PutScore(ram, gamestate.Player1Score, 10)
+	})
+	frame(func() { ram[gamestate.PlayerShotStatus] = 1 })
+	frame(func() {
+		ram[gamestate.PlayerShotStatus] = 0
+		gamestate.PutScore(ram, gamestate.Player1Score, 310)
+	})
+	// The last invader is killed, clearing the wave
+	frame(func() { ram[gamestate.PlayerShotStatus] = 1 })
+	frame(func() {
+		ram[gamestate.PlayerShotStatus] = 3
+		ram[gamestate.Player1Invaders+1] = 0
+		gamestate.PutScore(ram, gamestate.Player1Score, 320)
+	})
+	// A plunger shot blows up on the ship
+	frame(func() {
+		ram[gamestate.PlayerX] = 0x60
+		ram[gamestate.PlungerShot+5], ram[gamestate.PlungerShot+13], ram[gamestate.PlungerShot+14] = 0x81, 0x22, 0x66
+		ram[gamestate.PlayerAlive] = 0
+	})
+	if gameOver := frame(func() { ram[gamestate.GameMode] = 0 }); !gameOver {
+		t.Error("expected the game to be over")
+	}
+
+	got := tracker.Session.Totals
+	if got.Games != 1 || got.ShotsFired != 5 || got.InvadersKilled != 2 || got.UFOsDestroyed != 1 {
+		t.Errorf("expected 1 game, 5 shots, 2 kills and 1 UFO, got %+v", got)
+	}
+	if got.UFOPoints[300] != 1 {
+		t.Errorf("expected a 300 point UFO, got %v", got.UFOPoints)
+	}
+	if got.Deaths[PlungerShot] != 1 || got.TotalDeaths() != 1 {
+		t.Errorf("expected a death to a plunger shot, got %v", got.Deaths)
+	}
+	if got.AverageWave() != 2 {
+		t.Errorf("expected wave 2 reached, got %v", got.AverageWave())
+	}
+	if got.Accuracy() != 0.6 {
+		t.Errorf("expected 60%% accuracy, got %v", got.Accuracy())
+	}
+	if got.Frames != 12 {
+		t.Errorf("expected 12 frames played, got %d", got.Frames)
+	}
+}
+
+func TestInvasion(t *testing.T) {
+	ram := make([]byte, gamestate.RAMSize)
+	tracker := NewTracker(ram)
+	ram[gamestate.GameMode] = 1
+	ram[gamestate.PlayerAlive] = 0xFF
+	tracker.Update()
+	tracker.Update()
+
+	// The fleet lands, blowing up the ship
+	ram[gamestate.Invaded] = 1
+	ram[gamestate.PlayerAlive] = 0
+	tracker.Update()
+	// The game ends with the ship back, as the ROM leaves it
+	ram[gamestate.PlayerAlive] = 0xFF
+	tracker.Update()
+	ram[gamestate.GameMode] = 0
+	tracker.Update()
+	if deaths := tracker.Session.Deaths; deaths[Invasion] != 1 || tracker.Session.TotalDeaths() != 1 {
+		t.Errorf("expected a death to invasion, got %v", deaths)
+	}
+}
+
+func TestDeathsOnROM(t *testing.T) {
+	tests := []struct {
+		name string
+		// invade moves the fleet down to the ship once the game is on
+		invade    bool
+		invasions int
+	}{
+		{name: "Bombs", invasions: 0},
+		{name: "Invasion", invade: true, invasions: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := input.ParseScript(strings.NewReader("60 coin\n65\n120 start1\n125\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			vm, hardware := replay.NewMachine()
+			hardware.ShipsSetting = 3
+			hardware.Input = source
+			ram := hardware.RAM()
+			tracker := NewTracker(ram)
+
+			// Nobody touches the controls, so the game is over in a few thousand frames
+			for frame := 0; frame < 20000; frame++ {
+				vm.Update()
+				if tt.invade && frame == 400 {
+					ram[gamestate.FleetY] = 0x30
+				}
+				if tracker.Update() {
+					break
+				}
+			}
+
+			got := tracker.Session.Totals
+			if got.Games != 1 {
+				t.Fatalf("expected a game played, got %+v", got)
+			}
+			if got.Deaths[Invasion] != tt.invasions {
+				t.Errorf("expected %d deaths to invasion, got %v", tt.invasions, got.Deaths)
+			}
+			if !tt.invade && got.TotalDeaths() != 3 {
+				t.Errorf("expected all 3 ships lost, got %v", got.Deaths)
+			}
+			if tt.invade && got.TotalDeaths() != 1 {
+				t.Errorf("expected the landing to end the game, got %v", got.Deaths)
+			}
+		})
+	}
+}
+
+func TestNewGameScoreIsNotUFO(t *testing.T) {
+	ram := make([]byte, gamestate.RAMSize)
+	tracker := NewTracker(ram)
+	gamestate.PutScore(ram, gamestate.Player1Score, 1270)
+	ram[gamestate.GameMode] = 1
+	tracker.Update()
+	tracker.Update()
+
+	gamestate.PutScore(ram, gamestate.Player1Score, 0)
+	tracker.Update()
+	if tracker.Session.UFOsDestroyed != 0 {
+		t.Errorf("expected no UFOs, got %d", tracker.Session.UFOsDestroyed)
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.json")
+	record, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Lifetime.Games != 0 || len(record.Sessions) != 0 {
+		t.Fatalf("expected a missing file to be an empty record, got %+v", record)
+	}
+
+	session := Session{Totals: Totals{Games: 2, UFOPoints: map[int]int{150: 1}, Deaths: map[string]int{RollingShot: 3}}}
+	record.Sessions = append(record.Sessions, session)
+	record.Lifetime.Add(session.Totals)
+	record.Lifetime.Add(session.Totals)
+	if err := record.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Lifetime.Games != 4 || loaded.Lifetime.UFOPoints[150] != 2 || loaded.Lifetime.Deaths[RollingShot] != 6 {
+		t.Errorf("expected the totals added twice, got %+v", loaded.Lifetime)
+	}
+	if len(loaded.Sessions) != 1 || loaded.Sessions[0].Games != 2 {
+		t.Errorf("expected the session back, got %+v", loaded.Sessions)
+	}
+}
+
+func TestExport(t *testing.T) {
+	record := &Record{}
+	record = record.With(Session{Totals: Totals{Games: 1, ShotsFired: 4, InvadersKilled: 3, UFOsDestroyed: 1, UFOPoints: map[int]int{300: 1}, Deaths: map[string]int{Invasion: 1}, WavesReached: 2, Frames: 600}})
+
+	var csv strings.Builder
+	if err := record.WriteCSV(&csv); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header, a session and the lifetime totals, got %q", lines)
+	}
+	want := "session,games,shots_fired,hits,accuracy,invaders_killed,ufos_destroyed,ufos_50,ufos_100,ufos_150,ufos_300,deaths_rolling_shot,deaths_plunger_shot,deaths_squiggly_shot,deaths_invasion,deaths_unknown,average_wave,play_time_seconds"
+	if lines[0] != want {
+		t.Errorf("expected header %q, got %q", want, lines[0])
+	}
+	if want := "lifetime,1,4,4,1.000,3,1,0,0,0,1,0,0,0,1,0,2.00,10"; lines[2] != want {
+		t.Errorf("expected %q, got %q", want, lines[2])
+	}
+
+	var out strings.Builder
+	if err := record.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var export struct {
+		Lifetime Report   `json:"lifetime"`
+		Sessions []Report `json:"sessions"`
+	}
+	if err := json.Unmarshal([]byte(out.String()), &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Lifetime.Accuracy != 1 || export.Lifetime.PlayTimeSeconds != 10 || len(export.Sessions) != 1 || export.Sessions[0].Session == nil {
+		t.Errorf("expected the lifetime totals and a session, got %+v", export)
+	}
+}